@@ -0,0 +1,208 @@
+// Package alert evaluates staleness rules against the lock manager and
+// notifies sinks when an alert starts firing or resolves.
+package alert
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shadyabhi/foolock/lockstate"
+)
+
+type Kind string
+
+const (
+	// NotAcquired fires when a job hasn't been held, acquired or renewed for
+	// For
+	NotAcquired Kind = "not_acquired"
+	// NoSuccess fires when a job hasn't completed successfully for For
+	NoSuccess Kind = "no_success"
+	// HeldTooLong fires when a single holder has kept a job for over For
+	HeldTooLong Kind = "held_too_long"
+)
+
+// Rule describes a condition to alert on. An empty Job applies the rule to
+// every job the manager knows about.
+type Rule struct {
+	Name string        `yaml:"name"`
+	Job  string        `yaml:"job"`
+	Kind Kind          `yaml:"kind"`
+	For  time.Duration `yaml:"for"`
+}
+
+func (r Rule) validate() error {
+	if r.Name == "" {
+		return fmt.Errorf("rule without a name")
+	}
+	switch r.Kind {
+	case NotAcquired, NoSuccess, HeldTooLong:
+	default:
+		return fmt.Errorf("rule %q: unknown kind %q", r.Name, r.Kind)
+	}
+	if r.For <= 0 {
+		return fmt.Errorf("rule %q: for must be positive", r.Name)
+	}
+	return nil
+}
+
+type Status string
+
+const (
+	Firing   Status = "firing"
+	Resolved Status = "resolved"
+)
+
+// Alert is the current state of a rule for one job
+type Alert struct {
+	Rule       string
+	Job        string
+	Kind       Kind
+	Status     Status
+	Message    string
+	Since      time.Time
+	ResolvedAt time.Time
+}
+
+// Sink delivers alert transitions somewhere outside the process
+type Sink interface {
+	Notify(ctx context.Context, a Alert) error
+}
+
+// Engine periodically evaluates rules and tracks which alerts are firing
+type Engine struct {
+	manager *lockstate.Manager
+	rules   []Rule
+	sinks   []Sink
+	started time.Time
+
+	mu     sync.Mutex
+	alerts map[string]*Alert
+}
+
+func NewEngine(manager *lockstate.Manager, rules []Rule, sinks ...Sink) *Engine {
+	return &Engine{
+		manager: manager,
+		rules:   rules,
+		sinks:   sinks,
+		started: time.Now(),
+		alerts:  make(map[string]*Alert),
+	}
+}
+
+// Run evaluates the rules every interval until ctx is cancelled
+func (e *Engine) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		e.Evaluate(ctx, time.Now())
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Evaluate checks every rule as of now and notifies sinks of alerts that
+// started firing or resolved since the previous evaluation
+func (e *Engine) Evaluate(ctx context.Context, now time.Time) {
+	var changed []Alert
+
+	e.mu.Lock()
+	for _, rule := range e.rules {
+		jobs := []string{rule.Job}
+		if rule.Job == "" {
+			jobs = e.manager.Jobs()
+		}
+		for _, job := range jobs {
+			message, firing := e.check(rule, e.manager.Status(job), now)
+			if a, ok := e.transition(rule, job, message, firing, now); ok {
+				changed = append(changed, a)
+			}
+		}
+	}
+	e.mu.Unlock()
+
+	for _, a := range changed {
+		slog.Info("Alert", "status", a.Status, "rule", a.Rule, "job", a.Job, "message", a.Message)
+		for _, sink := range e.sinks {
+			if err := sink.Notify(ctx, a); err != nil {
+				slog.Error("Error notifying alert sink", "err", err)
+			}
+		}
+	}
+}
+
+// check reports whether rule currently fires for a job's status
+func (e *Engine) check(rule Rule, status lockstate.StatusResult, now time.Time) (string, bool) {
+	switch rule.Kind {
+	case NotAcquired:
+		if status.Holder != "" && !status.IsExpired {
+			return "", false
+		}
+		last := latest(status.LastAcquiredAt, e.started)
+		if now.Sub(last) >= rule.For {
+			return fmt.Sprintf("not acquired for %s", now.Sub(last).Round(time.Second)), true
+		}
+	case NoSuccess:
+		last := latest(status.LastSuccessAt, e.started)
+		if now.Sub(last) >= rule.For {
+			return fmt.Sprintf("no successful run for %s", now.Sub(last).Round(time.Second)), true
+		}
+	case HeldTooLong:
+		if status.Holder != "" && !status.IsExpired && now.Sub(status.AcquiredAt) >= rule.For {
+			return fmt.Sprintf("held by %s for %s", status.Holder, now.Sub(status.AcquiredAt).Round(time.Second)), true
+		}
+	}
+	return "", false
+}
+
+// transition updates the stored alert and reports whether it changed state;
+// the caller must hold e.mu
+func (e *Engine) transition(rule Rule, job, message string, firing bool, now time.Time) (Alert, bool) {
+	key := rule.Name + "\x00" + job
+	a, ok := e.alerts[key]
+
+	switch {
+	case firing && (!ok || a.Status == Resolved):
+		a = &Alert{Rule: rule.Name, Job: job, Kind: rule.Kind, Status: Firing, Message: message, Since: now}
+		e.alerts[key] = a
+		return *a, true
+	case firing:
+		a.Message = message
+	case ok && a.Status == Firing:
+		a.Status = Resolved
+		a.ResolvedAt = now
+		return *a, true
+	}
+	return Alert{}, false
+}
+
+// Alerts returns every alert that has fired, sorted by rule and job
+func (e *Engine) Alerts() []Alert {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	alerts := make([]Alert, 0, len(e.alerts))
+	for _, a := range e.alerts {
+		alerts = append(alerts, *a)
+	}
+	slices.SortFunc(alerts, func(a, b Alert) int {
+		return cmp.Or(strings.Compare(a.Rule, b.Rule), strings.Compare(a.Job, b.Job))
+	})
+	return alerts
+}
+
+func latest(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
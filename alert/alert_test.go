@@ -0,0 +1,130 @@
+package alert
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/shadyabhi/foolock/lockstate"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingSink struct {
+	mu     sync.Mutex
+	alerts []Alert
+}
+
+func (s *recordingSink) Notify(_ context.Context, a Alert) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.alerts = append(s.alerts, a)
+	return nil
+}
+
+func TestEngineNotAcquired(t *testing.T) {
+	m := lockstate.New()
+	sink := &recordingSink{}
+	e := NewEngine(m, []Rule{{Name: "stale", Job: "backup", Kind: NotAcquired, For: time.Hour}}, sink)
+	ctx := context.Background()
+	start := e.started
+
+	e.Evaluate(ctx, start.Add(30*time.Minute))
+	require.Empty(t, sink.alerts, "rule must not fire before For has passed since startup")
+
+	e.Evaluate(ctx, start.Add(2*time.Hour))
+	require.Len(t, sink.alerts, 1)
+	require.Equal(t, Firing, sink.alerts[0].Status)
+	require.Equal(t, "backup", sink.alerts[0].Job)
+
+	// Still firing: no new notification
+	e.Evaluate(ctx, start.Add(3*time.Hour))
+	require.Len(t, sink.alerts, 1)
+
+	m.Acquire("backup", "client1", time.Minute)
+	e.Evaluate(ctx, time.Now())
+	require.Len(t, sink.alerts, 2)
+	require.Equal(t, Resolved, sink.alerts[1].Status)
+	require.False(t, sink.alerts[1].ResolvedAt.IsZero())
+
+	alerts := e.Alerts()
+	require.Len(t, alerts, 1)
+	require.Equal(t, Resolved, alerts[0].Status)
+}
+
+func TestEngineNotAcquiredWhileRenewed(t *testing.T) {
+	m := lockstate.New()
+	sink := &recordingSink{}
+	e := NewEngine(m, []Rule{{Name: "stale", Job: "backup", Kind: NotAcquired, For: 20 * time.Millisecond}}, sink)
+
+	m.Acquire("backup", "client1", time.Minute)
+	time.Sleep(30 * time.Millisecond)
+	e.Evaluate(context.Background(), time.Now())
+	require.Empty(t, sink.alerts, "a held job is not stale")
+
+	// Renewals count as acquiring, so it isn't stale right after a release
+	m.Acquire("backup", "client1", time.Minute)
+	m.Release("backup", "client1")
+	e.Evaluate(context.Background(), time.Now())
+	require.Empty(t, sink.alerts)
+}
+
+func TestEngineNoSuccess(t *testing.T) {
+	m := lockstate.New()
+	sink := &recordingSink{}
+	e := NewEngine(m, []Rule{{Name: "failing", Job: "backup", Kind: NoSuccess, For: time.Hour}}, sink)
+	ctx := context.Background()
+
+	// A failed run doesn't count as success
+	m.Acquire("backup", "client1", time.Minute)
+	m.Release("backup", "client1", lockstate.WithOutcome(lockstate.Outcome{ExitCode: 1}))
+	e.Evaluate(ctx, time.Now().Add(2*time.Hour))
+	require.Len(t, sink.alerts, 1)
+	require.Equal(t, Firing, sink.alerts[0].Status)
+
+	m.Acquire("backup", "client1", time.Minute)
+	m.Release("backup", "client1", lockstate.Succeeded())
+	e.Evaluate(ctx, time.Now())
+	require.Len(t, sink.alerts, 2)
+	require.Equal(t, Resolved, sink.alerts[1].Status)
+}
+
+func TestEngineHeldTooLongAllJobs(t *testing.T) {
+	m := lockstate.New()
+	sink := &recordingSink{}
+	e := NewEngine(m, []Rule{{Name: "stuck", Kind: HeldTooLong, For: time.Hour}}, sink)
+	ctx := context.Background()
+
+	m.Acquire("backup", "client1", 3*time.Hour)
+	m.Acquire("sync", "client2", 3*time.Hour)
+	m.Release("sync", "client2")
+
+	e.Evaluate(ctx, time.Now())
+	require.Empty(t, sink.alerts)
+
+	e.Evaluate(ctx, time.Now().Add(2*time.Hour))
+	require.Len(t, sink.alerts, 1)
+	require.Equal(t, "backup", sink.alerts[0].Job)
+	require.Contains(t, sink.alerts[0].Message, "client1")
+}
+
+func TestEngineRun(t *testing.T) {
+	m := lockstate.New()
+	m.Acquire("backup", "client1", time.Hour)
+	sink := &recordingSink{}
+	e := NewEngine(m, []Rule{{Name: "stuck", Kind: HeldTooLong, For: time.Nanosecond}}, sink)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		e.Run(ctx, time.Millisecond)
+		close(done)
+	}()
+
+	require.Eventually(t, func() bool {
+		return len(e.Alerts()) == 1
+	}, time.Second, time.Millisecond)
+
+	cancel()
+	<-done
+}
@@ -0,0 +1,88 @@
+package alert
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const defaultInterval = time.Minute
+
+// Config is the alerting section loaded from a YAML file:
+//
+//	interval: 1m
+//	rules:
+//	  - name: backup-stale
+//	    job: backup
+//	    kind: no_success
+//	    for: 24h
+//	  - name: stuck
+//	    kind: held_too_long
+//	    for: 2h
+//	sinks:
+//	  - webhook: https://example.com/hook
+//	  - command: [/usr/local/bin/notify, --urgent]
+type Config struct {
+	Interval time.Duration `yaml:"interval"`
+	Rules    []Rule        `yaml:"rules"`
+	Sinks    []SinkConfig  `yaml:"sinks"`
+}
+
+// SinkConfig configures exactly one of the supported sinks
+type SinkConfig struct {
+	Webhook string   `yaml:"webhook"`
+	Command []string `yaml:"command"`
+}
+
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+func Parse(data []byte) (*Config, error) {
+	var c Config
+	if err := yaml.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("parsing alert config: %w", err)
+	}
+	if c.Interval == 0 {
+		c.Interval = defaultInterval
+	}
+	if c.Interval < 0 {
+		return nil, fmt.Errorf("interval must be positive")
+	}
+
+	names := make(map[string]bool, len(c.Rules))
+	for _, rule := range c.Rules {
+		if err := rule.validate(); err != nil {
+			return nil, err
+		}
+		if names[rule.Name] {
+			return nil, fmt.Errorf("duplicate rule name %q", rule.Name)
+		}
+		names[rule.Name] = true
+	}
+	for i, sink := range c.Sinks {
+		if (sink.Webhook == "") == (len(sink.Command) == 0) {
+			return nil, fmt.Errorf("sink %d: set exactly one of webhook or command", i)
+		}
+	}
+	return &c, nil
+}
+
+// BuildSinks creates the configured sinks
+func (c *Config) BuildSinks() []Sink {
+	sinks := make([]Sink, 0, len(c.Sinks))
+	for _, sc := range c.Sinks {
+		if sc.Webhook != "" {
+			sinks = append(sinks, &Webhook{URL: sc.Webhook})
+			continue
+		}
+		sinks = append(sinks, &Command{Path: sc.Command[0], Args: sc.Command[1:]})
+	}
+	return sinks
+}
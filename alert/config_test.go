@@ -0,0 +1,64 @@
+package alert
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	c, err := Parse([]byte(`
+interval: 30s
+rules:
+  - name: backup-stale
+    job: backup
+    kind: no_success
+    for: 24h
+  - name: stuck
+    kind: held_too_long
+    for: 2h
+sinks:
+  - webhook: https://example.com/hook
+  - command: [/usr/local/bin/notify, --urgent]
+`))
+	require.NoError(t, err)
+	require.Equal(t, 30*time.Second, c.Interval)
+	require.Equal(t, []Rule{
+		{Name: "backup-stale", Job: "backup", Kind: NoSuccess, For: 24 * time.Hour},
+		{Name: "stuck", Kind: HeldTooLong, For: 2 * time.Hour},
+	}, c.Rules)
+
+	sinks := c.BuildSinks()
+	require.Len(t, sinks, 2)
+	require.Equal(t, &Webhook{URL: "https://example.com/hook"}, sinks[0])
+	require.Equal(t, &Command{Path: "/usr/local/bin/notify", Args: []string{"--urgent"}}, sinks[1])
+}
+
+func TestParseDefaultInterval(t *testing.T) {
+	c, err := Parse([]byte(`rules: []`))
+	require.NoError(t, err)
+	require.Equal(t, defaultInterval, c.Interval)
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"unknown kind", "rules: [{name: a, kind: bogus, for: 1h}]"},
+		{"missing name", "rules: [{kind: no_success, for: 1h}]"},
+		{"missing for", "rules: [{name: a, kind: no_success}]"},
+		{"duplicate name", "rules: [{name: a, kind: no_success, for: 1h}, {name: a, kind: not_acquired, for: 1h}]"},
+		{"empty sink", "sinks: [{}]"},
+		{"two sinks in one", "sinks: [{webhook: http://x, command: [ls]}]"},
+		{"negative interval", "interval: -1m"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(tt.data))
+			require.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,32 @@
+package alert
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+)
+
+type AlertsResponse struct {
+	Alerts []Notification `json:"alerts"`
+}
+
+// HandleAlerts serves GET /alerts with every firing and resolved alert
+func (e *Engine) HandleAlerts(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	alerts := e.Alerts()
+	response := AlertsResponse{Alerts: make([]Notification, 0, len(alerts))}
+	for _, a := range alerts {
+		response.Alerts = append(response.Alerts, newNotification(a))
+	}
+
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		slog.Error("Error encoding response", "err", err)
+	}
+}
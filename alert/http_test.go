@@ -0,0 +1,37 @@
+package alert
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/shadyabhi/foolock/lockstate"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleAlerts(t *testing.T) {
+	m := lockstate.New()
+	m.Acquire("backup", "client1", time.Hour)
+	e := NewEngine(m, []Rule{{Name: "stuck", Kind: HeldTooLong, For: time.Minute}})
+	e.Evaluate(context.Background(), time.Now().Add(time.Hour))
+
+	req := httptest.NewRequest(http.MethodGet, "/alerts", nil)
+	w := httptest.NewRecorder()
+	e.HandleAlerts(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	var resp AlertsResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Len(t, resp.Alerts, 1)
+	require.Equal(t, "stuck", resp.Alerts[0].Rule)
+	require.Equal(t, "backup", resp.Alerts[0].Job)
+	require.Equal(t, "firing", resp.Alerts[0].Status)
+
+	req = httptest.NewRequest(http.MethodPost, "/alerts", nil)
+	w = httptest.NewRecorder()
+	e.HandleAlerts(w, req)
+	require.Equal(t, http.StatusMethodNotAllowed, w.Code)
+}
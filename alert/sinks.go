@@ -0,0 +1,117 @@
+package alert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"time"
+)
+
+// Notification is the JSON payload sent to webhooks and commands
+type Notification struct {
+	Rule       string `json:"rule"`
+	Job        string `json:"job"`
+	Kind       string `json:"kind"`
+	Status     string `json:"status"`
+	Message    string `json:"message"`
+	Since      string `json:"since"`
+	ResolvedAt string `json:"resolved_at,omitempty"`
+}
+
+func newNotification(a Alert) Notification {
+	n := Notification{
+		Rule:    a.Rule,
+		Job:     a.Job,
+		Kind:    string(a.Kind),
+		Status:  string(a.Status),
+		Message: a.Message,
+		Since:   a.Since.Format(time.RFC3339),
+	}
+	if !a.ResolvedAt.IsZero() {
+		n.ResolvedAt = a.ResolvedAt.Format(time.RFC3339)
+	}
+	return n
+}
+
+// defaultTimeout bounds each notification, so a stuck sink can't stall the
+// alert loop
+const defaultTimeout = 10 * time.Second
+
+// Webhook POSTs each alert transition as JSON to a URL
+type Webhook struct {
+	URL    string
+	Client *http.Client
+}
+
+func (w *Webhook) Notify(ctx context.Context, a Alert) error {
+	body, err := json.Marshal(newNotification(a))
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := w.Client
+	if client == nil {
+		client = &http.Client{Timeout: defaultTimeout}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook %s returned %s", w.URL, resp.Status)
+	}
+	return nil
+}
+
+// Command runs a local program for each alert transition, passing the alert
+// as JSON on stdin and as FOOLOCK_ALERT_* environment variables. It is killed
+// if it runs longer than Timeout, 10s if zero.
+type Command struct {
+	Path    string
+	Args    []string
+	Timeout time.Duration
+}
+
+func (c *Command) Notify(ctx context.Context, a Alert) error {
+	n := newNotification(a)
+	body, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+
+	timeout := c.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, c.Path, c.Args...)
+	// Stop waiting for output a background child may keep open after the
+	// command itself is killed
+	cmd.WaitDelay = time.Second
+	cmd.Stdin = bytes.NewReader(body)
+	cmd.Env = append(os.Environ(),
+		"FOOLOCK_ALERT_RULE="+n.Rule,
+		"FOOLOCK_ALERT_JOB="+n.Job,
+		"FOOLOCK_ALERT_KIND="+n.Kind,
+		"FOOLOCK_ALERT_STATUS="+n.Status,
+		"FOOLOCK_ALERT_MESSAGE="+n.Message,
+	)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("alert command %s: %w: %s", c.Path, err, bytes.TrimSpace(out))
+	}
+	return nil
+}
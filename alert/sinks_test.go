@@ -0,0 +1,74 @@
+package alert
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+var testAlert = Alert{
+	Rule:    "stale",
+	Job:     "backup",
+	Kind:    NoSuccess,
+	Status:  Firing,
+	Message: "no successful run for 25h0m0s",
+	Since:   time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC),
+}
+
+func TestWebhook(t *testing.T) {
+	var got Notification
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, http.MethodPost, r.Method)
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+	}))
+	defer srv.Close()
+
+	w := &Webhook{URL: srv.URL}
+	require.NoError(t, w.Notify(context.Background(), testAlert))
+	require.Equal(t, "stale", got.Rule)
+	require.Equal(t, "backup", got.Job)
+	require.Equal(t, "firing", got.Status)
+	require.Equal(t, "2024-01-15T10:30:00Z", got.Since)
+}
+
+func TestWebhookError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	w := &Webhook{URL: srv.URL}
+	require.Error(t, w.Notify(context.Background(), testAlert))
+}
+
+func TestCommand(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "out")
+	c := &Command{Path: "/bin/sh", Args: []string{"-c", `echo "$FOOLOCK_ALERT_JOB $FOOLOCK_ALERT_STATUS" > ` + out + `; cat >> ` + out}}
+
+	require.NoError(t, c.Notify(context.Background(), testAlert))
+
+	data, err := os.ReadFile(out)
+	require.NoError(t, err)
+	require.Contains(t, string(data), "backup firing\n")
+	require.Contains(t, string(data), `"rule":"stale"`)
+}
+
+func TestCommandError(t *testing.T) {
+	c := &Command{Path: "/bin/sh", Args: []string{"-c", "echo nope; exit 3"}}
+	err := c.Notify(context.Background(), testAlert)
+	require.ErrorContains(t, err, "nope")
+}
+
+func TestCommandTimeout(t *testing.T) {
+	c := &Command{Path: "/bin/sh", Args: []string{"-c", "sleep 10 & sleep 10"}, Timeout: 50 * time.Millisecond}
+	start := time.Now()
+	require.Error(t, c.Notify(context.Background(), testAlert))
+	require.Less(t, time.Since(start), 5*time.Second)
+}
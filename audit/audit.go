@@ -0,0 +1,62 @@
+// Package audit appends lock events to a file as JSON lines for long-term
+// retention beyond the in-memory per-job history.
+package audit
+
+import (
+	"encoding/json"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/shadyabhi/foolock/lockstate"
+)
+
+type Record struct {
+	Time    string `json:"time"`
+	Job     string `json:"job"`
+	Type    string `json:"type"`
+	Client  string `json:"client,omitempty"`
+	Holder  string `json:"holder,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// Log writes events to an append-only file
+type Log struct {
+	mu   sync.Mutex
+	file *os.File
+	enc  *json.Encoder
+}
+
+// Open opens path for appending, creating it if needed
+func Open(path string) (*Log, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &Log{file: f, enc: json.NewEncoder(f)}, nil
+}
+
+// Write appends a single event; it matches lockstate.EventSink
+func (l *Log) Write(e lockstate.Event) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if err := l.enc.Encode(Record{
+		Time:    e.Time.Format(time.RFC3339Nano),
+		Job:     e.Job,
+		Type:    string(e.Type),
+		Client:  e.Client,
+		Holder:  e.Holder,
+		Message: e.Message,
+	}); err != nil {
+		slog.Error("Error writing audit log", "err", err)
+	}
+}
+
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.file.Close()
+}
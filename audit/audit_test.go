@@ -0,0 +1,70 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/shadyabhi/foolock/lockstate"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogWrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+
+	l, err := Open(path)
+	require.NoError(t, err)
+
+	m := lockstate.New(lockstate.WithEventSink(l.Write))
+	m.Acquire("backup", "laptop1", time.Minute)
+	m.Release("backup", "laptop1")
+	m.Close()
+	require.NoError(t, l.Close())
+
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+
+	var records []Record
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var r Record
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &r))
+		records = append(records, r)
+	}
+	require.NoError(t, scanner.Err())
+
+	require.Len(t, records, 2)
+	require.Equal(t, "acquired", records[0].Type)
+	require.Equal(t, "backup", records[0].Job)
+	require.Equal(t, "laptop1", records[0].Client)
+	require.Equal(t, "released", records[1].Type)
+}
+
+func TestLogAppends(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+
+	for range 2 {
+		l, err := Open(path)
+		require.NoError(t, err)
+		l.Write(lockstate.Event{Time: time.Now(), Job: "backup", Type: lockstate.EventAcquired})
+		require.NoError(t, l.Close())
+	}
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, 2, countLines(data))
+}
+
+func countLines(data []byte) int {
+	n := 0
+	for _, b := range data {
+		if b == '\n' {
+			n++
+		}
+	}
+	return n
+}
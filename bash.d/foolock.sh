@@ -4,8 +4,8 @@
 #
 # Usage:
 #   source foolock.sh
-#   foolock_acquire [job] [ttl]    # Acquire lock with optional job and TTL
-#   foolock_release [job]          # Release the lock for a job
+#   foolock_acquire [job] [ttl] [min_interval]  # Acquire lock with optional job, TTL and run interval
+#   foolock_release [job] [success]             # Release the lock, optionally marking the run successful
 #   foolock_status [job]           # Check lock status for a job
 #
 # Environment variables:
@@ -32,6 +32,7 @@ _foolock_get_client_id() {
 # Arguments:
 #   $1 - Job name (optional, default: $FOOLOCK_JOB or "default")
 #   $2 - TTL (optional, default: 30s, e.g., "10s", "5m", "1h")
+#   $3 - Minimum interval since the last successful run (optional, e.g., "1h")
 # Returns:
 #   0 on success, 1 on failure
 # Outputs:
@@ -39,6 +40,7 @@ _foolock_get_client_id() {
 foolock_acquire() {
     local job="${1:-$FOOLOCK_JOB}"
     local ttl="${2:-30s}"
+    local min_interval="${3:-}"
     local client_id
     client_id=$(_foolock_get_client_id)
 
@@ -51,7 +53,7 @@ foolock_acquire() {
     local http_code
 
     response=$(curl -s -w "\n%{http_code}" -X POST \
-        "${FOOLOCK_SERVER}/lock?client=${client_id}&job=${job}&ttl=${ttl}")
+        "${FOOLOCK_SERVER}/lock?client=${client_id}&job=${job}&ttl=${ttl}&min_interval=${min_interval}")
 
     http_code=$(echo "$response" | tail -n1)
     response=$(echo "$response" | sed '$d')
@@ -68,12 +70,14 @@ foolock_acquire() {
 # Release the lock
 # Arguments:
 #   $1 - Job name (optional, default: $FOOLOCK_JOB or "default")
+#   $2 - "true" to mark the run as successful (optional)
 # Returns:
 #   0 on success, 1 on failure
 # Outputs:
 #   JSON response from server
 foolock_release() {
     local job="${1:-$FOOLOCK_JOB}"
+    local success="${2:-}"
     local client_id
     client_id=$(_foolock_get_client_id)
 
@@ -86,7 +90,7 @@ foolock_release() {
     local http_code
 
     response=$(curl -s -w "\n%{http_code}" -X DELETE \
-        "${FOOLOCK_SERVER}/lock?client=${client_id}&job=${job}")
+        "${FOOLOCK_SERVER}/lock?client=${client_id}&job=${job}&success=${success}")
 
     http_code=$(echo "$response" | tail -n1)
     response=$(echo "$response" | sed '$d')
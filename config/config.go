@@ -0,0 +1,418 @@
+// Package config assembles the server configuration from defaults, an
+// optional YAML file, FOOLOCK_* environment variables and command-line
+// flags, each overriding the one before.
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shadyabhi/foolock/lockstate"
+	"github.com/shadyabhi/foolock/ratelimit"
+	"gopkg.in/yaml.v3"
+)
+
+const envPrefix = "FOOLOCK_"
+
+// Config is the effective server configuration. Zero durations mean "no
+// limit" except for TTL, which must be positive.
+//
+//	addr: :8080
+//	grpc_addr: :9090
+//	resp_addr: :6380
+//	unix_socket: /run/foolock.sock
+//	ttl: 30s
+//	max_ttl: 1h
+//	grace_period: 5s
+//	drain_period: 10s
+//	state_file: /var/lib/foolock/state.json
+//	log_file: /var/log/foolock.log
+//	log_level: debug
+//	log_format: json
+//	access_log: /var/log/foolock-access.log
+//	cors_origins: [https://dashboard.example.com]
+//	client_rate: 5
+//	rate_limits:
+//	  clients:
+//	    ci: {rate: 50, burst: 100}
+//	  jobs:
+//	    backup: {rate: 0.2}
+//	audit_log: /var/lib/foolock/audit.jsonl
+//	policy: /etc/foolock/policy.yaml
+//	alerts: /etc/foolock/alerts.yaml
+type Config struct {
+	Addr string `yaml:"addr"`
+	// GRPCAddr serves the gRPC API on a port of its own; empty turns it off
+	GRPCAddr string `yaml:"grpc_addr"`
+	// RESPAddr serves the Redis protocol frontend on a port of its own;
+	// empty turns it off
+	RESPAddr string `yaml:"resp_addr"`
+	// UnixSocket also serves the HTTP API on a Unix socket at this path,
+	// identifying clients by their process credentials; Linux only
+	UnixSocket  string        `yaml:"unix_socket"`
+	TTL         time.Duration `yaml:"ttl"`
+	MaxTTL      time.Duration `yaml:"max_ttl"`
+	GracePeriod time.Duration `yaml:"grace_period"`
+	MaxHold     time.Duration `yaml:"max_hold"`
+	Cooldown    time.Duration `yaml:"cooldown"`
+	HistorySize int           `yaml:"history_size"`
+	RunsSize    int           `yaml:"runs_size"`
+
+	// On SIGTERM or SIGINT the server keeps serving renewals and releases for
+	// DrainPeriod, then gives in-flight requests up to ShutdownTimeout
+	DrainPeriod     time.Duration `yaml:"drain_period"`
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+	// StateFile, if set, is written on shutdown and loaded on start
+	StateFile string `yaml:"state_file"`
+
+	// LogFile receives the server log instead of stderr
+	LogFile string `yaml:"log_file"`
+	// LogLevel is debug, info, warn or error; LogFormat is text or json
+	LogLevel  string `yaml:"log_level"`
+	LogFormat string `yaml:"log_format"`
+
+	// AccessLog receives a line per request, "-" meaning stdout, in
+	// AccessLogFormat: common or json
+	AccessLog       string `yaml:"access_log"`
+	AccessLogFormat string `yaml:"access_log_format"`
+	// RequestTimeout and MaxBodySize bound each request; zero disables them
+	RequestTimeout time.Duration `yaml:"request_timeout"`
+	MaxBodySize    int           `yaml:"max_body_size"`
+	// CORSOrigins may call the API from a browser; "*" allows any
+	CORSOrigins []string `yaml:"cors_origins"`
+
+	// Acquisitions and releases allowed per second for each client and each
+	// remote address, with bursts of up to the matching burst; zero rates
+	// mean no limit
+	ClientRate  float64 `yaml:"client_rate"`
+	ClientBurst int     `yaml:"client_burst"`
+	IPRate      float64 `yaml:"ip_rate"`
+	IPBurst     int     `yaml:"ip_burst"`
+	// RateLimits overrides the client limit for particular clients and adds
+	// limits on particular jobs; it can only be set in the config file
+	RateLimits RateLimits `yaml:"rate_limits"`
+
+	// Optional subsystems, each enabled by pointing it at a file
+	AuditLog string `yaml:"audit_log"`
+	Policy   string `yaml:"policy"`
+	Alerts   string `yaml:"alerts"`
+}
+
+// RateLimits holds per-client and per-job token buckets
+type RateLimits struct {
+	Clients map[string]ratelimit.Limit `yaml:"clients"`
+	Jobs    map[string]ratelimit.Limit `yaml:"jobs"`
+}
+
+// Default returns the configuration used when nothing is overridden
+func Default() Config {
+	return Config{
+		Addr:        ":8080",
+		TTL:         30 * time.Second,
+		GracePeriod: 5 * time.Second,
+		HistorySize: 100,
+		RunsSize:    50,
+
+		LogLevel:  "info",
+		LogFormat: "text",
+
+		AccessLogFormat: "common",
+		RequestTimeout:  30 * time.Second,
+		MaxBodySize:     1 << 20,
+
+		DrainPeriod:     5 * time.Second,
+		ShutdownTimeout: 10 * time.Second,
+	}
+}
+
+// setting ties one Config field to its file key, flag and environment
+// variable; the flag is the key with dashes and the variable is FOOLOCK_
+// followed by the upper-cased key
+type setting struct {
+	key   string
+	usage string
+	field func(*Config) any
+}
+
+var settings = []setting{
+	{"addr", "listen address", func(c *Config) any { return &c.Addr }},
+	{"grpc_addr", "listen address for the gRPC API, empty to disable", func(c *Config) any { return &c.GRPCAddr }},
+	{"resp_addr", "listen address for the Redis protocol frontend, empty to disable", func(c *Config) any { return &c.RESPAddr }},
+	{"unix_socket", "also serve the HTTP API on a Unix socket at this path, identifying clients by uid and process", func(c *Config) any { return &c.UnixSocket }},
+	{"ttl", "default lock ttl", func(c *Config) any { return &c.TTL }},
+	{"max_ttl", "longest ttl a client may ask for, 0 for no limit", func(c *Config) any { return &c.MaxTTL }},
+	{"grace_period", "how long an expired lock stays reserved for its holder", func(c *Config) any { return &c.GracePeriod }},
+	{"max_hold", "longest a holder may keep a lock through renewals, 0 for no limit", func(c *Config) any { return &c.MaxHold }},
+	{"cooldown", "how long a released lock stays unavailable, 0 for none", func(c *Config) any { return &c.Cooldown }},
+	{"history_size", "events kept per job", func(c *Config) any { return &c.HistorySize }},
+	{"runs_size", "runs kept per job", func(c *Config) any { return &c.RunsSize }},
+	{"drain_period", "how long to keep serving renewals and releases after a shutdown signal, 0 to stop at once", func(c *Config) any { return &c.DrainPeriod }},
+	{"shutdown_timeout", "how long in-flight requests get to finish on shutdown, 0 for no limit", func(c *Config) any { return &c.ShutdownTimeout }},
+	{"state_file", "save lock state here on shutdown and restore it on start", func(c *Config) any { return &c.StateFile }},
+	{"log_file", "write the server log to this file instead of stderr", func(c *Config) any { return &c.LogFile }},
+	{"log_level", "minimum level logged: debug, info, warn or error", func(c *Config) any { return &c.LogLevel }},
+	{"log_format", "log output format: text or json", func(c *Config) any { return &c.LogFormat }},
+	{"access_log", `write an access log line per request to this file, "-" for stdout`, func(c *Config) any { return &c.AccessLog }},
+	{"access_log_format", "access log format: common or json", func(c *Config) any { return &c.AccessLogFormat }},
+	{"request_timeout", "longest a read-only request may take, 0 for no limit", func(c *Config) any { return &c.RequestTimeout }},
+	{"max_body_size", "largest request body accepted in bytes, 0 for no limit", func(c *Config) any { return &c.MaxBodySize }},
+	{"cors_origins", "comma-separated browser `origins` allowed to call the API, * for any", func(c *Config) any { return &c.CORSOrigins }},
+	{"client_rate", "acquisitions and releases per second allowed for each client, 0 for no limit", func(c *Config) any { return &c.ClientRate }},
+	{"client_burst", "requests a client may make at once before client_rate applies", func(c *Config) any { return &c.ClientBurst }},
+	{"ip_rate", "acquisitions and releases per second allowed for each remote address, 0 for no limit", func(c *Config) any { return &c.IPRate }},
+	{"ip_burst", "requests an address may make at once before ip_rate applies", func(c *Config) any { return &c.IPBurst }},
+	{"audit_log", "append lock events as JSON lines to this file", func(c *Config) any { return &c.AuditLog }},
+	{"policy", "load per-job policies from this YAML or JSON file", func(c *Config) any { return &c.Policy }},
+	{"alerts", "load alert rules and sinks from this YAML file", func(c *Config) any { return &c.Alerts }},
+}
+
+func (s setting) flagName() string {
+	return strings.ReplaceAll(s.key, "_", "-")
+}
+
+func (s setting) envName() string {
+	return envPrefix + strings.ToUpper(s.key)
+}
+
+// set parses value into the setting's field
+func (s setting) set(c *Config, value string) error {
+	var err error
+	switch p := s.field(c).(type) {
+	case *string:
+		*p = value
+	case *time.Duration:
+		*p, err = time.ParseDuration(value)
+	case *int:
+		*p, err = strconv.Atoi(value)
+	case *float64:
+		*p, err = strconv.ParseFloat(value, 64)
+	case *[]string:
+		*p = splitList(value)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: invalid value %q", s.key, value)
+	}
+	return nil
+}
+
+// define registers the setting's flag on fs, typed like its field and
+// defaulting to its value in c, and returns a function copying the parsed
+// flag into a Config
+func (s setting) define(fs *flag.FlagSet, c *Config) func(*Config) {
+	name := s.flagName()
+	usage := fmt.Sprintf("%s (env %s)", s.usage, s.envName())
+	switch p := s.field(c).(type) {
+	case *string:
+		v := fs.String(name, *p, usage)
+		return func(c *Config) { *s.field(c).(*string) = *v }
+	case *time.Duration:
+		v := fs.Duration(name, *p, usage)
+		return func(c *Config) { *s.field(c).(*time.Duration) = *v }
+	case *int:
+		v := fs.Int(name, *p, usage)
+		return func(c *Config) { *s.field(c).(*int) = *v }
+	case *float64:
+		v := fs.Float64(name, *p, usage)
+		return func(c *Config) { *s.field(c).(*float64) = *v }
+	case *[]string:
+		v := listValue(*p)
+		fs.Var(&v, name, usage)
+		return func(c *Config) { *s.field(c).(*[]string) = v }
+	}
+	panic("config: unsupported type for " + s.key)
+}
+
+// listValue is a comma-separated list flag
+type listValue []string
+
+func (l *listValue) String() string {
+	if l == nil {
+		return ""
+	}
+	return strings.Join(*l, ",")
+}
+
+func (l *listValue) Set(value string) error {
+	*l = splitList(value)
+	return nil
+}
+
+// splitList splits a comma-separated list, dropping empty entries
+func splitList(value string) []string {
+	var list []string
+	for v := range strings.SplitSeq(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+// Options are the command-line switches that aren't configuration values
+type Options struct {
+	// PrintConfig asks for the effective configuration to be printed
+	PrintConfig bool
+}
+
+// Load builds the effective configuration from args (without the program
+// name) and the environment. The config file is named by -config or
+// FOOLOCK_CONFIG.
+func Load(args []string, getenv func(string) string) (Config, Options, error) {
+	cfg := Default()
+	var opts Options
+
+	fs := flag.NewFlagSet("foolock", flag.ContinueOnError)
+	configPath := fs.String("config", getenv(envPrefix+"CONFIG"), "read settings from this YAML file (env "+envPrefix+"CONFIG)")
+	fs.BoolVar(&opts.PrintConfig, "print-config", false, "print the effective configuration as YAML and exit")
+	apply := make(map[string]func(*Config), len(settings))
+	for _, s := range settings {
+		apply[s.flagName()] = s.define(fs, &cfg)
+	}
+	if err := fs.Parse(args); err != nil {
+		return Config{}, Options{}, err
+	}
+
+	if *configPath != "" {
+		if err := cfg.loadFile(*configPath); err != nil {
+			return Config{}, Options{}, err
+		}
+	}
+
+	for _, s := range settings {
+		if v := getenv(s.envName()); v != "" {
+			if err := s.set(&cfg, v); err != nil {
+				return Config{}, Options{}, fmt.Errorf("%s: %w", s.envName(), err)
+			}
+		}
+	}
+
+	fs.Visit(func(f *flag.Flag) {
+		if set, ok := apply[f.Name]; ok {
+			set(&cfg)
+		}
+	})
+
+	if err := cfg.validate(); err != nil {
+		return Config{}, Options{}, err
+	}
+	return cfg, opts, nil
+}
+
+// loadFile overlays the settings present in a YAML file; unknown keys are
+// rejected so typos don't go unnoticed
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("parsing config file: %w", err)
+	}
+	// The empty values YAML() writes for these read back as empty rather
+	// than nil
+	if len(c.CORSOrigins) == 0 {
+		c.CORSOrigins = nil
+	}
+	if len(c.RateLimits.Clients) == 0 {
+		c.RateLimits.Clients = nil
+	}
+	if len(c.RateLimits.Jobs) == 0 {
+		c.RateLimits.Jobs = nil
+	}
+	return nil
+}
+
+func (c *Config) validate() error {
+	if c.Addr == "" {
+		return fmt.Errorf("addr must not be empty")
+	}
+	if c.TTL <= 0 {
+		return fmt.Errorf("ttl must be positive")
+	}
+	if c.UnixSocket != "" && runtime.GOOS != "linux" {
+		return fmt.Errorf("unix_socket is only supported on Linux")
+	}
+	if c.MaxTTL < 0 || c.GracePeriod < 0 || c.MaxHold < 0 || c.Cooldown < 0 || c.DrainPeriod < 0 || c.ShutdownTimeout < 0 || c.RequestTimeout < 0 {
+		return fmt.Errorf("durations must not be negative")
+	}
+	if c.MaxTTL > 0 && c.TTL > c.MaxTTL {
+		return fmt.Errorf("ttl %s is above max_ttl %s", c.TTL, c.MaxTTL)
+	}
+	if c.HistorySize < 0 || c.RunsSize < 0 || c.MaxBodySize < 0 {
+		return fmt.Errorf("history_size, runs_size and max_body_size must not be negative")
+	}
+	limits := []ratelimit.Limit{{Rate: c.ClientRate, Burst: c.ClientBurst}, {Rate: c.IPRate, Burst: c.IPBurst}}
+	for _, l := range c.RateLimits.Clients {
+		limits = append(limits, l)
+	}
+	for _, l := range c.RateLimits.Jobs {
+		limits = append(limits, l)
+	}
+	for _, l := range limits {
+		if l.Rate < 0 || l.Burst < 0 {
+			return fmt.Errorf("rate limits must not be negative")
+		}
+	}
+	if c.AccessLogFormat != "common" && c.AccessLogFormat != "json" {
+		return fmt.Errorf("access_log_format %q is not common or json", c.AccessLogFormat)
+	}
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.LogLevel)); err != nil {
+		return fmt.Errorf("log_level %q is not debug, info, warn or error", c.LogLevel)
+	}
+	if c.LogFormat != "text" && c.LogFormat != "json" {
+		return fmt.Errorf("log_format %q is not text or json", c.LogFormat)
+	}
+	return nil
+}
+
+// ManagerOptions converts the lock settings into lockstate manager options
+func (c Config) ManagerOptions() []lockstate.Option {
+	return []lockstate.Option{
+		lockstate.WithTTL(c.TTL),
+		lockstate.WithMaxTTL(c.MaxTTL),
+		lockstate.WithGracePeriod(c.GracePeriod),
+		lockstate.WithMaxHold(c.MaxHold),
+		lockstate.WithCooldown(c.Cooldown),
+		lockstate.WithHistorySize(c.HistorySize),
+		lockstate.WithRunsSize(c.RunsSize),
+	}
+}
+
+// RateLimit converts the rate limit settings for the rate limiter
+func (c Config) RateLimit() ratelimit.Config {
+	return ratelimit.Config{
+		Client:  ratelimit.Limit{Rate: c.ClientRate, Burst: c.ClientBurst},
+		IP:      ratelimit.Limit{Rate: c.IPRate, Burst: c.IPBurst},
+		Clients: c.RateLimits.Clients,
+		Jobs:    c.RateLimits.Jobs,
+	}
+}
+
+// Logger builds the server logger writing to w in the configured format and
+// level
+func (c Config) Logger(w io.Writer) *slog.Logger {
+	var level slog.Level
+	level.UnmarshalText([]byte(c.LogLevel))
+	opts := &slog.HandlerOptions{Level: level}
+	if c.LogFormat == "json" {
+		return slog.New(slog.NewJSONHandler(w, opts))
+	}
+	return slog.New(slog.NewTextHandler(w, opts))
+}
+
+// YAML renders the configuration in the config file format
+func (c Config) YAML() ([]byte, error) {
+	return yaml.Marshal(c)
+}
@@ -0,0 +1,223 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/shadyabhi/foolock/ratelimit"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func env(vars map[string]string) func(string) string {
+	return func(key string) string { return vars[key] }
+}
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "foolock.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	return path
+}
+
+func TestLoadDefaults(t *testing.T) {
+	cfg, opts, err := Load(nil, env(nil))
+	require.NoError(t, err)
+	require.Equal(t, Default(), cfg)
+	require.False(t, opts.PrintConfig)
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	path := writeConfig(t, `
+addr: :9000
+ttl: 1m
+grace_period: 10s
+policy: /etc/foolock/policy.yaml
+`)
+
+	// file only
+	cfg, _, err := Load([]string{"-config", path}, env(nil))
+	require.NoError(t, err)
+	require.Equal(t, ":9000", cfg.Addr)
+	require.Equal(t, time.Minute, cfg.TTL)
+	require.Equal(t, 10*time.Second, cfg.GracePeriod)
+	require.Equal(t, "/etc/foolock/policy.yaml", cfg.Policy)
+	require.Empty(t, cfg.GRPCAddr, "gRPC is off by default")
+	require.Empty(t, cfg.RESPAddr, "RESP is off by default")
+	require.Empty(t, cfg.UnixSocket, "the Unix socket is off by default")
+
+	// env overrides the file, and can name the file itself
+	cfg, _, err = Load(nil, env(map[string]string{
+		"FOOLOCK_CONFIG":    path,
+		"FOOLOCK_TTL":       "2m",
+		"FOOLOCK_GRPC_ADDR": ":9090",
+		"FOOLOCK_RESP_ADDR": ":6380",
+	}))
+	require.NoError(t, err)
+	require.Equal(t, ":9000", cfg.Addr)
+	require.Equal(t, ":9090", cfg.GRPCAddr)
+	require.Equal(t, ":6380", cfg.RESPAddr)
+	require.Equal(t, 2*time.Minute, cfg.TTL)
+
+	// flags override both
+	cfg, _, err = Load([]string{"-config", path, "-ttl", "3m", "-grace-period", "0s"}, env(map[string]string{
+		"FOOLOCK_TTL": "2m",
+	}))
+	require.NoError(t, err)
+	require.Equal(t, 3*time.Minute, cfg.TTL)
+	require.Zero(t, cfg.GracePeriod)
+	require.Equal(t, ":9000", cfg.Addr)
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		env  map[string]string
+		file string
+	}{
+		{"bad flag duration", []string{"-ttl", "soon"}, nil, ""},
+		{"bad env int", nil, map[string]string{"FOOLOCK_HISTORY_SIZE": "lots"}, ""},
+		{"unknown flag", []string{"-nope"}, nil, ""},
+		{"unknown file key", nil, nil, "tll: 30s\n"},
+		{"zero ttl", []string{"-ttl", "0s"}, nil, ""},
+		{"ttl above max", []string{"-ttl", "2h", "-max-ttl", "1h"}, nil, ""},
+		{"negative grace", nil, map[string]string{"FOOLOCK_GRACE_PERIOD": "-1s"}, ""},
+		{"bad log level", []string{"-log-level", "loud"}, nil, ""},
+		{"bad log format", nil, map[string]string{"FOOLOCK_LOG_FORMAT": "xml"}, ""},
+		{"bad access log format", []string{"-access-log-format", "combined"}, nil, ""},
+		{"negative body size", []string{"-max-body-size", "-1"}, nil, ""},
+		{"bad rate", []string{"-client-rate", "fast"}, nil, ""},
+		{"negative job rate", nil, nil, "rate_limits:\n  jobs:\n    backup: {rate: -1}\n"},
+		{"missing file", []string{"-config", "/nonexistent/foolock.yaml"}, nil, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := tt.args
+			if tt.file != "" {
+				args = append(args, "-config", writeConfig(t, tt.file))
+			}
+			_, _, err := Load(args, env(tt.env))
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestLoadEmptyFile(t *testing.T) {
+	cfg, _, err := Load([]string{"-config", writeConfig(t, "")}, env(nil))
+	require.NoError(t, err)
+	require.Equal(t, Default(), cfg)
+}
+
+func TestPrintConfigRoundTrip(t *testing.T) {
+	cfg, opts, err := Load([]string{"-print-config", "-max-ttl", "1h", "-audit-log", "/tmp/audit.jsonl"}, env(nil))
+	require.NoError(t, err)
+	require.True(t, opts.PrintConfig)
+
+	out, err := cfg.YAML()
+	require.NoError(t, err)
+
+	var parsed map[string]any
+	require.NoError(t, yaml.Unmarshal(out, &parsed))
+	require.Equal(t, "1h0m0s", parsed["max_ttl"])
+	require.Equal(t, "/tmp/audit.jsonl", parsed["audit_log"])
+
+	again, _, err := Load([]string{"-config", writeConfig(t, string(out))}, env(nil))
+	require.NoError(t, err)
+	require.Equal(t, cfg, again)
+}
+
+func TestLogger(t *testing.T) {
+	cfg, _, err := Load([]string{"-log-level", "warn", "-log-format", "json"}, env(nil))
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	logger := cfg.Logger(&buf)
+	logger.Info("hidden")
+	logger.Warn("shown", "job", "backup")
+
+	var entry map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+	require.Equal(t, "shown", entry["msg"])
+	require.Equal(t, "backup", entry["job"])
+}
+
+func TestLoadList(t *testing.T) {
+	cfg, _, err := Load(nil, env(map[string]string{"FOOLOCK_CORS_ORIGINS": "https://a.example, https://b.example"}))
+	require.NoError(t, err)
+	require.Equal(t, []string{"https://a.example", "https://b.example"}, cfg.CORSOrigins)
+
+	cfg, _, err = Load([]string{"-config", writeConfig(t, "cors_origins: ['*']\n")}, env(nil))
+	require.NoError(t, err)
+	require.Equal(t, []string{"*"}, cfg.CORSOrigins)
+}
+
+func TestRateLimit(t *testing.T) {
+	path := writeConfig(t, `
+client_rate: 5
+rate_limits:
+  clients:
+    ci: {rate: 50, burst: 100}
+  jobs:
+    backup: {rate: 0.2}
+`)
+	cfg, _, err := Load([]string{"-config", path, "-ip-rate", "2.5"}, env(nil))
+	require.NoError(t, err)
+
+	rl := cfg.RateLimit()
+	require.Equal(t, ratelimit.Limit{Rate: 5}, rl.Client)
+	require.Equal(t, ratelimit.Limit{Rate: 2.5}, rl.IP)
+	require.Equal(t, ratelimit.Limit{Rate: 50, Burst: 100}, rl.Clients["ci"])
+	require.Equal(t, ratelimit.Limit{Rate: 0.2}, rl.Jobs["backup"])
+	require.True(t, rl.Enabled())
+
+	out, err := cfg.YAML()
+	require.NoError(t, err)
+	again, _, err := Load([]string{"-config", writeConfig(t, string(out))}, env(nil))
+	require.NoError(t, err)
+	require.Equal(t, cfg, again)
+}
+
+func TestFlagTypes(t *testing.T) {
+	fs := flag.NewFlagSet("foolock", flag.ContinueOnError)
+	cfg := Default()
+	for _, s := range settings {
+		s.define(fs, &cfg)
+	}
+
+	tests := map[string]string{
+		"addr":         "string",
+		"ttl":          "duration",
+		"history-size": "int",
+		"client-rate":  "float",
+		"cors-origins": "origins",
+	}
+	for name, want := range tests {
+		got, _ := flag.UnquoteUsage(fs.Lookup(name))
+		require.Equal(t, want, got, name)
+	}
+	require.Equal(t, "30s", fs.Lookup("ttl").DefValue)
+}
+
+func TestPrintConfigShowsEverySetting(t *testing.T) {
+	out, err := Default().YAML()
+	require.NoError(t, err)
+
+	var parsed map[string]any
+	require.NoError(t, yaml.Unmarshal(out, &parsed))
+	for _, s := range settings {
+		require.Contains(t, parsed, s.key)
+	}
+	require.Contains(t, parsed, "rate_limits")
+	require.Equal(t, "5s", parsed["drain_period"], "holders get a window to release on shutdown")
+
+	again, _, err := Load([]string{"-config", writeConfig(t, string(out))}, env(nil))
+	require.NoError(t, err)
+	require.Equal(t, Default(), again)
+}
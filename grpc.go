@@ -0,0 +1,53 @@
+package main
+
+import (
+	"log/slog"
+	"time"
+
+	"google.golang.org/grpc"
+
+	"github.com/shadyabhi/foolock/lockpb"
+	"github.com/shadyabhi/foolock/lockstate"
+	"github.com/shadyabhi/foolock/lockstategrpc"
+)
+
+// grpcService is the gRPC server and the lock service registered on it
+type grpcService struct {
+	server *grpc.Server
+	locks  *lockstategrpc.Server
+}
+
+func newGRPCService(manager *lockstate.Manager, opts ...lockstategrpc.Option) *grpcService {
+	g := &grpcService{
+		server: grpc.NewServer(),
+		locks:  lockstategrpc.New(manager, opts...),
+	}
+	lockpb.RegisterLocksServer(g.server, g.locks)
+	return g
+}
+
+// stop ends Watch streams, so clients reconnect elsewhere, then waits up to
+// timeout, or forever if it's zero, for other calls to finish before cutting
+// them off. It does nothing if gRPC is turned off.
+func (g *grpcService) stop(timeout time.Duration) {
+	if g == nil {
+		return
+	}
+	g.locks.Close()
+
+	stopped := make(chan struct{})
+	go func() {
+		g.server.GracefulStop()
+		close(stopped)
+	}()
+	if timeout == 0 {
+		<-stopped
+		return
+	}
+	select {
+	case <-stopped:
+	case <-time.After(timeout):
+		slog.Warn("gRPC shutdown did not complete")
+		g.server.Stop()
+	}
+}
@@ -0,0 +1,6 @@
+// Package lockpb holds the gRPC service definition for foolock and the code
+// generated from it. After editing lock.proto, regenerate with go generate,
+// which needs protoc, protoc-gen-go and protoc-gen-go-grpc on the PATH.
+package lockpb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative lock.proto
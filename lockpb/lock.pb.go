@@ -0,0 +1,1029 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        (unknown)
+// source: lock.proto
+
+// The gRPC counterpart of the /v1 HTTP API. Refusals such as a lock held by
+// another client are answered normally, with success false and a code, so
+// clients can see who holds the lock and until when; RPC errors are kept for
+// malformed requests and throttling.
+
+package lockpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	durationpb "google.golang.org/protobuf/types/known/durationpb"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type AcquireRequest struct {
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	Job    string                 `protobuf:"bytes,1,opt,name=job,proto3" json:"job,omitempty"`
+	Client string                 `protobuf:"bytes,2,opt,name=client,proto3" json:"client,omitempty"`
+	// Unset uses the job's default
+	Ttl *durationpb.Duration `protobuf:"bytes,3,opt,name=ttl,proto3" json:"ttl,omitempty"`
+	// Refuses with code ran_recently if the job last succeeded less than this
+	// long ago
+	MinInterval   *durationpb.Duration `protobuf:"bytes,4,opt,name=min_interval,json=minInterval,proto3" json:"min_interval,omitempty"`
+	Metadata      map[string]string    `protobuf:"bytes,5,rep,name=metadata,proto3" json:"metadata,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AcquireRequest) Reset() {
+	*x = AcquireRequest{}
+	mi := &file_lock_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AcquireRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AcquireRequest) ProtoMessage() {}
+
+func (x *AcquireRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_lock_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AcquireRequest.ProtoReflect.Descriptor instead.
+func (*AcquireRequest) Descriptor() ([]byte, []int) {
+	return file_lock_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *AcquireRequest) GetJob() string {
+	if x != nil {
+		return x.Job
+	}
+	return ""
+}
+
+func (x *AcquireRequest) GetClient() string {
+	if x != nil {
+		return x.Client
+	}
+	return ""
+}
+
+func (x *AcquireRequest) GetTtl() *durationpb.Duration {
+	if x != nil {
+		return x.Ttl
+	}
+	return nil
+}
+
+func (x *AcquireRequest) GetMinInterval() *durationpb.Duration {
+	if x != nil {
+		return x.MinInterval
+	}
+	return nil
+}
+
+func (x *AcquireRequest) GetMetadata() map[string]string {
+	if x != nil {
+		return x.Metadata
+	}
+	return nil
+}
+
+type RenewRequest struct {
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	Job    string                 `protobuf:"bytes,1,opt,name=job,proto3" json:"job,omitempty"`
+	Client string                 `protobuf:"bytes,2,opt,name=client,proto3" json:"client,omitempty"`
+	// Unset uses the job's default
+	Ttl           *durationpb.Duration `protobuf:"bytes,3,opt,name=ttl,proto3" json:"ttl,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RenewRequest) Reset() {
+	*x = RenewRequest{}
+	mi := &file_lock_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RenewRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RenewRequest) ProtoMessage() {}
+
+func (x *RenewRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_lock_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RenewRequest.ProtoReflect.Descriptor instead.
+func (*RenewRequest) Descriptor() ([]byte, []int) {
+	return file_lock_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *RenewRequest) GetJob() string {
+	if x != nil {
+		return x.Job
+	}
+	return ""
+}
+
+func (x *RenewRequest) GetClient() string {
+	if x != nil {
+		return x.Client
+	}
+	return ""
+}
+
+func (x *RenewRequest) GetTtl() *durationpb.Duration {
+	if x != nil {
+		return x.Ttl
+	}
+	return nil
+}
+
+type AcquireResponse struct {
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	Success bool                   `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`
+	// The same codes as the HTTP API, such as acquired, renewed or
+	// held_by_another
+	Code    string `protobuf:"bytes,2,opt,name=code,proto3" json:"code,omitempty"`
+	Message string `protobuf:"bytes,3,opt,name=message,proto3" json:"message,omitempty"`
+	// The lock after the request, or who holds it when refused
+	Lock *Lock `protobuf:"bytes,4,opt,name=lock,proto3" json:"lock,omitempty"`
+	// Set when the lock was taken over from a client that let it lapse
+	PreviousHolder string `protobuf:"bytes,5,opt,name=previous_holder,json=previousHolder,proto3" json:"previous_holder,omitempty"`
+	// When a refused client could expect to get the lock, if the holder doesn't
+	// renew; unset when retrying won't help
+	AvailableAt *timestamppb.Timestamp `protobuf:"bytes,6,opt,name=available_at,json=availableAt,proto3" json:"available_at,omitempty"`
+	// Set when refused with code ran_recently
+	NextRunAt     *timestamppb.Timestamp `protobuf:"bytes,7,opt,name=next_run_at,json=nextRunAt,proto3" json:"next_run_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AcquireResponse) Reset() {
+	*x = AcquireResponse{}
+	mi := &file_lock_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AcquireResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AcquireResponse) ProtoMessage() {}
+
+func (x *AcquireResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_lock_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AcquireResponse.ProtoReflect.Descriptor instead.
+func (*AcquireResponse) Descriptor() ([]byte, []int) {
+	return file_lock_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *AcquireResponse) GetSuccess() bool {
+	if x != nil {
+		return x.Success
+	}
+	return false
+}
+
+func (x *AcquireResponse) GetCode() string {
+	if x != nil {
+		return x.Code
+	}
+	return ""
+}
+
+func (x *AcquireResponse) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *AcquireResponse) GetLock() *Lock {
+	if x != nil {
+		return x.Lock
+	}
+	return nil
+}
+
+func (x *AcquireResponse) GetPreviousHolder() string {
+	if x != nil {
+		return x.PreviousHolder
+	}
+	return ""
+}
+
+func (x *AcquireResponse) GetAvailableAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.AvailableAt
+	}
+	return nil
+}
+
+func (x *AcquireResponse) GetNextRunAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.NextRunAt
+	}
+	return nil
+}
+
+type ReleaseRequest struct {
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	Job    string                 `protobuf:"bytes,1,opt,name=job,proto3" json:"job,omitempty"`
+	Client string                 `protobuf:"bytes,2,opt,name=client,proto3" json:"client,omitempty"`
+	// How the run went; exit_code alone implies success when it's zero
+	Success  *bool  `protobuf:"varint,3,opt,name=success,proto3,oneof" json:"success,omitempty"`
+	ExitCode *int32 `protobuf:"varint,4,opt,name=exit_code,json=exitCode,proto3,oneof" json:"exit_code,omitempty"`
+	// Requires success or exit_code
+	Message       string `protobuf:"bytes,5,opt,name=message,proto3" json:"message,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ReleaseRequest) Reset() {
+	*x = ReleaseRequest{}
+	mi := &file_lock_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ReleaseRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ReleaseRequest) ProtoMessage() {}
+
+func (x *ReleaseRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_lock_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ReleaseRequest.ProtoReflect.Descriptor instead.
+func (*ReleaseRequest) Descriptor() ([]byte, []int) {
+	return file_lock_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *ReleaseRequest) GetJob() string {
+	if x != nil {
+		return x.Job
+	}
+	return ""
+}
+
+func (x *ReleaseRequest) GetClient() string {
+	if x != nil {
+		return x.Client
+	}
+	return ""
+}
+
+func (x *ReleaseRequest) GetSuccess() bool {
+	if x != nil && x.Success != nil {
+		return *x.Success
+	}
+	return false
+}
+
+func (x *ReleaseRequest) GetExitCode() int32 {
+	if x != nil && x.ExitCode != nil {
+		return *x.ExitCode
+	}
+	return 0
+}
+
+func (x *ReleaseRequest) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+type ReleaseResponse struct {
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	Success bool                   `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`
+	Code    string                 `protobuf:"bytes,2,opt,name=code,proto3" json:"code,omitempty"`
+	Message string                 `protobuf:"bytes,3,opt,name=message,proto3" json:"message,omitempty"`
+	HeldFor *durationpb.Duration   `protobuf:"bytes,4,opt,name=held_for,json=heldFor,proto3" json:"held_for,omitempty"`
+	// Set while nobody may acquire the lock after this release
+	CooldownUntil *timestamppb.Timestamp `protobuf:"bytes,5,opt,name=cooldown_until,json=cooldownUntil,proto3" json:"cooldown_until,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ReleaseResponse) Reset() {
+	*x = ReleaseResponse{}
+	mi := &file_lock_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ReleaseResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ReleaseResponse) ProtoMessage() {}
+
+func (x *ReleaseResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_lock_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ReleaseResponse.ProtoReflect.Descriptor instead.
+func (*ReleaseResponse) Descriptor() ([]byte, []int) {
+	return file_lock_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *ReleaseResponse) GetSuccess() bool {
+	if x != nil {
+		return x.Success
+	}
+	return false
+}
+
+func (x *ReleaseResponse) GetCode() string {
+	if x != nil {
+		return x.Code
+	}
+	return ""
+}
+
+func (x *ReleaseResponse) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *ReleaseResponse) GetHeldFor() *durationpb.Duration {
+	if x != nil {
+		return x.HeldFor
+	}
+	return nil
+}
+
+func (x *ReleaseResponse) GetCooldownUntil() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CooldownUntil
+	}
+	return nil
+}
+
+type StatusRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Job           string                 `protobuf:"bytes,1,opt,name=job,proto3" json:"job,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StatusRequest) Reset() {
+	*x = StatusRequest{}
+	mi := &file_lock_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StatusRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StatusRequest) ProtoMessage() {}
+
+func (x *StatusRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_lock_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StatusRequest.ProtoReflect.Descriptor instead.
+func (*StatusRequest) Descriptor() ([]byte, []int) {
+	return file_lock_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *StatusRequest) GetJob() string {
+	if x != nil {
+		return x.Job
+	}
+	return ""
+}
+
+type ListRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListRequest) Reset() {
+	*x = ListRequest{}
+	mi := &file_lock_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListRequest) ProtoMessage() {}
+
+func (x *ListRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_lock_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListRequest.ProtoReflect.Descriptor instead.
+func (*ListRequest) Descriptor() ([]byte, []int) {
+	return file_lock_proto_rawDescGZIP(), []int{6}
+}
+
+type ListResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Locks         []*Lock                `protobuf:"bytes,1,rep,name=locks,proto3" json:"locks,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListResponse) Reset() {
+	*x = ListResponse{}
+	mi := &file_lock_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListResponse) ProtoMessage() {}
+
+func (x *ListResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_lock_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListResponse.ProtoReflect.Descriptor instead.
+func (*ListResponse) Descriptor() ([]byte, []int) {
+	return file_lock_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *ListResponse) GetLocks() []*Lock {
+	if x != nil {
+		return x.Locks
+	}
+	return nil
+}
+
+type WatchRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Jobs to watch, including any paths beneath them; empty watches every job
+	Jobs          []string `protobuf:"bytes,1,rep,name=jobs,proto3" json:"jobs,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WatchRequest) Reset() {
+	*x = WatchRequest{}
+	mi := &file_lock_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WatchRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchRequest) ProtoMessage() {}
+
+func (x *WatchRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_lock_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchRequest.ProtoReflect.Descriptor instead.
+func (*WatchRequest) Descriptor() ([]byte, []int) {
+	return file_lock_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *WatchRequest) GetJobs() []string {
+	if x != nil {
+		return x.Jobs
+	}
+	return nil
+}
+
+type Lock struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Job   string                 `protobuf:"bytes,1,opt,name=job,proto3" json:"job,omitempty"`
+	// Empty when nobody holds the lock
+	Holder     string                 `protobuf:"bytes,2,opt,name=holder,proto3" json:"holder,omitempty"`
+	AcquiredAt *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=acquired_at,json=acquiredAt,proto3" json:"acquired_at,omitempty"`
+	ExpiresAt  *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=expires_at,json=expiresAt,proto3" json:"expires_at,omitempty"`
+	GraceUntil *timestamppb.Timestamp `protobuf:"bytes,5,opt,name=grace_until,json=graceUntil,proto3" json:"grace_until,omitempty"`
+	// Also true when nobody holds the lock
+	Expired  bool              `protobuf:"varint,6,opt,name=expired,proto3" json:"expired,omitempty"`
+	InGrace  bool              `protobuf:"varint,7,opt,name=in_grace,json=inGrace,proto3" json:"in_grace,omitempty"`
+	Metadata map[string]string `protobuf:"bytes,8,rep,name=metadata,proto3" json:"metadata,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	// The ancestor or descendant path lock that would block acquiring this one
+	BlockedBy       string                 `protobuf:"bytes,9,opt,name=blocked_by,json=blockedBy,proto3" json:"blocked_by,omitempty"`
+	BlockedByHolder string                 `protobuf:"bytes,10,opt,name=blocked_by_holder,json=blockedByHolder,proto3" json:"blocked_by_holder,omitempty"`
+	MaxHoldUntil    *timestamppb.Timestamp `protobuf:"bytes,11,opt,name=max_hold_until,json=maxHoldUntil,proto3" json:"max_hold_until,omitempty"`
+	CooldownUntil   *timestamppb.Timestamp `protobuf:"bytes,12,opt,name=cooldown_until,json=cooldownUntil,proto3" json:"cooldown_until,omitempty"`
+	LastSuccessAt   *timestamppb.Timestamp `protobuf:"bytes,13,opt,name=last_success_at,json=lastSuccessAt,proto3" json:"last_success_at,omitempty"`
+	LastSuccessBy   string                 `protobuf:"bytes,14,opt,name=last_success_by,json=lastSuccessBy,proto3" json:"last_success_by,omitempty"`
+	LastFailureAt   *timestamppb.Timestamp `protobuf:"bytes,15,opt,name=last_failure_at,json=lastFailureAt,proto3" json:"last_failure_at,omitempty"`
+	LastFailureBy   string                 `protobuf:"bytes,16,opt,name=last_failure_by,json=lastFailureBy,proto3" json:"last_failure_by,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *Lock) Reset() {
+	*x = Lock{}
+	mi := &file_lock_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Lock) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Lock) ProtoMessage() {}
+
+func (x *Lock) ProtoReflect() protoreflect.Message {
+	mi := &file_lock_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Lock.ProtoReflect.Descriptor instead.
+func (*Lock) Descriptor() ([]byte, []int) {
+	return file_lock_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *Lock) GetJob() string {
+	if x != nil {
+		return x.Job
+	}
+	return ""
+}
+
+func (x *Lock) GetHolder() string {
+	if x != nil {
+		return x.Holder
+	}
+	return ""
+}
+
+func (x *Lock) GetAcquiredAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.AcquiredAt
+	}
+	return nil
+}
+
+func (x *Lock) GetExpiresAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.ExpiresAt
+	}
+	return nil
+}
+
+func (x *Lock) GetGraceUntil() *timestamppb.Timestamp {
+	if x != nil {
+		return x.GraceUntil
+	}
+	return nil
+}
+
+func (x *Lock) GetExpired() bool {
+	if x != nil {
+		return x.Expired
+	}
+	return false
+}
+
+func (x *Lock) GetInGrace() bool {
+	if x != nil {
+		return x.InGrace
+	}
+	return false
+}
+
+func (x *Lock) GetMetadata() map[string]string {
+	if x != nil {
+		return x.Metadata
+	}
+	return nil
+}
+
+func (x *Lock) GetBlockedBy() string {
+	if x != nil {
+		return x.BlockedBy
+	}
+	return ""
+}
+
+func (x *Lock) GetBlockedByHolder() string {
+	if x != nil {
+		return x.BlockedByHolder
+	}
+	return ""
+}
+
+func (x *Lock) GetMaxHoldUntil() *timestamppb.Timestamp {
+	if x != nil {
+		return x.MaxHoldUntil
+	}
+	return nil
+}
+
+func (x *Lock) GetCooldownUntil() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CooldownUntil
+	}
+	return nil
+}
+
+func (x *Lock) GetLastSuccessAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.LastSuccessAt
+	}
+	return nil
+}
+
+func (x *Lock) GetLastSuccessBy() string {
+	if x != nil {
+		return x.LastSuccessBy
+	}
+	return ""
+}
+
+func (x *Lock) GetLastFailureAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.LastFailureAt
+	}
+	return nil
+}
+
+func (x *Lock) GetLastFailureBy() string {
+	if x != nil {
+		return x.LastFailureBy
+	}
+	return ""
+}
+
+type Event struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Time  *timestamppb.Timestamp `protobuf:"bytes,1,opt,name=time,proto3" json:"time,omitempty"`
+	Job   string                 `protobuf:"bytes,2,opt,name=job,proto3" json:"job,omitempty"`
+	// acquired, renewed, released, expired, grace_ended or conflict
+	Type          string `protobuf:"bytes,3,opt,name=type,proto3" json:"type,omitempty"`
+	Client        string `protobuf:"bytes,4,opt,name=client,proto3" json:"client,omitempty"`
+	Holder        string `protobuf:"bytes,5,opt,name=holder,proto3" json:"holder,omitempty"`
+	Message       string `protobuf:"bytes,6,opt,name=message,proto3" json:"message,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Event) Reset() {
+	*x = Event{}
+	mi := &file_lock_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Event) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Event) ProtoMessage() {}
+
+func (x *Event) ProtoReflect() protoreflect.Message {
+	mi := &file_lock_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Event.ProtoReflect.Descriptor instead.
+func (*Event) Descriptor() ([]byte, []int) {
+	return file_lock_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *Event) GetTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Time
+	}
+	return nil
+}
+
+func (x *Event) GetJob() string {
+	if x != nil {
+		return x.Job
+	}
+	return ""
+}
+
+func (x *Event) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *Event) GetClient() string {
+	if x != nil {
+		return x.Client
+	}
+	return ""
+}
+
+func (x *Event) GetHolder() string {
+	if x != nil {
+		return x.Holder
+	}
+	return ""
+}
+
+func (x *Event) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+var File_lock_proto protoreflect.FileDescriptor
+
+const file_lock_proto_rawDesc = "" +
+	"\n" +
+	"\n" +
+	"lock.proto\x12\n" +
+	"foolock.v1\x1a\x1egoogle/protobuf/duration.proto\x1a\x1fgoogle/protobuf/timestamp.proto\"\xa8\x02\n" +
+	"\x0eAcquireRequest\x12\x10\n" +
+	"\x03job\x18\x01 \x01(\tR\x03job\x12\x16\n" +
+	"\x06client\x18\x02 \x01(\tR\x06client\x12+\n" +
+	"\x03ttl\x18\x03 \x01(\v2\x19.google.protobuf.DurationR\x03ttl\x12<\n" +
+	"\fmin_interval\x18\x04 \x01(\v2\x19.google.protobuf.DurationR\vminInterval\x12D\n" +
+	"\bmetadata\x18\x05 \x03(\v2(.foolock.v1.AcquireRequest.MetadataEntryR\bmetadata\x1a;\n" +
+	"\rMetadataEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"e\n" +
+	"\fRenewRequest\x12\x10\n" +
+	"\x03job\x18\x01 \x01(\tR\x03job\x12\x16\n" +
+	"\x06client\x18\x02 \x01(\tR\x06client\x12+\n" +
+	"\x03ttl\x18\x03 \x01(\v2\x19.google.protobuf.DurationR\x03ttl\"\xa3\x02\n" +
+	"\x0fAcquireResponse\x12\x18\n" +
+	"\asuccess\x18\x01 \x01(\bR\asuccess\x12\x12\n" +
+	"\x04code\x18\x02 \x01(\tR\x04code\x12\x18\n" +
+	"\amessage\x18\x03 \x01(\tR\amessage\x12$\n" +
+	"\x04lock\x18\x04 \x01(\v2\x10.foolock.v1.LockR\x04lock\x12'\n" +
+	"\x0fprevious_holder\x18\x05 \x01(\tR\x0epreviousHolder\x12=\n" +
+	"\favailable_at\x18\x06 \x01(\v2\x1a.google.protobuf.TimestampR\vavailableAt\x12:\n" +
+	"\vnext_run_at\x18\a \x01(\v2\x1a.google.protobuf.TimestampR\tnextRunAt\"\xaf\x01\n" +
+	"\x0eReleaseRequest\x12\x10\n" +
+	"\x03job\x18\x01 \x01(\tR\x03job\x12\x16\n" +
+	"\x06client\x18\x02 \x01(\tR\x06client\x12\x1d\n" +
+	"\asuccess\x18\x03 \x01(\bH\x00R\asuccess\x88\x01\x01\x12 \n" +
+	"\texit_code\x18\x04 \x01(\x05H\x01R\bexitCode\x88\x01\x01\x12\x18\n" +
+	"\amessage\x18\x05 \x01(\tR\amessageB\n" +
+	"\n" +
+	"\b_successB\f\n" +
+	"\n" +
+	"_exit_code\"\xd2\x01\n" +
+	"\x0fReleaseResponse\x12\x18\n" +
+	"\asuccess\x18\x01 \x01(\bR\asuccess\x12\x12\n" +
+	"\x04code\x18\x02 \x01(\tR\x04code\x12\x18\n" +
+	"\amessage\x18\x03 \x01(\tR\amessage\x124\n" +
+	"\bheld_for\x18\x04 \x01(\v2\x19.google.protobuf.DurationR\aheldFor\x12A\n" +
+	"\x0ecooldown_until\x18\x05 \x01(\v2\x1a.google.protobuf.TimestampR\rcooldownUntil\"!\n" +
+	"\rStatusRequest\x12\x10\n" +
+	"\x03job\x18\x01 \x01(\tR\x03job\"\r\n" +
+	"\vListRequest\"6\n" +
+	"\fListResponse\x12&\n" +
+	"\x05locks\x18\x01 \x03(\v2\x10.foolock.v1.LockR\x05locks\"\"\n" +
+	"\fWatchRequest\x12\x12\n" +
+	"\x04jobs\x18\x01 \x03(\tR\x04jobs\"\xbb\x06\n" +
+	"\x04Lock\x12\x10\n" +
+	"\x03job\x18\x01 \x01(\tR\x03job\x12\x16\n" +
+	"\x06holder\x18\x02 \x01(\tR\x06holder\x12;\n" +
+	"\vacquired_at\x18\x03 \x01(\v2\x1a.google.protobuf.TimestampR\n" +
+	"acquiredAt\x129\n" +
+	"\n" +
+	"expires_at\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\texpiresAt\x12;\n" +
+	"\vgrace_until\x18\x05 \x01(\v2\x1a.google.protobuf.TimestampR\n" +
+	"graceUntil\x12\x18\n" +
+	"\aexpired\x18\x06 \x01(\bR\aexpired\x12\x19\n" +
+	"\bin_grace\x18\a \x01(\bR\ainGrace\x12:\n" +
+	"\bmetadata\x18\b \x03(\v2\x1e.foolock.v1.Lock.MetadataEntryR\bmetadata\x12\x1d\n" +
+	"\n" +
+	"blocked_by\x18\t \x01(\tR\tblockedBy\x12*\n" +
+	"\x11blocked_by_holder\x18\n" +
+	" \x01(\tR\x0fblockedByHolder\x12@\n" +
+	"\x0emax_hold_until\x18\v \x01(\v2\x1a.google.protobuf.TimestampR\fmaxHoldUntil\x12A\n" +
+	"\x0ecooldown_until\x18\f \x01(\v2\x1a.google.protobuf.TimestampR\rcooldownUntil\x12B\n" +
+	"\x0flast_success_at\x18\r \x01(\v2\x1a.google.protobuf.TimestampR\rlastSuccessAt\x12&\n" +
+	"\x0flast_success_by\x18\x0e \x01(\tR\rlastSuccessBy\x12B\n" +
+	"\x0flast_failure_at\x18\x0f \x01(\v2\x1a.google.protobuf.TimestampR\rlastFailureAt\x12&\n" +
+	"\x0flast_failure_by\x18\x10 \x01(\tR\rlastFailureBy\x1a;\n" +
+	"\rMetadataEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\xa7\x01\n" +
+	"\x05Event\x12.\n" +
+	"\x04time\x18\x01 \x01(\v2\x1a.google.protobuf.TimestampR\x04time\x12\x10\n" +
+	"\x03job\x18\x02 \x01(\tR\x03job\x12\x12\n" +
+	"\x04type\x18\x03 \x01(\tR\x04type\x12\x16\n" +
+	"\x06client\x18\x04 \x01(\tR\x06client\x12\x16\n" +
+	"\x06holder\x18\x05 \x01(\tR\x06holder\x12\x18\n" +
+	"\amessage\x18\x06 \x01(\tR\amessage2\xf9\x02\n" +
+	"\x05Locks\x12B\n" +
+	"\aAcquire\x12\x1a.foolock.v1.AcquireRequest\x1a\x1b.foolock.v1.AcquireResponse\x12>\n" +
+	"\x05Renew\x12\x18.foolock.v1.RenewRequest\x1a\x1b.foolock.v1.AcquireResponse\x12B\n" +
+	"\aRelease\x12\x1a.foolock.v1.ReleaseRequest\x1a\x1b.foolock.v1.ReleaseResponse\x125\n" +
+	"\x06Status\x12\x19.foolock.v1.StatusRequest\x1a\x10.foolock.v1.Lock\x129\n" +
+	"\x04List\x12\x17.foolock.v1.ListRequest\x1a\x18.foolock.v1.ListResponse\x126\n" +
+	"\x05Watch\x12\x18.foolock.v1.WatchRequest\x1a\x11.foolock.v1.Event0\x01B%Z#github.com/shadyabhi/foolock/lockpbb\x06proto3"
+
+var (
+	file_lock_proto_rawDescOnce sync.Once
+	file_lock_proto_rawDescData []byte
+)
+
+func file_lock_proto_rawDescGZIP() []byte {
+	file_lock_proto_rawDescOnce.Do(func() {
+		file_lock_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_lock_proto_rawDesc), len(file_lock_proto_rawDesc)))
+	})
+	return file_lock_proto_rawDescData
+}
+
+var file_lock_proto_msgTypes = make([]protoimpl.MessageInfo, 13)
+var file_lock_proto_goTypes = []any{
+	(*AcquireRequest)(nil),        // 0: foolock.v1.AcquireRequest
+	(*RenewRequest)(nil),          // 1: foolock.v1.RenewRequest
+	(*AcquireResponse)(nil),       // 2: foolock.v1.AcquireResponse
+	(*ReleaseRequest)(nil),        // 3: foolock.v1.ReleaseRequest
+	(*ReleaseResponse)(nil),       // 4: foolock.v1.ReleaseResponse
+	(*StatusRequest)(nil),         // 5: foolock.v1.StatusRequest
+	(*ListRequest)(nil),           // 6: foolock.v1.ListRequest
+	(*ListResponse)(nil),          // 7: foolock.v1.ListResponse
+	(*WatchRequest)(nil),          // 8: foolock.v1.WatchRequest
+	(*Lock)(nil),                  // 9: foolock.v1.Lock
+	(*Event)(nil),                 // 10: foolock.v1.Event
+	nil,                           // 11: foolock.v1.AcquireRequest.MetadataEntry
+	nil,                           // 12: foolock.v1.Lock.MetadataEntry
+	(*durationpb.Duration)(nil),   // 13: google.protobuf.Duration
+	(*timestamppb.Timestamp)(nil), // 14: google.protobuf.Timestamp
+}
+var file_lock_proto_depIdxs = []int32{
+	13, // 0: foolock.v1.AcquireRequest.ttl:type_name -> google.protobuf.Duration
+	13, // 1: foolock.v1.AcquireRequest.min_interval:type_name -> google.protobuf.Duration
+	11, // 2: foolock.v1.AcquireRequest.metadata:type_name -> foolock.v1.AcquireRequest.MetadataEntry
+	13, // 3: foolock.v1.RenewRequest.ttl:type_name -> google.protobuf.Duration
+	9,  // 4: foolock.v1.AcquireResponse.lock:type_name -> foolock.v1.Lock
+	14, // 5: foolock.v1.AcquireResponse.available_at:type_name -> google.protobuf.Timestamp
+	14, // 6: foolock.v1.AcquireResponse.next_run_at:type_name -> google.protobuf.Timestamp
+	13, // 7: foolock.v1.ReleaseResponse.held_for:type_name -> google.protobuf.Duration
+	14, // 8: foolock.v1.ReleaseResponse.cooldown_until:type_name -> google.protobuf.Timestamp
+	9,  // 9: foolock.v1.ListResponse.locks:type_name -> foolock.v1.Lock
+	14, // 10: foolock.v1.Lock.acquired_at:type_name -> google.protobuf.Timestamp
+	14, // 11: foolock.v1.Lock.expires_at:type_name -> google.protobuf.Timestamp
+	14, // 12: foolock.v1.Lock.grace_until:type_name -> google.protobuf.Timestamp
+	12, // 13: foolock.v1.Lock.metadata:type_name -> foolock.v1.Lock.MetadataEntry
+	14, // 14: foolock.v1.Lock.max_hold_until:type_name -> google.protobuf.Timestamp
+	14, // 15: foolock.v1.Lock.cooldown_until:type_name -> google.protobuf.Timestamp
+	14, // 16: foolock.v1.Lock.last_success_at:type_name -> google.protobuf.Timestamp
+	14, // 17: foolock.v1.Lock.last_failure_at:type_name -> google.protobuf.Timestamp
+	14, // 18: foolock.v1.Event.time:type_name -> google.protobuf.Timestamp
+	0,  // 19: foolock.v1.Locks.Acquire:input_type -> foolock.v1.AcquireRequest
+	1,  // 20: foolock.v1.Locks.Renew:input_type -> foolock.v1.RenewRequest
+	3,  // 21: foolock.v1.Locks.Release:input_type -> foolock.v1.ReleaseRequest
+	5,  // 22: foolock.v1.Locks.Status:input_type -> foolock.v1.StatusRequest
+	6,  // 23: foolock.v1.Locks.List:input_type -> foolock.v1.ListRequest
+	8,  // 24: foolock.v1.Locks.Watch:input_type -> foolock.v1.WatchRequest
+	2,  // 25: foolock.v1.Locks.Acquire:output_type -> foolock.v1.AcquireResponse
+	2,  // 26: foolock.v1.Locks.Renew:output_type -> foolock.v1.AcquireResponse
+	4,  // 27: foolock.v1.Locks.Release:output_type -> foolock.v1.ReleaseResponse
+	9,  // 28: foolock.v1.Locks.Status:output_type -> foolock.v1.Lock
+	7,  // 29: foolock.v1.Locks.List:output_type -> foolock.v1.ListResponse
+	10, // 30: foolock.v1.Locks.Watch:output_type -> foolock.v1.Event
+	25, // [25:31] is the sub-list for method output_type
+	19, // [19:25] is the sub-list for method input_type
+	19, // [19:19] is the sub-list for extension type_name
+	19, // [19:19] is the sub-list for extension extendee
+	0,  // [0:19] is the sub-list for field type_name
+}
+
+func init() { file_lock_proto_init() }
+func file_lock_proto_init() {
+	if File_lock_proto != nil {
+		return
+	}
+	file_lock_proto_msgTypes[3].OneofWrappers = []any{}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_lock_proto_rawDesc), len(file_lock_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   13,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_lock_proto_goTypes,
+		DependencyIndexes: file_lock_proto_depIdxs,
+		MessageInfos:      file_lock_proto_msgTypes,
+	}.Build()
+	File_lock_proto = out.File
+	file_lock_proto_goTypes = nil
+	file_lock_proto_depIdxs = nil
+}
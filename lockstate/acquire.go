@@ -1,6 +1,7 @@
 package lockstate
 
 import (
+	"maps"
 	"time"
 
 	"github.com/shadyabhi/foolock/lockstate/msg"
@@ -8,33 +9,101 @@ import (
 
 type AcquireResult struct {
 	Success bool
+	Code    Code
 	Job     string
 	Holder  string
 	Message string
 
-	ExpiresAt  time.Time
-	GraceUntil time.Time
+	// PreviousHolder is set when the lock was taken over from a client that
+	// let it lapse
+	PreviousHolder string
+
+	// BlockedBy names the ancestor or descendant job that caused a conflict
+	BlockedBy string
+
+	// AcquiredAt is when the holder's current tenure began
+	AcquiredAt    time.Time
+	ExpiresAt     time.Time
+	GraceUntil    time.Time
+	MaxHoldUntil  time.Time
+	CooldownUntil time.Time
+
+	// Set when refused because the job ran within the requested interval
+	LastSuccessAt time.Time
+	LastSuccessBy string
+	NextRunAt     time.Time
+
+	// Metadata is what the holder attached to the lock, if anything
+	Metadata map[string]string
+	// Session is the session the lock is bound to, if any
+	Session string
+
+	// AvailableAt is the earliest time a refused client could expect to get
+	// the lock, assuming the holder doesn't renew; zero when retrying won't
+	// help
+	AvailableAt time.Time
 }
 
-func (s *State) Acquire(client string, ttl time.Duration) AcquireResult {
+func (s *State) Acquire(client string, ttl time.Duration, opts ...AcquireOption) AcquireResult {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	now := time.Now()
+	ttl, result, ok := s.checkPolicy(client, ttl)
+	if !ok {
+		return result
+	}
+	// Sessions belong to the manager, so only Manager.Acquire binds to one
+	o := newAcquireOptions(opts)
+	o.session = ""
+	return s.acquire(client, time.Now(), ttl, o)
+}
+
+// acquire grants or renews the lock for an already validated ttl; the caller
+// must hold s.mu
+func (s *State) acquire(client string, now time.Time, ttl time.Duration, o acquireOptions) AcquireResult {
+	s.observe(now)
+	if result, blocked := s.checkRenewing(client, o); blocked {
+		s.recordConflict(client, result, now)
+		return result
+	}
+	if result, blocked := s.checkAvailable(client, now, o); blocked {
+		s.recordConflict(client, result, now)
+		return result
+	}
+
+	var result AcquireResult
+	if s.isCurrentHolderRenewing(client) && !s.isMaxHoldExceeded(now) {
+		result = s.respRenewLock(now, ttl)
+	} else {
+		result = s.acquireLock(client, now, ttl)
+	}
+	s.setMetadata(result.Code, o.metadata)
+	result.Metadata = maps.Clone(s.Metadata)
+	// Renewing outside the session takes the lock out of it
+	s.session = o.session
+	result.Session = s.session
+	return result
+}
 
+// checkBlocked reports whether client is currently prevented from acquiring
+// the lock, returning the conflict result if so; the caller must hold s.mu
+func (s *State) checkBlocked(client string, now time.Time) (AcquireResult, bool) {
 	if s.isCurrentHolderRenewing(client) {
-		return s.respRenewLock(now, ttl)
+		if s.isMaxHoldExceeded(now) && !s.isLapsed(now) {
+			return s.respMaxHoldExceeded(now), true
+		}
+		return AcquireResult{}, false
 	}
 
 	if s.isHeldByAnother(now) {
-		return s.respAlreadyLocked()
+		return s.respAlreadyLocked(), true
 	}
 
 	if s.isInGracePeriod(now) {
-		return s.respActiveGracePeriod()
+		return s.respActiveGracePeriod(), true
 	}
 
-	return s.acquireLock(client, now, ttl)
+	return AcquireResult{}, false
 }
 
 func (s *State) isCurrentHolderRenewing(client string) bool {
@@ -42,14 +111,25 @@ func (s *State) isCurrentHolderRenewing(client string) bool {
 }
 
 func (s *State) respRenewLock(now time.Time, ttl time.Duration) AcquireResult {
-	s.ExpiresAt = now.Add(ttl)
+	code := CodeRenewed
+	if !now.Before(s.ExpiresAt) {
+		code = CodeReclaimed
+	}
+	s.LastAcquiredAt = now
+	s.ExpiresAt = s.capExpiry(now.Add(ttl))
 	s.GraceUntil = s.ExpiresAt.Add(s.gracePeriod)
+	s.resetObserved()
+	s.record(Event{Time: now, Type: EventRenewed, Client: s.Holder, Holder: s.Holder})
 	return AcquireResult{
 		Success:   true,
+		Code:      code,
 		Job:       s.Job,
 		Holder:    s.Holder,
 		ExpiresAt: s.ExpiresAt,
 		Message:   msg.Renewed,
+
+		AcquiredAt:   s.AcquiredAt,
+		MaxHoldUntil: s.maxHoldUntil(),
 	}
 }
 
@@ -59,11 +139,17 @@ func (s *State) isHeldByAnother(now time.Time) bool {
 
 func (s *State) respAlreadyLocked() AcquireResult {
 	return AcquireResult{
-		Success:   false,
-		Job:       s.Job,
-		Holder:    s.Holder,
-		ExpiresAt: s.ExpiresAt,
-		Message:   msg.HeldByAnother,
+		Success:    false,
+		Code:       CodeHeldByAnother,
+		Job:        s.Job,
+		Holder:     s.Holder,
+		AcquiredAt: s.AcquiredAt,
+		ExpiresAt:  s.ExpiresAt,
+		GraceUntil: s.GraceUntil,
+		Message:    msg.HeldByAnother,
+		// The holder's grace period follows expiry, so others have to wait
+		// for that too
+		AvailableAt: s.GraceUntil,
 	}
 }
 
@@ -74,31 +160,52 @@ func (s *State) isInGracePeriod(now time.Time) bool {
 func (s *State) respActiveGracePeriod() AcquireResult {
 	return AcquireResult{
 		Success:    false,
+		Code:       CodeGraceActive,
 		Job:        s.Job,
 		Holder:     s.Holder,
+		AcquiredAt: s.AcquiredAt,
 		ExpiresAt:  s.ExpiresAt,
 		GraceUntil: s.GraceUntil,
 		Message:    msg.GracePeriodActive,
+
+		AvailableAt: s.GraceUntil,
 	}
 }
 
 func (s *State) acquireLock(client string, now time.Time, ttl time.Duration) AcquireResult {
 	previousHolder := s.Holder
+	if previousHolder != "" && previousHolder != client && !s.graceEndRecorded {
+		// Taken over the instant the lease ended, before observe saw the
+		// grace period out
+		s.recordAbandoned()
+	}
 	s.Holder = client
 	s.AcquiredAt = now
-	s.ExpiresAt = now.Add(ttl)
+	s.LastAcquiredAt = now
+	s.ExpiresAt = s.capExpiry(now.Add(ttl))
 	s.GraceUntil = s.ExpiresAt.Add(s.gracePeriod)
 
-	message := msg.Acquired
+	s.resetObserved()
+
+	code, message := CodeAcquired, msg.Acquired
+	if previousHolder == client {
+		previousHolder = ""
+	}
 	if previousHolder != "" {
-		message = msg.Acquired + " from " + previousHolder
+		code, message = CodeTakenOver, msg.Acquired+" from "+previousHolder
 	}
+	s.record(Event{Time: now, Type: EventAcquired, Client: client, Holder: client, Message: message})
 
 	return AcquireResult{
 		Success:   true,
+		Code:      code,
 		Job:       s.Job,
 		Holder:    s.Holder,
 		ExpiresAt: s.ExpiresAt,
 		Message:   message,
+
+		PreviousHolder: previousHolder,
+		AcquiredAt:     s.AcquiredAt,
+		MaxHoldUntil:   s.maxHoldUntil(),
 	}
 }
@@ -14,27 +14,33 @@ func TestAcquire(t *testing.T) {
 		client  string
 		ttl     time.Duration
 		success bool
+		code    Code
 		message string
 	}{
-		{"fresh lock", func(s *State) {}, "client1", time.Minute, true, msg.Acquired},
+		{"fresh lock", func(s *State) {}, "client1", time.Minute, true, CodeAcquired, msg.Acquired},
 		{"renew own lock", func(s *State) {
 			s.Holder = "client1"
 			s.ExpiresAt = time.Now().Add(time.Minute)
-		}, "client1", time.Minute, true, msg.Renewed},
+		}, "client1", time.Minute, true, CodeRenewed, msg.Renewed},
 		{"held by another", func(s *State) {
 			s.Holder = "client1"
 			s.ExpiresAt = time.Now().Add(time.Minute)
-		}, "client2", time.Minute, false, msg.HeldByAnother},
+		}, "client2", time.Minute, false, CodeHeldByAnother, msg.HeldByAnother},
 		{"in grace period", func(s *State) {
 			s.Holder = "client1"
 			s.ExpiresAt = time.Now().Add(-time.Second)
 			s.GraceUntil = time.Now().Add(time.Minute)
-		}, "client2", time.Minute, false, msg.GracePeriodActive},
+		}, "client2", time.Minute, false, CodeGraceActive, msg.GracePeriodActive},
+		{"reclaim in grace period", func(s *State) {
+			s.Holder = "client1"
+			s.ExpiresAt = time.Now().Add(-time.Second)
+			s.GraceUntil = time.Now().Add(time.Minute)
+		}, "client1", time.Minute, true, CodeReclaimed, msg.Renewed},
 		{"expired past grace", func(s *State) {
 			s.Holder = "client1"
 			s.ExpiresAt = time.Now().Add(-2 * time.Minute)
 			s.GraceUntil = time.Now().Add(-time.Minute)
-		}, "client2", time.Minute, true, msg.Acquired + " from client1"},
+		}, "client2", time.Minute, true, CodeTakenOver, msg.Acquired + " from client1"},
 	}
 
 	for _, tt := range tests {
@@ -45,9 +51,28 @@ func TestAcquire(t *testing.T) {
 			if result.Success != tt.success {
 				t.Errorf("Success = %v, want %v", result.Success, tt.success)
 			}
+			if result.Code != tt.code {
+				t.Errorf("Code = %q, want %q", result.Code, tt.code)
+			}
 			if result.Message != tt.message {
 				t.Errorf("Message = %q, want %q", result.Message, tt.message)
 			}
 		})
 	}
 }
+
+func TestAcquirePreviousHolder(t *testing.T) {
+	s := &State{ttl: 30 * time.Second}
+	s.Acquire("client1", time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+
+	result := s.Acquire("client2", time.Minute)
+	if result.Code != CodeTakenOver || result.PreviousHolder != "client1" {
+		t.Errorf("got %q from %q, want %q from client1", result.Code, result.PreviousHolder, CodeTakenOver)
+	}
+
+	result = s.Acquire("client2", time.Minute)
+	if result.PreviousHolder != "" {
+		t.Errorf("PreviousHolder = %q on renewal, want empty", result.PreviousHolder)
+	}
+}
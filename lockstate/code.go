@@ -0,0 +1,41 @@
+package lockstate
+
+// Code identifies the outcome of an acquire or release in a stable,
+// machine-readable form; Message stays the human-readable counterpart
+type Code string
+
+const (
+	// CodeAcquired means the lock was free and is now held by the client
+	CodeAcquired Code = "acquired"
+	// CodeRenewed means the holder extended a lease that had not expired
+	CodeRenewed Code = "renewed"
+	// CodeReclaimed means the holder renewed during its grace period
+	CodeReclaimed Code = "reclaimed"
+	// CodeTakenOver means the client acquired a lock whose previous holder
+	// let it lapse without releasing
+	CodeTakenOver Code = "taken_over"
+
+	CodeHeldByAnother      Code = "held_by_another"
+	CodeGraceActive        Code = "grace_active"
+	CodeRelatedPathHeld    Code = "related_path_held"
+	CodeCooldownActive     Code = "cooldown_active"
+	CodeRanRecently        Code = "ran_recently"
+	CodeMaxHoldExceeded    Code = "max_hold_exceeded"
+	CodeUnknownJob         Code = "unknown_job"
+	CodeClientNotPermitted Code = "client_not_permitted"
+	CodeTTLOutOfRange      Code = "ttl_out_of_range"
+	CodeDraining           Code = "draining"
+
+	CodeReleased  Code = "released"
+	CodeNotHolder Code = "not_holder"
+	// CodeNotHeld refuses a release of a lock nobody holds, or whose
+	// holder's lease and grace period have both run out
+	CodeNotHeld Code = "not_held"
+
+	CodeSessionCreated Code = "session_created"
+	CodeSessionRenewed Code = "session_renewed"
+	CodeSessionClosed  Code = "session_closed"
+	// CodeSessionNotFound means the session never existed, expired or was
+	// closed
+	CodeSessionNotFound Code = "session_not_found"
+)
@@ -0,0 +1,85 @@
+package lockstate
+
+import (
+	"time"
+
+	"github.com/shadyabhi/foolock/lockstate/msg"
+)
+
+// CooldownMode controls who is kept out during the cooldown after a release
+type CooldownMode int
+
+const (
+	// CooldownEveryone blocks every client, including the one that released
+	CooldownEveryone CooldownMode = iota
+	// CooldownOthers lets the releasing client re-acquire straight away
+	CooldownOthers
+)
+
+// WithCooldown keeps a lock unavailable for d after an explicit release
+func WithCooldown(d time.Duration) Option {
+	return func(m *Manager) {
+		m.cooldown = d
+	}
+}
+
+// WithCooldownMode sets who the cooldown applies to
+func WithCooldownMode(mode CooldownMode) Option {
+	return func(m *Manager) {
+		m.cooldownMode = mode
+	}
+}
+
+// startCooldown begins the cooldown after client released the lock; the
+// caller must hold s.mu
+func (s *State) startCooldown(client string, now time.Time) {
+	if s.cooldown <= 0 {
+		return
+	}
+	s.CooldownUntil = now.Add(s.cooldown)
+	s.releasedBy = client
+}
+
+// isInCooldown reports whether client must wait for the release cooldown
+func (s *State) isInCooldown(client string, now time.Time) bool {
+	if !now.Before(s.CooldownUntil) {
+		return false
+	}
+	return s.cooldownMode == CooldownEveryone || client != s.releasedBy
+}
+
+func (s *State) respCooldownActive() AcquireResult {
+	return AcquireResult{
+		Success:       false,
+		Code:          CodeCooldownActive,
+		Job:           s.Job,
+		Holder:        s.Holder,
+		CooldownUntil: s.CooldownUntil,
+		Message:       msg.CooldownActive,
+		AvailableAt:   s.CooldownUntil,
+	}
+}
+
+// checkAvailable is checkBlocked plus the release cooldown and minimum run
+// interval, which only apply to the job itself and not to its ancestors or
+// descendants; the caller must hold s.mu
+func (s *State) checkAvailable(client string, now time.Time, o acquireOptions) (AcquireResult, bool) {
+	if result, blocked := s.checkBlocked(client, now); blocked {
+		return result, true
+	}
+	if s.Holder == "" && s.isInCooldown(client, now) {
+		return s.respCooldownActive(), true
+	}
+	if !s.isCurrentHolderRenewing(client) && s.ranWithin(o.minInterval, now) {
+		return s.respRanRecently(o.minInterval), true
+	}
+	return AcquireResult{}, false
+}
+
+// activeCooldown returns the cooldown deadline while it is still running
+func (s *State) activeCooldown(now time.Time) time.Time {
+	if s.Holder != "" || !now.Before(s.CooldownUntil) {
+		return time.Time{}
+	}
+	return s.CooldownUntil
+}
@@ -0,0 +1,104 @@
+package lockstate
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shadyabhi/foolock/lockstate/msg"
+)
+
+func TestCooldown(t *testing.T) {
+	tests := []struct {
+		name    string
+		mode    CooldownMode
+		client  string
+		success bool
+	}{
+		{"everyone blocks other client", CooldownEveryone, "client2", false},
+		{"everyone blocks releasing client", CooldownEveryone, "client1", false},
+		{"others blocks other client", CooldownOthers, "client2", false},
+		{"others lets releasing client back", CooldownOthers, "client1", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := New(WithCooldown(time.Minute), WithCooldownMode(tt.mode))
+			m.Acquire("sync", "client1", time.Minute)
+
+			released := m.Release("sync", "client1")
+			if released.CooldownUntil.IsZero() {
+				t.Error("expected CooldownUntil on release")
+			}
+
+			result := m.Acquire("sync", tt.client, time.Minute)
+			if result.Success != tt.success {
+				t.Errorf("Success = %v, want %v", result.Success, tt.success)
+			}
+			if !tt.success {
+				if result.Message != msg.CooldownActive {
+					t.Errorf("Message = %q, want %q", result.Message, msg.CooldownActive)
+				}
+				if !result.CooldownUntil.Equal(released.CooldownUntil) {
+					t.Errorf("CooldownUntil = %s, want %s", result.CooldownUntil, released.CooldownUntil)
+				}
+			}
+		})
+	}
+}
+
+func TestCooldownExpires(t *testing.T) {
+	m := New(WithCooldown(10 * time.Millisecond))
+	m.Acquire("sync", "client1", time.Minute)
+	m.Release("sync", "client1")
+
+	if status := m.Status("sync"); status.CooldownUntil.IsZero() {
+		t.Error("expected status to report the cooldown")
+	}
+
+	time.Sleep(15 * time.Millisecond)
+
+	if status := m.Status("sync"); !status.CooldownUntil.IsZero() {
+		t.Errorf("CooldownUntil = %s, want zero after cooldown", status.CooldownUntil)
+	}
+	if result := m.Acquire("sync", "client2", time.Minute); !result.Success {
+		t.Errorf("expected success after cooldown, got %q", result.Message)
+	}
+}
+
+func TestCooldownOnlyAfterRelease(t *testing.T) {
+	m := New(WithCooldown(time.Minute), WithGracePeriod(time.Millisecond))
+	m.Acquire("sync", "client1", time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+
+	if result := m.Acquire("sync", "client2", time.Minute); !result.Success {
+		t.Errorf("expected expiry not to start a cooldown, got %q", result.Message)
+	}
+}
+
+func TestCooldownNotInherited(t *testing.T) {
+	m := New(WithCooldown(time.Minute))
+	m.Acquire("/photos", "client1", time.Minute)
+	m.Release("/photos", "client1")
+
+	if result := m.Acquire("/photos/2024", "client2", time.Minute); !result.Success {
+		t.Errorf("expected a parent's cooldown not to block its children, got %q", result.Message)
+	}
+}
+
+func TestCooldownPolicy(t *testing.T) {
+	m := New(WithPolicies(map[string]Policy{
+		"sync": {Cooldown: time.Minute, CooldownMode: CooldownOthers},
+	}))
+
+	m.Acquire("sync", "client1", time.Minute)
+	m.Release("sync", "client1")
+	m.Acquire("backup", "client1", time.Minute)
+	m.Release("backup", "client1")
+
+	if result := m.Acquire("sync", "client2", time.Minute); result.Message != msg.CooldownActive {
+		t.Errorf("sync Message = %q, want %q", result.Message, msg.CooldownActive)
+	}
+	if result := m.Acquire("backup", "client2", time.Minute); !result.Success {
+		t.Errorf("expected backup without cooldown policy to be free, got %q", result.Message)
+	}
+}
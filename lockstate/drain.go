@@ -0,0 +1,32 @@
+package lockstate
+
+import "github.com/shadyabhi/foolock/lockstate/msg"
+
+// SetDraining switches the manager in or out of draining mode. While
+// draining, only current holders may acquire, so they can keep renewing and
+// release cleanly while the server shuts down; everyone else is refused.
+func (m *Manager) SetDraining(draining bool) {
+	m.draining.Store(draining)
+}
+
+// Draining reports whether the manager is refusing new acquisitions
+func (m *Manager) Draining() bool {
+	return m.draining.Load()
+}
+
+// checkDraining refuses client unless it already holds the lock; the caller
+// must hold s.mu
+func (m *Manager) checkDraining(s *State, client string) (AcquireResult, bool) {
+	if !m.draining.Load() || s.isCurrentHolderRenewing(client) {
+		return AcquireResult{}, false
+	}
+	return AcquireResult{
+		Success:    false,
+		Code:       CodeDraining,
+		Job:        s.Job,
+		Holder:     s.Holder,
+		AcquiredAt: s.AcquiredAt,
+		ExpiresAt:  s.ExpiresAt,
+		Message:    msg.Draining,
+	}, true
+}
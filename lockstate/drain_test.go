@@ -0,0 +1,51 @@
+package lockstate
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDraining(t *testing.T) {
+	m := New()
+	m.Acquire("backup", "c1", time.Minute)
+	m.Acquire("sync", "c1", time.Minute)
+
+	m.SetDraining(true)
+	if !m.Draining() {
+		t.Fatal("Draining() = false after SetDraining(true)")
+	}
+
+	result := m.Acquire("photos", "c1", time.Minute)
+	if result.Success || result.Code != CodeDraining {
+		t.Errorf("new lock: Success = %v, Code = %q, want false, %q", result.Success, result.Code, CodeDraining)
+	}
+
+	result = m.Acquire("backup", "c1", time.Minute)
+	if !result.Success || result.Code != CodeRenewed {
+		t.Errorf("holders can still renew: Success = %v, Code = %q", result.Success, result.Code)
+	}
+
+	many := m.AcquireMany([]string{"backup", "photos"}, "c1", time.Minute)
+	if many.Success {
+		t.Error("expected AcquireMany with a new lock to fail")
+	}
+	if len(many.Conflicts) != 1 || many.Conflicts[0].Code != CodeDraining {
+		t.Errorf("Conflicts = %+v, want one %q conflict", many.Conflicts, CodeDraining)
+	}
+
+	if !m.AcquireMany([]string{"backup", "sync"}, "c1", time.Minute).Success {
+		t.Error("expected AcquireMany of held locks to succeed")
+	}
+	if !m.Release("backup", "c1").Success {
+		t.Error("holders can still release")
+	}
+
+	if code := m.Acquire("backup", "c1", time.Minute).Code; code != CodeDraining {
+		t.Errorf("released locks can't be taken again: Code = %q, want %q", code, CodeDraining)
+	}
+
+	m.SetDraining(false)
+	if !m.Acquire("photos", "c2", time.Minute).Success {
+		t.Error("expected acquire to succeed once draining stops")
+	}
+}
@@ -0,0 +1,161 @@
+package lockstate
+
+import (
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/shadyabhi/foolock/lockstate/msg"
+)
+
+// Job names containing a slash form a hierarchy: "/photos" is an ancestor of
+// "/photos/2024", and a lock on either conflicts with a lock on the other
+// held by a different client.
+
+// normalizeJob cleans path-like job names so "/photos/" and "/photos" refer
+// to the same lock; other names are returned unchanged
+func normalizeJob(job string) string {
+	if !strings.Contains(job, "/") {
+		return job
+	}
+	return path.Clean(job)
+}
+
+// isAncestor reports whether parent is a strict path prefix of child
+func isAncestor(parent, child string) bool {
+	prefix := strings.TrimSuffix(parent, "/") + "/"
+	return parent != child && strings.HasPrefix(child, prefix)
+}
+
+// Covers reports whether job is scope itself or a path beneath it, once both
+// are normalized, so a permission for "/photos" extends to "/photos/2024"
+func Covers(scope, job string) bool {
+	scope, job = normalizeJob(scope), normalizeJob(job)
+	return scope == job || isAncestor(scope, job)
+}
+
+// isRelated reports whether a and b are ancestor and descendant of each other
+func isRelated(a, b string) bool {
+	return isAncestor(a, b) || isAncestor(b, a)
+}
+
+// lockSet is a group of states locked together in canonical order: the
+// targets of an operation plus every existing ancestor or descendant of them
+type lockSet struct {
+	targets []*State
+	related []*State
+	all     []*State
+}
+
+// lockJobs creates the states for jobs if needed, collects their relatives
+// and locks all of them in sorted job order. A relative created while it
+// waits for a state lock would go unchecked, so if any state was created in
+// the meantime it starts over.
+func (m *Manager) lockJobs(jobs []string) *lockSet {
+	names := make([]string, 0, len(jobs))
+	for _, job := range jobs {
+		names = append(names, normalizeJob(job))
+	}
+	slices.Sort(names)
+	names = slices.Compact(names)
+
+	for {
+		ls, generation := m.collectJobs(names)
+		for _, s := range ls.all {
+			s.mu.Lock()
+		}
+		if m.generation.Load() == generation {
+			return ls
+		}
+		ls.unlock()
+	}
+}
+
+// collectJobs gathers the states for the sorted names, creating them if
+// needed, and their existing relatives, as of the returned generation
+func (m *Manager) collectJobs(names []string) (*lockSet, uint64) {
+	ls := &lockSet{}
+
+	m.mu.Lock()
+	for _, job := range names {
+		ls.targets = append(ls.targets, m.getOrCreateLockLocked(job))
+	}
+	for job, s := range m.locks {
+		if slices.Contains(names, job) {
+			continue
+		}
+		for _, target := range names {
+			if isRelated(job, target) {
+				ls.related = append(ls.related, s)
+				break
+			}
+		}
+	}
+	generation := m.generation.Load()
+	m.mu.Unlock()
+
+	ls.all = append(slices.Clone(ls.targets), ls.related...)
+	slices.SortFunc(ls.all, func(a, b *State) int {
+		return strings.Compare(a.Job, b.Job)
+	})
+	return ls, generation
+}
+
+func (ls *lockSet) unlock() {
+	for i := len(ls.all) - 1; i >= 0; i-- {
+		ls.all[i].mu.Unlock()
+	}
+}
+
+// blockingRelative returns the first ancestor or descendant of target that
+// client is not allowed to override
+func (ls *lockSet) blockingRelative(target *State, client string, now time.Time) (*State, bool) {
+	for _, r := range ls.related {
+		if !isRelated(r.Job, target.Job) {
+			continue
+		}
+		if _, blocked := r.checkBlocked(client, now); blocked {
+			return r, true
+		}
+	}
+	return nil, false
+}
+
+// checkBlocked reports whether client is prevented from acquiring target,
+// either by target itself or by one of its relatives
+func (ls *lockSet) checkBlocked(target *State, client string, now time.Time, o acquireOptions) (AcquireResult, bool) {
+	if result, blocked := target.checkAvailable(client, now, o); blocked {
+		return result, true
+	}
+	if r, blocked := ls.blockingRelative(target, client, now); blocked {
+		return AcquireResult{
+			Success:    false,
+			Code:       CodeRelatedPathHeld,
+			Job:        target.Job,
+			Holder:     r.Holder,
+			BlockedBy:  r.Job,
+			AcquiredAt: r.AcquiredAt,
+			ExpiresAt:  r.ExpiresAt,
+			GraceUntil: r.GraceUntil,
+			Message:    msg.RelatedPathHeld,
+
+			AvailableAt: r.GraceUntil,
+		}, true
+	}
+	return AcquireResult{}, false
+}
+
+// statusBlocker returns the relative of target held by someone other than
+// target's own holder, if any
+func (ls *lockSet) statusBlocker(target *State, now time.Time) (*State, bool) {
+	for _, r := range ls.related {
+		if !isRelated(r.Job, target.Job) || r.Holder == "" || r.Holder == target.Holder {
+			continue
+		}
+		if r.isHeldByAnother(now) || r.isInGracePeriod(now) {
+			return r, true
+		}
+	}
+	return nil, false
+}
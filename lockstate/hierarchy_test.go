@@ -0,0 +1,211 @@
+package lockstate
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shadyabhi/foolock/lockstate/msg"
+)
+
+func TestIsAncestor(t *testing.T) {
+	tests := []struct {
+		parent   string
+		child    string
+		expected bool
+	}{
+		{"/photos", "/photos/2024", true},
+		{"/photos", "/photos/2024/jan", true},
+		{"/", "/photos", true},
+		{"/photos", "/photos", false},
+		{"/photos", "/photoshop", false},
+		{"/photos/2024", "/photos", false},
+		{"default", "backup", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.parent+" "+tt.child, func(t *testing.T) {
+			if got := isAncestor(tt.parent, tt.child); got != tt.expected {
+				t.Errorf("isAncestor(%q, %q) = %v, want %v", tt.parent, tt.child, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestNormalizeJob(t *testing.T) {
+	tests := []struct {
+		job      string
+		expected string
+	}{
+		{"default", "default"},
+		{"/photos/", "/photos"},
+		{"/photos//2024", "/photos/2024"},
+		{"photos/2024/", "photos/2024"},
+	}
+
+	for _, tt := range tests {
+		if got := normalizeJob(tt.job); got != tt.expected {
+			t.Errorf("normalizeJob(%q) = %q, want %q", tt.job, got, tt.expected)
+		}
+	}
+}
+
+func TestCovers(t *testing.T) {
+	tests := []struct {
+		scope    string
+		job      string
+		expected bool
+	}{
+		{"backup", "backup", true},
+		{"backup", "backups", false},
+		{"/photos", "/photos/2024", true},
+		{"/photos/", "/photos/2024/", true},
+		{"/photos", "/photos", true},
+		{"/photos/2024", "/photos", false},
+		{"/photos", "/photoshop", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.scope+" "+tt.job, func(t *testing.T) {
+			if got := Covers(tt.scope, tt.job); got != tt.expected {
+				t.Errorf("Covers(%q, %q) = %v, want %v", tt.scope, tt.job, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestHierarchicalAcquire(t *testing.T) {
+	tests := []struct {
+		name      string
+		held      string
+		job       string
+		client    string
+		success   bool
+		blockedBy string
+	}{
+		{"child blocked by parent", "/photos", "/photos/2024", "client2", false, "/photos"},
+		{"parent blocked by child", "/photos/2024", "/photos", "client2", false, "/photos/2024"},
+		{"root blocked by any path", "/photos/2024", "/", "client2", false, "/photos/2024"},
+		{"sibling is independent", "/photos/2023", "/photos/2024", "client2", true, ""},
+		{"prefix is not a parent", "/photos", "/photoshop", "client2", true, ""},
+		{"same client may nest", "/photos", "/photos/2024", "client1", true, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := New()
+			m.Acquire(tt.held, "client1", time.Minute)
+
+			result := m.Acquire(tt.job, tt.client, time.Minute)
+			if result.Success != tt.success {
+				t.Fatalf("Success = %v, want %v (%s)", result.Success, tt.success, result.Message)
+			}
+			if result.BlockedBy != tt.blockedBy {
+				t.Errorf("BlockedBy = %q, want %q", result.BlockedBy, tt.blockedBy)
+			}
+			if !tt.success {
+				if result.Message != msg.RelatedPathHeld {
+					t.Errorf("Message = %q, want %q", result.Message, msg.RelatedPathHeld)
+				}
+				if result.Holder != "client1" {
+					t.Errorf("Holder = %q, want client1", result.Holder)
+				}
+			}
+		})
+	}
+}
+
+func TestHierarchicalGracePeriod(t *testing.T) {
+	m := New(WithGracePeriod(time.Minute))
+	m.Acquire("/photos", "client1", time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+
+	result := m.Acquire("/photos/2024", "client2", time.Minute)
+	if result.Success {
+		t.Fatal("expected parent in grace period to block child")
+	}
+	if result.BlockedBy != "/photos" {
+		t.Errorf("BlockedBy = %q, want /photos", result.BlockedBy)
+	}
+}
+
+func TestHierarchicalRelease(t *testing.T) {
+	m := New()
+	m.Acquire("/photos/", "client1", time.Minute)
+
+	if result := m.Acquire("/photos/2024", "client2", time.Minute); result.Success {
+		t.Fatal("expected conflict while parent is held")
+	}
+
+	if result := m.Release("/photos", "client1"); !result.Success {
+		t.Fatalf("release failed: %s", result.Message)
+	}
+
+	if result := m.Acquire("/photos/2024", "client2", time.Minute); !result.Success {
+		t.Fatalf("expected success after parent release: %s", result.Message)
+	}
+}
+
+func TestHierarchicalStatus(t *testing.T) {
+	m := New()
+	m.Acquire("/photos/2024", "client1", time.Minute)
+
+	status := m.Status("/photos")
+	if status.Holder != "" {
+		t.Errorf("Holder = %q, want empty", status.Holder)
+	}
+	if status.BlockedBy != "/photos/2024" {
+		t.Errorf("BlockedBy = %q, want /photos/2024", status.BlockedBy)
+	}
+	if status.BlockedByHolder != "client1" {
+		t.Errorf("BlockedByHolder = %q, want client1", status.BlockedByHolder)
+	}
+
+	status = m.Status("/videos")
+	if status.BlockedBy != "" {
+		t.Errorf("BlockedBy = %q, want empty for unrelated path", status.BlockedBy)
+	}
+}
+
+func TestHierarchicalAcquireMany(t *testing.T) {
+	m := New()
+	m.Acquire("/photos/2024", "client1", time.Minute)
+
+	result := m.AcquireMany([]string{"/photos", "/videos"}, "client2", time.Minute)
+	if result.Success {
+		t.Fatal("expected conflict from descendant")
+	}
+	if len(result.Conflicts) != 1 || result.Conflicts[0].BlockedBy != "/photos/2024" {
+		t.Fatalf("conflicts = %+v, want /photos blocked by /photos/2024", result.Conflicts)
+	}
+
+	result = m.AcquireMany([]string{"/photos", "/photos/2024"}, "client1", time.Minute)
+	if !result.Success {
+		t.Fatalf("expected holder to take parent and child together: %s", result.Message)
+	}
+}
+
+func TestHierarchicalRelativeCreatedWhileWaiting(t *testing.T) {
+	m := New()
+	a := m.getOrCreateLock("/a")
+	a.mu.Lock()
+
+	done := make(chan AcquireManyResult)
+	go func() {
+		done <- m.AcquireMany([]string{"/a", "/p"}, "client1", time.Minute)
+	}()
+	// Let client1 collect its relatives and block on /a, then create a
+	// descendant of /p behind its back
+	time.Sleep(20 * time.Millisecond)
+	if result := m.Acquire("/p/c", "client2", time.Minute); !result.Success {
+		t.Fatalf("expected /p/c to be free: %s", result.Message)
+	}
+	a.mu.Unlock()
+
+	result := <-done
+	if result.Success {
+		t.Fatal("expected /p to be blocked by /p/c, created while waiting")
+	}
+	if len(result.Conflicts) != 1 || result.Conflicts[0].BlockedBy != "/p/c" {
+		t.Fatalf("conflicts = %+v, want /p blocked by /p/c", result.Conflicts)
+	}
+}
@@ -0,0 +1,120 @@
+package lockstate
+
+import "time"
+
+const defaultHistorySize = 100
+
+type EventType string
+
+const (
+	EventAcquired   EventType = "acquired"
+	EventRenewed    EventType = "renewed"
+	EventReleased   EventType = "released"
+	EventExpired    EventType = "expired"
+	EventGraceEnded EventType = "grace_ended"
+	EventConflict   EventType = "conflict"
+)
+
+// Event is a single entry in a job's history
+type Event struct {
+	Time    time.Time
+	Job     string
+	Type    EventType
+	Client  string
+	Holder  string
+	Message string
+}
+
+// EventSink receives every recorded event, e.g. to append it to an audit log
+type EventSink func(Event)
+
+// ring is a fixed-size buffer keeping the most recent entries
+type ring[T any] struct {
+	buf  []T
+	next int
+	full bool
+}
+
+func newRing[T any](size int) *ring[T] {
+	return &ring[T]{buf: make([]T, max(size, 0))}
+}
+
+func (r *ring[T]) add(v T) {
+	if len(r.buf) == 0 {
+		return
+	}
+	r.buf[r.next] = v
+	r.next = (r.next + 1) % len(r.buf)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// list returns the buffered entries, oldest first
+func (r *ring[T]) list() []T {
+	if !r.full {
+		return append([]T(nil), r.buf[:r.next]...)
+	}
+	entries := make([]T, 0, len(r.buf))
+	entries = append(entries, r.buf[r.next:]...)
+	return append(entries, r.buf[:r.next]...)
+}
+
+// record stores an event in the job's history and forwards it to the sink;
+// the caller must hold s.mu
+func (s *State) record(e Event) {
+	e.Job = s.Job
+	if s.history != nil {
+		s.history.add(e)
+	}
+	if s.sink != nil {
+		s.sink(e)
+	}
+}
+
+// observe records expiry and end-of-grace transitions that happened since
+// the lock was last looked at, the latter ending the holder's run; the caller
+// must hold s.mu
+func (s *State) observe(now time.Time) {
+	if s.Holder == "" {
+		return
+	}
+	if !s.expiryRecorded && !now.Before(s.ExpiresAt) {
+		s.expiryRecorded = true
+		s.record(Event{Time: s.ExpiresAt, Type: EventExpired, Client: s.Holder, Holder: s.Holder})
+	}
+	if !s.graceEndRecorded && !now.Before(s.GraceUntil) {
+		s.graceEndRecorded = true
+		s.record(Event{Time: s.GraceUntil, Type: EventGraceEnded, Client: s.Holder, Holder: s.Holder})
+		s.recordAbandoned()
+	}
+}
+
+// resetObserved clears the expiry bookkeeping after the lease changes;
+// the caller must hold s.mu
+func (s *State) resetObserved() {
+	s.expiryRecorded = false
+	s.graceEndRecorded = false
+}
+
+func (s *State) recordConflict(client string, result AcquireResult, now time.Time) {
+	s.record(Event{Time: now, Type: EventConflict, Client: client, Holder: result.Holder, Message: result.Message})
+}
+
+// History returns the recorded events for the lock, oldest first
+func (s *State) History() []Event {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.observe(time.Now())
+	if s.history == nil {
+		return nil
+	}
+	return s.history.list()
+}
+
+// History returns the recorded events for a job, oldest first
+func (m *Manager) History(job string) []Event {
+	s := m.getOrCreateLock(job)
+	return s.History()
+}
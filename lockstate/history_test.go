@@ -0,0 +1,100 @@
+package lockstate
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRing(t *testing.T) {
+	r := newRing[Event](3)
+	if got := r.list(); len(got) != 0 {
+		t.Fatalf("empty ring returned %d events", len(got))
+	}
+
+	for _, job := range []string{"a", "b", "c", "d", "e"} {
+		r.add(Event{Job: job})
+	}
+
+	got := r.list()
+	if len(got) != 3 {
+		t.Fatalf("len = %d, want 3", len(got))
+	}
+	for i, want := range []string{"c", "d", "e"} {
+		if got[i].Job != want {
+			t.Errorf("event %d = %q, want %q", i, got[i].Job, want)
+		}
+	}
+}
+
+func TestHistory(t *testing.T) {
+	var sunk []Event
+	m := New(WithGracePeriod(time.Millisecond), WithEventSink(func(e Event) {
+		sunk = append(sunk, e)
+	}))
+
+	m.Acquire("backup", "client1", time.Minute)
+	m.Acquire("backup", "client1", 20*time.Millisecond)
+	m.Acquire("backup", "client2", time.Minute)
+	time.Sleep(50 * time.Millisecond)
+	m.Acquire("backup", "client2", time.Minute)
+	m.Release("backup", "client2")
+	m.Flush()
+
+	want := []EventType{
+		EventAcquired,
+		EventRenewed,
+		EventConflict,
+		EventExpired,
+		EventGraceEnded,
+		EventAcquired,
+		EventReleased,
+	}
+
+	events := m.History("backup")
+	if len(events) != len(want) {
+		t.Fatalf("got %d events %+v, want %d", len(events), events, len(want))
+	}
+	for i, typ := range want {
+		if events[i].Type != typ {
+			t.Errorf("event %d type = %q, want %q", i, events[i].Type, typ)
+		}
+		if events[i].Job != "backup" {
+			t.Errorf("event %d job = %q, want backup", i, events[i].Job)
+		}
+	}
+	if events[2].Client != "client2" || events[2].Holder != "client1" {
+		t.Errorf("conflict event = %+v, want client2 blocked by client1", events[2])
+	}
+	if len(sunk) != len(want) {
+		t.Errorf("sink got %d events, want %d", len(sunk), len(want))
+	}
+}
+
+func TestHistoryObservesExpiry(t *testing.T) {
+	m := New(WithGracePeriod(time.Hour))
+	m.Acquire("backup", "client1", time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+
+	events := m.History("backup")
+	if len(events) != 2 {
+		t.Fatalf("got %d events, want 2", len(events))
+	}
+	if events[1].Type != EventExpired {
+		t.Errorf("type = %q, want %q", events[1].Type, EventExpired)
+	}
+
+	// Looking again must not duplicate the expiry
+	if events = m.History("backup"); len(events) != 2 {
+		t.Errorf("got %d events after second read, want 2", len(events))
+	}
+}
+
+func TestHistorySize(t *testing.T) {
+	m := New(WithHistorySize(2))
+	for range 5 {
+		m.Acquire("backup", "client1", time.Minute)
+	}
+	if events := m.History("backup"); len(events) != 2 {
+		t.Errorf("got %d events, want 2", len(events))
+	}
+}
@@ -0,0 +1,54 @@
+package lockstate
+
+import (
+	"time"
+
+	"github.com/shadyabhi/foolock/lockstate/msg"
+)
+
+type acquireOptions struct {
+	minInterval time.Duration
+	metadata    map[string]string
+	renewOnly   bool
+	session     string
+}
+
+// AcquireOption tweaks a single acquisition
+type AcquireOption func(*acquireOptions)
+
+// MinInterval refuses a new acquisition if the job last completed
+// successfully less than d ago, so a job scheduled on several machines runs
+// at most once per interval
+func MinInterval(d time.Duration) AcquireOption {
+	return func(o *acquireOptions) {
+		o.minInterval = d
+	}
+}
+
+func newAcquireOptions(opts []AcquireOption) acquireOptions {
+	var o acquireOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// ranWithin reports whether the job completed successfully less than
+// interval ago
+func (s *State) ranWithin(interval time.Duration, now time.Time) bool {
+	return interval > 0 && !s.LastSuccessAt.IsZero() && now.Sub(s.LastSuccessAt) < interval
+}
+
+func (s *State) respRanRecently(interval time.Duration) AcquireResult {
+	return AcquireResult{
+		Success:       false,
+		Code:          CodeRanRecently,
+		Job:           s.Job,
+		Holder:        s.Holder,
+		Message:       msg.RanRecently,
+		LastSuccessAt: s.LastSuccessAt,
+		LastSuccessBy: s.LastSuccessBy,
+		NextRunAt:     s.LastSuccessAt.Add(interval),
+		AvailableAt:   s.LastSuccessAt.Add(interval),
+	}
+}
@@ -0,0 +1,91 @@
+package lockstate
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shadyabhi/foolock/lockstate/msg"
+)
+
+func TestMinInterval(t *testing.T) {
+	m := New()
+
+	m.Acquire("cron", "client1", time.Minute, MinInterval(time.Hour))
+	released := m.Release("cron", "client1", Succeeded())
+	if !released.Success {
+		t.Fatalf("release failed: %s", released.Message)
+	}
+
+	result := m.Acquire("cron", "client2", time.Minute, MinInterval(time.Hour))
+	if result.Success {
+		t.Fatal("expected acquisition within interval to be refused")
+	}
+	if result.Message != msg.RanRecently {
+		t.Errorf("Message = %q, want %q", result.Message, msg.RanRecently)
+	}
+	if result.LastSuccessBy != "client1" {
+		t.Errorf("LastSuccessBy = %q, want client1", result.LastSuccessBy)
+	}
+	if got := result.NextRunAt.Sub(result.LastSuccessAt); got != time.Hour {
+		t.Errorf("NextRunAt - LastSuccessAt = %s, want 1h", got)
+	}
+
+	// Without an interval the lock itself is free
+	if result := m.Acquire("cron", "client2", time.Minute); !result.Success {
+		t.Errorf("expected plain acquisition to succeed, got %q", result.Message)
+	}
+}
+
+func TestMinIntervalElapsed(t *testing.T) {
+	m := New()
+	m.Acquire("cron", "client1", time.Minute)
+	m.Release("cron", "client1", Succeeded())
+	time.Sleep(15 * time.Millisecond)
+
+	if result := m.Acquire("cron", "client2", time.Minute, MinInterval(10*time.Millisecond)); !result.Success {
+		t.Errorf("expected success once interval elapsed, got %q", result.Message)
+	}
+}
+
+func TestMinIntervalIgnoresUnsuccessfulRuns(t *testing.T) {
+	m := New()
+	m.Acquire("cron", "client1", time.Minute)
+	m.Release("cron", "client1")
+
+	if result := m.Acquire("cron", "client2", time.Minute, MinInterval(time.Hour)); !result.Success {
+		t.Errorf("expected success when previous run wasn't marked successful, got %q", result.Message)
+	}
+
+	status := m.Status("cron")
+	if !status.LastSuccessAt.IsZero() {
+		t.Errorf("LastSuccessAt = %s, want zero", status.LastSuccessAt)
+	}
+}
+
+func TestMinIntervalAllowsRenewal(t *testing.T) {
+	m := New()
+	m.Acquire("cron", "client1", time.Minute)
+	m.Release("cron", "client1", Succeeded())
+
+	m.Acquire("cron", "client1", time.Minute)
+	if result := m.Acquire("cron", "client1", time.Minute, MinInterval(time.Hour)); !result.Success {
+		t.Errorf("expected current holder to renew regardless of interval, got %q", result.Message)
+	}
+}
+
+func TestMinIntervalAcquireMany(t *testing.T) {
+	m := New()
+	m.Acquire("cron", "client1", time.Minute)
+	m.ReleaseMany([]string{"cron"}, "client1", Succeeded())
+
+	result := m.AcquireMany([]string{"cron", "other"}, "client2", time.Minute, MinInterval(time.Hour))
+	if result.Success {
+		t.Fatal("expected AcquireMany to honour the interval")
+	}
+	if status := m.Status("other"); status.Holder != "" {
+		t.Errorf("other holder = %q, want empty", status.Holder)
+	}
+	if status := m.Status("cron"); status.LastSuccessBy != "client1" {
+		t.Errorf("LastSuccessBy = %q, want client1", status.LastSuccessBy)
+	}
+}
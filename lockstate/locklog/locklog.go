@@ -0,0 +1,84 @@
+// Package locklog logs lock operations the same way whichever API they came
+// through.
+package locklog
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/shadyabhi/foolock/lockstate"
+)
+
+// Acquire records an acquisition attempt. Grants are logged at info and
+// routine refusals at debug, since waiting clients retry them constantly;
+// refusals that point at a misconfigured client are warnings.
+func Acquire(ctx context.Context, logger *slog.Logger, client string, ttl time.Duration, result lockstate.AcquireResult) {
+	level := slog.LevelDebug
+	attrs := []slog.Attr{
+		slog.String("job", result.Job),
+		slog.String("client", client),
+		slog.String("code", string(result.Code)),
+	}
+	if ttl != 0 {
+		attrs = append(attrs, slog.Duration("ttl", ttl))
+	}
+
+	switch result.Code {
+	case lockstate.CodeAcquired, lockstate.CodeRenewed, lockstate.CodeReclaimed, lockstate.CodeTakenOver:
+		level = slog.LevelInfo
+		attrs = append(attrs, slog.Time("expires_at", result.ExpiresAt))
+		if result.Code != lockstate.CodeAcquired {
+			attrs = append(attrs, slog.Duration("held_for", time.Since(result.AcquiredAt).Round(time.Millisecond)))
+		}
+		if result.PreviousHolder != "" {
+			attrs = append(attrs, slog.String("previous_holder", result.PreviousHolder))
+		}
+	case lockstate.CodeMaxHoldExceeded, lockstate.CodeClientNotPermitted, lockstate.CodeUnknownJob, lockstate.CodeTTLOutOfRange, lockstate.CodeNotHolder, lockstate.CodeSessionNotFound:
+		level = slog.LevelWarn
+	default:
+		attrs = append(attrs, slog.String("holder", result.Holder))
+	}
+	logger.LogAttrs(ctx, level, "Lock acquire", attrs...)
+}
+
+// Release records a release attempt; releasing a lock the client doesn't
+// hold is a warning, as it usually means the client lost it without noticing
+func Release(ctx context.Context, logger *slog.Logger, client string, result lockstate.ReleaseResult) {
+	level := slog.LevelInfo
+	attrs := []slog.Attr{
+		slog.String("job", result.Job),
+		slog.String("client", client),
+		slog.String("code", string(result.Code)),
+	}
+	if result.Success {
+		attrs = append(attrs, slog.Duration("held_for", result.HeldFor.Round(time.Millisecond)))
+	} else {
+		level = slog.LevelWarn
+	}
+	logger.LogAttrs(ctx, level, "Lock release", attrs...)
+}
+
+// Session records a session being created, renewed or closed. Heartbeats are
+// logged at debug, as clients send them constantly; heartbeating a session
+// that expired is a warning, as its locks are already in their grace periods.
+func Session(ctx context.Context, logger *slog.Logger, id string, result lockstate.SessionResult) {
+	level := slog.LevelInfo
+	attrs := []slog.Attr{
+		slog.String("session", id),
+		slog.String("code", string(result.Code)),
+	}
+	if result.Success {
+		attrs = append(attrs,
+			slog.String("client", result.Session.Client),
+			slog.Int("locks", len(result.Session.Jobs)),
+		)
+	}
+	switch result.Code {
+	case lockstate.CodeSessionRenewed:
+		level = slog.LevelDebug
+	case lockstate.CodeSessionNotFound:
+		level = slog.LevelWarn
+	}
+	logger.LogAttrs(ctx, level, "Session", attrs...)
+}
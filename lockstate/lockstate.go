@@ -1,7 +1,10 @@
 package lockstate
 
 import (
+	"maps"
+	"slices"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -14,12 +17,43 @@ type State struct {
 	mu          sync.Mutex
 	ttl         time.Duration
 	gracePeriod time.Duration
+	minTTL      time.Duration
+	maxTTL      time.Duration
+	maxHold     time.Duration
+	clients     []string
+	unknown     bool
 
+	cooldown     time.Duration
+	cooldownMode CooldownMode
+	releasedBy   string
+	// session is the ID of the session the holder bound the lock to, if any
+	session string
+
+	history          *ring[Event]
+	runs             *ring[Run]
+	sink             EventSink
+	expiryRecorded   bool
+	graceEndRecorded bool
+
+	// Deadlines are always derived from time.Now(), and Restore rebases the
+	// ones it loads onto it, so they carry a monotonic clock reading and
+	// comparisons against them are unaffected by wall-clock jumps on the
+	// server
 	Job        string
 	Holder     string
 	AcquiredAt time.Time
 	ExpiresAt  time.Time
 	GraceUntil time.Time
+	Metadata   map[string]string
+
+	CooldownUntil time.Time
+
+	// LastAcquiredAt is when the lock was last acquired or renewed
+	LastAcquiredAt time.Time
+	LastSuccessAt  time.Time
+	LastSuccessBy  string
+	LastFailureAt  time.Time
+	LastFailureBy  string
 }
 
 func newState(ttl, gracePeriod time.Duration) *State {
@@ -34,7 +68,26 @@ type Manager struct {
 	mu          sync.RWMutex
 	locks       map[string]*State
 	ttl         time.Duration
+	maxTTL      time.Duration
 	gracePeriod time.Duration
+	maxHold     time.Duration
+	historySize int
+	runsSize    int
+
+	cooldown     time.Duration
+	cooldownMode CooldownMode
+
+	sink     EventSink
+	policies map[string]Policy
+	strict   bool
+
+	draining atomic.Bool
+	watchers watchers
+	outbox   outbox
+	// generation counts the states created, so lockJobs can tell whether a
+	// relative appeared while it was waiting for state locks
+	generation atomic.Uint64
+	sessions   sessions
 }
 
 type Option func(*Manager)
@@ -45,55 +98,165 @@ func WithTTL(d time.Duration) Option {
 	}
 }
 
+// WithMaxTTL rejects acquisitions asking for a longer ttl, unless a job's
+// policy sets its own limit
+func WithMaxTTL(d time.Duration) Option {
+	return func(m *Manager) {
+		m.maxTTL = d
+	}
+}
+
 func WithGracePeriod(d time.Duration) Option {
 	return func(m *Manager) {
 		m.gracePeriod = d
 	}
 }
 
+// WithMaxHold limits how long a single holder may keep a lock through
+// renewals, measured from when it was acquired
+func WithMaxHold(d time.Duration) Option {
+	return func(m *Manager) {
+		m.maxHold = d
+	}
+}
+
+// WithHistorySize sets how many events are kept per job
+func WithHistorySize(n int) Option {
+	return func(m *Manager) {
+		m.historySize = n
+	}
+}
+
+// WithEventSink registers a function called for every recorded event
+func WithEventSink(sink EventSink) Option {
+	return func(m *Manager) {
+		m.sink = sink
+	}
+}
+
 func New(opts ...Option) *Manager {
 	m := &Manager{
 		locks:       make(map[string]*State),
 		ttl:         defaultTTL,
 		gracePeriod: defaultGracePeriod,
+		historySize: defaultHistorySize,
+		runsSize:    defaultRunsSize,
 	}
+	m.outbox.cond = sync.NewCond(&m.outbox.mu)
 	for _, opt := range opts {
 		opt(m)
 	}
 	return m
 }
 
-// getOrCreateLock returns the lock for a job, creating it if needed
+// getOrCreateLock returns the lock for a job, creating it if needed. A job
+// strict mode refuses gets a fresh state that isn't kept, which answers every
+// request as for a job nobody has locked and refuses acquisitions, so
+// requests for made-up jobs can't grow the manager.
 func (m *Manager) getOrCreateLock(job string) *State {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
+	return m.getOrCreateLockLocked(normalizeJob(job))
+}
+
+// getOrCreateLockLocked is getOrCreateLock for callers already holding m.mu
+func (m *Manager) getOrCreateLockLocked(job string) *State {
 	if s, ok := m.locks[job]; ok {
 		return s
 	}
 
 	s := newState(m.ttl, m.gracePeriod)
 	s.Job = job
+	s.maxTTL = m.maxTTL
+	s.maxHold = m.maxHold
+	s.cooldown = m.cooldown
+	s.cooldownMode = m.cooldownMode
+	s.history = newRing[Event](m.historySize)
+	s.runs = newRing[Run](m.runsSize)
+	s.sink = m.publish
+	m.applyPolicy(s)
+	if s.unknown {
+		return s
+	}
 	m.locks[job] = s
+	m.generation.Add(1)
 	return s
 }
 
-// Acquire attempts to acquire a lock for a job
-func (m *Manager) Acquire(job, client string, ttl time.Duration) AcquireResult {
-	s := m.getOrCreateLock(job)
-	return s.Acquire(client, ttl)
+// Acquire attempts to acquire a lock for a job. A zero ttl uses the job's
+// default.
+func (m *Manager) Acquire(job, client string, ttl time.Duration, opts ...AcquireOption) AcquireResult {
+	o := newAcquireOptions(opts)
+
+	ls := m.lockJobs([]string{job})
+	defer ls.unlock()
+
+	now := time.Now()
+	s := ls.targets[0]
+	var sessionEnds time.Time
+	if o.session != "" {
+		var result AcquireResult
+		var ok bool
+		if ttl, sessionEnds, result, ok = m.sessionLease(s, client, o.session, now); !ok {
+			return result
+		}
+	}
+	ttl, result, ok := s.checkPolicy(client, ttl)
+	if !ok {
+		return result
+	}
+	if !sessionEnds.IsZero() {
+		ttl = sessionEnds.Sub(now)
+	}
+	s.observe(now)
+	if result, blocked := s.checkRenewing(client, o); blocked {
+		s.recordConflict(client, result, now)
+		return result
+	}
+	if result, blocked := m.checkDraining(s, client); blocked {
+		s.recordConflict(client, result, now)
+		return result
+	}
+	if result, blocked := ls.checkBlocked(s, client, now, o); blocked {
+		s.recordConflict(client, result, now)
+		return result
+	}
+	return m.bindSession(s, s.acquire(client, now, ttl, o))
 }
 
 // Release releases a lock for a job
-func (m *Manager) Release(job, client string) ReleaseResult {
+func (m *Manager) Release(job, client string, opts ...ReleaseOption) ReleaseResult {
 	s := m.getOrCreateLock(job)
-	return s.Release(client)
+	return s.Release(client, opts...)
+}
+
+// Jobs returns the names of all jobs the manager knows about, sorted
+func (m *Manager) Jobs() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	jobs := make([]string, 0, len(m.locks))
+	for job := range m.locks {
+		jobs = append(jobs, job)
+	}
+	slices.Sort(jobs)
+	return jobs
 }
 
-// Status returns the status of a lock for a job
+// Status returns the status of a lock for a job, including any ancestor or
+// descendant path lock that would block it
 func (m *Manager) Status(job string) StatusResult {
-	s := m.getOrCreateLock(job)
-	return s.Status()
+	ls := m.lockJobs([]string{job})
+	defer ls.unlock()
+
+	s := ls.targets[0]
+	result := s.status()
+	if r, blocked := ls.statusBlocker(s, time.Now()); blocked {
+		result.BlockedBy = r.Job
+		result.BlockedByHolder = r.Holder
+	}
+	return result
 }
 
 func (s *State) IsExpired() bool {
@@ -108,22 +271,59 @@ func (s *State) InGracePeriod() bool {
 type StatusResult struct {
 	Job        string
 	Holder     string
+	AcquiredAt time.Time
 	ExpiresAt  time.Time
 	GraceUntil time.Time
 	IsExpired  bool
 	InGrace    bool
+	Metadata   map[string]string
+	// Session is the session the lock is bound to, if any
+	Session string
+
+	BlockedBy       string
+	BlockedByHolder string
+
+	// MaxHoldUntil is when the current holder's renewals stop being accepted
+	MaxHoldUntil time.Time
+	// CooldownUntil is set while the lock is cooling down after a release
+	CooldownUntil time.Time
+
+	// LastAcquiredAt is when the lock was last acquired or renewed
+	LastAcquiredAt time.Time
+	LastSuccessAt  time.Time
+	LastSuccessBy  string
+	LastFailureAt  time.Time
+	LastFailureBy  string
 }
 
 func (s *State) Status() StatusResult {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	return s.status()
+}
+
+// status builds the status snapshot; the caller must hold s.mu
+func (s *State) status() StatusResult {
+	s.observe(time.Now())
 	return StatusResult{
 		Job:        s.Job,
 		Holder:     s.Holder,
+		AcquiredAt: s.AcquiredAt,
 		ExpiresAt:  s.ExpiresAt,
 		GraceUntil: s.GraceUntil,
 		IsExpired:  s.IsExpired(),
 		InGrace:    s.InGracePeriod(),
+		Metadata:   maps.Clone(s.Metadata),
+		Session:    s.session,
+
+		MaxHoldUntil:  s.maxHoldUntil(),
+		CooldownUntil: s.activeCooldown(time.Now()),
+
+		LastAcquiredAt: s.LastAcquiredAt,
+		LastSuccessAt:  s.LastSuccessAt,
+		LastSuccessBy:  s.LastSuccessBy,
+		LastFailureAt:  s.LastFailureAt,
+		LastFailureBy:  s.LastFailureBy,
 	}
 }
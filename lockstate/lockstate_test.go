@@ -193,3 +193,25 @@ func TestStatus(t *testing.T) {
 		})
 	}
 }
+
+func TestManagerJobs(t *testing.T) {
+	m := New()
+	if jobs := m.Jobs(); len(jobs) != 0 {
+		t.Fatalf("expected no jobs, got %v", jobs)
+	}
+
+	m.Acquire("sync", "client1", time.Minute)
+	m.Acquire("/photos/", "client1", time.Minute)
+	m.Status("backup")
+
+	jobs := m.Jobs()
+	want := []string{"/photos", "backup", "sync"}
+	if len(jobs) != len(want) {
+		t.Fatalf("jobs = %v, want %v", jobs, want)
+	}
+	for i := range want {
+		if jobs[i] != want[i] {
+			t.Errorf("jobs[%d] = %q, want %q", i, jobs[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package lockstate
+
+import (
+	"time"
+
+	"github.com/shadyabhi/foolock/lockstate/msg"
+)
+
+// AcquireManyResult holds the outcome of an all-or-nothing acquisition
+type AcquireManyResult struct {
+	Success   bool
+	Message   string
+	Acquired  []AcquireResult
+	Conflicts []AcquireResult
+}
+
+// ReleaseManyResult holds the outcome of an all-or-nothing release
+type ReleaseManyResult struct {
+	Success  bool
+	Message  string
+	Released []ReleaseResult
+	NotHeld  []ReleaseResult
+}
+
+// AcquireMany acquires or renews the locks for all jobs, or none of them.
+// Locks are taken in sorted job order so that concurrent callers asking for
+// overlapping sets can never deadlock each other.
+func (m *Manager) AcquireMany(jobs []string, client string, ttl time.Duration, opts ...AcquireOption) AcquireManyResult {
+	o := newAcquireOptions(opts)
+
+	ls := m.lockJobs(jobs)
+	defer ls.unlock()
+
+	now := time.Now()
+
+	ttls := make([]time.Duration, len(ls.targets))
+	var rejected []AcquireResult
+	for i, s := range ls.targets {
+		ttl := ttl
+		var sessionEnds time.Time
+		if o.session != "" {
+			var result AcquireResult
+			var ok bool
+			if ttl, sessionEnds, result, ok = m.sessionLease(s, client, o.session, now); !ok {
+				rejected = append(rejected, result)
+				continue
+			}
+		}
+		effective, result, ok := s.checkPolicy(client, ttl)
+		if !ok {
+			rejected = append(rejected, result)
+		}
+		if !sessionEnds.IsZero() {
+			effective = sessionEnds.Sub(now)
+		}
+		ttls[i] = effective
+	}
+	if len(rejected) > 0 {
+		return AcquireManyResult{
+			Success:   false,
+			Message:   msg.SomeLocksUnavailable,
+			Conflicts: rejected,
+		}
+	}
+
+	var conflicts []AcquireResult
+	for _, s := range ls.targets {
+		s.observe(now)
+		if result, blocked := m.checkDraining(s, client); blocked {
+			s.recordConflict(client, result, now)
+			conflicts = append(conflicts, result)
+			continue
+		}
+		if result, blocked := ls.checkBlocked(s, client, now, o); blocked {
+			s.recordConflict(client, result, now)
+			conflicts = append(conflicts, result)
+		}
+	}
+	if len(conflicts) > 0 {
+		return AcquireManyResult{
+			Success:   false,
+			Message:   msg.SomeLocksUnavailable,
+			Conflicts: conflicts,
+		}
+	}
+
+	acquired := make([]AcquireResult, 0, len(ls.targets))
+	for i, s := range ls.targets {
+		acquired = append(acquired, m.bindSession(s, s.acquire(client, now, ttls[i], o)))
+	}
+
+	return AcquireManyResult{
+		Success:  true,
+		Message:  msg.AllLocksAcquired,
+		Acquired: acquired,
+	}
+}
+
+// ReleaseMany releases the locks for all jobs if client holds every one of
+// them, otherwise nothing is released
+func (m *Manager) ReleaseMany(jobs []string, client string, opts ...ReleaseOption) ReleaseManyResult {
+	o := newReleaseOptions(opts)
+
+	ls := m.lockJobs(jobs)
+	defer ls.unlock()
+
+	now := time.Now()
+	var notHeld []ReleaseResult
+	for _, s := range ls.targets {
+		if s.Holder != client {
+			notHeld = append(notHeld, s.respNotHolder(now))
+		}
+	}
+	if len(notHeld) > 0 {
+		return ReleaseManyResult{
+			Success: false,
+			Message: msg.ClientNotHolder,
+			NotHeld: notHeld,
+		}
+	}
+
+	released := make([]ReleaseResult, 0, len(ls.targets))
+	for _, s := range ls.targets {
+		released = append(released, s.release(client, o))
+	}
+
+	return ReleaseManyResult{
+		Success:  true,
+		Message:  msg.AllLocksReleased,
+		Released: released,
+	}
+}
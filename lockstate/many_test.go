@@ -0,0 +1,120 @@
+package lockstate
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/shadyabhi/foolock/lockstate/msg"
+)
+
+func TestAcquireMany(t *testing.T) {
+	m := New()
+
+	result := m.AcquireMany([]string{"photos", "backup"}, "client1", time.Minute)
+	if !result.Success {
+		t.Fatalf("expected success, got %q", result.Message)
+	}
+	if len(result.Acquired) != 2 {
+		t.Fatalf("acquired %d locks, want 2", len(result.Acquired))
+	}
+	// Results come back in canonical order
+	if result.Acquired[0].Job != "backup" || result.Acquired[1].Job != "photos" {
+		t.Errorf("jobs = %q, %q, want backup, photos", result.Acquired[0].Job, result.Acquired[1].Job)
+	}
+
+	// Renewing the same set succeeds
+	result = m.AcquireMany([]string{"backup", "photos", "backup"}, "client1", time.Minute)
+	if !result.Success {
+		t.Fatalf("expected renew success, got %q", result.Message)
+	}
+	if len(result.Acquired) != 2 {
+		t.Fatalf("acquired %d locks, want 2 after de-duplication", len(result.Acquired))
+	}
+	for _, r := range result.Acquired {
+		if r.Message != msg.Renewed {
+			t.Errorf("job %s message = %q, want %q", r.Job, r.Message, msg.Renewed)
+		}
+	}
+}
+
+func TestAcquireManyAllOrNothing(t *testing.T) {
+	m := New()
+	m.Acquire("backup", "client1", time.Minute)
+
+	result := m.AcquireMany([]string{"photos", "backup"}, "client2", time.Minute)
+	if result.Success {
+		t.Fatal("expected failure when one job is held by another client")
+	}
+	if result.Message != msg.SomeLocksUnavailable {
+		t.Errorf("Message = %q, want %q", result.Message, msg.SomeLocksUnavailable)
+	}
+	if len(result.Conflicts) != 1 || result.Conflicts[0].Job != "backup" {
+		t.Fatalf("conflicts = %+v, want only backup", result.Conflicts)
+	}
+	if result.Conflicts[0].Holder != "client1" {
+		t.Errorf("conflict holder = %q, want client1", result.Conflicts[0].Holder)
+	}
+
+	// photos must not have been acquired
+	if status := m.Status("photos"); status.Holder != "" {
+		t.Errorf("photos holder = %q, want empty", status.Holder)
+	}
+}
+
+func TestReleaseMany(t *testing.T) {
+	m := New()
+	m.AcquireMany([]string{"photos", "backup"}, "client1", time.Minute)
+	m.Acquire("sync", "client2", time.Minute)
+
+	result := m.ReleaseMany([]string{"photos", "sync"}, "client1")
+	if result.Success {
+		t.Fatal("expected failure when client does not hold every job")
+	}
+	if len(result.NotHeld) != 1 || result.NotHeld[0].Job != "sync" {
+		t.Fatalf("not held = %+v, want only sync", result.NotHeld)
+	}
+	if status := m.Status("photos"); status.Holder != "client1" {
+		t.Errorf("photos holder = %q, want client1", status.Holder)
+	}
+
+	result = m.ReleaseMany([]string{"photos", "backup"}, "client1")
+	if !result.Success {
+		t.Fatalf("expected success, got %q", result.Message)
+	}
+	if len(result.Released) != 2 {
+		t.Errorf("released %d locks, want 2", len(result.Released))
+	}
+	if status := m.Status("backup"); status.Holder != "" {
+		t.Errorf("backup holder = %q, want empty", status.Holder)
+	}
+}
+
+func TestAcquireManyNoDeadlock(t *testing.T) {
+	m := New()
+
+	var wg sync.WaitGroup
+	for range 50 {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			m.AcquireMany([]string{"a", "b", "c"}, "client1", time.Millisecond)
+		}()
+		go func() {
+			defer wg.Done()
+			m.AcquireMany([]string{"c", "b", "a"}, "client2", time.Millisecond)
+		}()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("AcquireMany deadlocked")
+	}
+}
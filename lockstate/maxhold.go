@@ -0,0 +1,58 @@
+package lockstate
+
+import (
+	"time"
+
+	"github.com/shadyabhi/foolock/lockstate/msg"
+)
+
+// maxHoldUntil returns the end of the current holder's tenure, or the zero
+// time when there is no holder or no limit
+func (s *State) maxHoldUntil() time.Time {
+	if s.maxHold <= 0 || s.Holder == "" || s.AcquiredAt.IsZero() {
+		return time.Time{}
+	}
+	return s.AcquiredAt.Add(s.maxHold)
+}
+
+// isMaxHoldExceeded reports whether the current holder has used up its
+// maximum hold time
+func (s *State) isMaxHoldExceeded(now time.Time) bool {
+	deadline := s.maxHoldUntil()
+	return !deadline.IsZero() && !now.Before(deadline)
+}
+
+// isLapsed reports whether the lock has expired and its grace period ended
+func (s *State) isLapsed(now time.Time) bool {
+	return s.Holder != "" && !now.Before(s.GraceUntil)
+}
+
+// capExpiry keeps a lease from outliving the holder's maximum hold time
+func (s *State) capExpiry(expiresAt time.Time) time.Time {
+	deadline := s.maxHoldUntil()
+	if !deadline.IsZero() && expiresAt.After(deadline) {
+		return deadline
+	}
+	return expiresAt
+}
+
+// respMaxHoldExceeded refuses a renewal past the maximum hold time and ends
+// the lease now, so the lock moves through its grace period to free
+func (s *State) respMaxHoldExceeded(now time.Time) AcquireResult {
+	if now.Before(s.ExpiresAt) {
+		s.ExpiresAt = now
+		s.GraceUntil = now.Add(s.gracePeriod)
+	}
+	return AcquireResult{
+		Success:      false,
+		Code:         CodeMaxHoldExceeded,
+		Job:          s.Job,
+		Holder:       s.Holder,
+		AcquiredAt:   s.AcquiredAt,
+		ExpiresAt:    s.ExpiresAt,
+		GraceUntil:   s.GraceUntil,
+		MaxHoldUntil: s.maxHoldUntil(),
+		Message:      msg.MaxHoldExceeded,
+		AvailableAt:  s.GraceUntil,
+	}
+}
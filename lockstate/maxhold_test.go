@@ -0,0 +1,101 @@
+package lockstate
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shadyabhi/foolock/lockstate/msg"
+)
+
+func TestMaxHoldCapsExpiry(t *testing.T) {
+	m := New(WithMaxHold(time.Minute))
+
+	result := m.Acquire("backup", "client1", time.Hour)
+	if !result.Success {
+		t.Fatalf("acquire failed: %s", result.Message)
+	}
+	if !result.ExpiresAt.Equal(result.MaxHoldUntil) {
+		t.Errorf("ExpiresAt = %s, want capped at MaxHoldUntil %s", result.ExpiresAt, result.MaxHoldUntil)
+	}
+
+	status := m.Status("backup")
+	if got := time.Until(status.MaxHoldUntil); got <= 0 || got > time.Minute {
+		t.Errorf("remaining max hold = %s, want within 1m", got)
+	}
+}
+
+func TestMaxHoldForcesGrace(t *testing.T) {
+	m := New(WithMaxHold(20*time.Millisecond), WithGracePeriod(20*time.Millisecond))
+
+	m.Acquire("backup", "client1", 10*time.Millisecond)
+	time.Sleep(25 * time.Millisecond)
+
+	// Past the budget: the holder's renewal is refused and the lock lapses
+	result := m.Acquire("backup", "client1", time.Minute)
+	if result.Success {
+		t.Fatal("expected renewal past max hold to be refused")
+	}
+	if result.Message != msg.MaxHoldExceeded {
+		t.Errorf("Message = %q, want %q", result.Message, msg.MaxHoldExceeded)
+	}
+
+	// Others still have to wait out the grace period
+	if result := m.Acquire("backup", "client2", time.Minute); result.Message != msg.GracePeriodActive {
+		t.Errorf("Message = %q, want %q", result.Message, msg.GracePeriodActive)
+	}
+
+	time.Sleep(25 * time.Millisecond)
+
+	// Once free, the previous holder may start a brand new tenure
+	result = m.Acquire("backup", "client1", time.Minute)
+	if !result.Success {
+		t.Fatalf("expected fresh acquire after lapse, got %q", result.Message)
+	}
+	if result.Message != msg.Acquired {
+		t.Errorf("Message = %q, want %q", result.Message, msg.Acquired)
+	}
+}
+
+func TestMaxHoldRenewalsCannotExtend(t *testing.T) {
+	m := New(WithMaxHold(100*time.Millisecond), WithGracePeriod(time.Minute))
+
+	first := m.Acquire("backup", "client1", time.Minute)
+	for range 3 {
+		time.Sleep(10 * time.Millisecond)
+		result := m.Acquire("backup", "client1", time.Minute)
+		if !result.Success {
+			t.Fatalf("renewal within budget failed: %s", result.Message)
+		}
+		if !result.ExpiresAt.Equal(first.MaxHoldUntil) {
+			t.Errorf("ExpiresAt = %s, want %s", result.ExpiresAt, first.MaxHoldUntil)
+		}
+	}
+	time.Sleep(80 * time.Millisecond)
+
+	result := m.Acquire("backup", "client1", time.Minute)
+	if result.Message != msg.MaxHoldExceeded {
+		t.Fatalf("Message = %q, want %q", result.Message, msg.MaxHoldExceeded)
+	}
+
+	status := m.Status("backup")
+	if !status.IsExpired || !status.InGrace {
+		t.Errorf("status = %+v, want expired and in grace", status)
+	}
+}
+
+func TestMaxHoldPolicyOverride(t *testing.T) {
+	m := New(
+		WithMaxHold(time.Hour),
+		WithPolicies(map[string]Policy{"backup": {MaxHold: time.Minute}}),
+	)
+
+	backup := m.Acquire("backup", "client1", time.Second)
+	other := m.Acquire("other", "client1", time.Second)
+
+	if got := backup.MaxHoldUntil.Sub(time.Now()); got > time.Minute {
+		t.Errorf("backup max hold = %s, want policy's 1m", got)
+	}
+	if got := other.MaxHoldUntil.Sub(time.Now()); got < 59*time.Minute {
+		t.Errorf("other max hold = %s, want manager's 1h", got)
+	}
+}
@@ -0,0 +1,21 @@
+package lockstate
+
+import "maps"
+
+// WithMetadata attaches free-form key/value pairs to the lock, such as the
+// host or PID of the holder. A renewal without metadata keeps what was
+// attached before; a new holder starts from scratch.
+func WithMetadata(md map[string]string) AcquireOption {
+	return func(o *acquireOptions) {
+		o.metadata = md
+	}
+}
+
+// setMetadata records the metadata from a successful acquisition; the caller
+// must hold s.mu
+func (s *State) setMetadata(code Code, md map[string]string) {
+	if (code == CodeRenewed || code == CodeReclaimed) && md == nil {
+		return
+	}
+	s.Metadata = maps.Clone(md)
+}
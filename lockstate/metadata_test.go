@@ -0,0 +1,51 @@
+package lockstate
+
+import (
+	"maps"
+	"testing"
+	"time"
+)
+
+func TestMetadata(t *testing.T) {
+	m := New(WithGracePeriod(0))
+
+	md := map[string]string{"host": "laptop1"}
+	result := m.Acquire("backup", "c1", time.Minute, WithMetadata(md))
+	if !maps.Equal(result.Metadata, md) {
+		t.Errorf("Metadata = %v, want %v", result.Metadata, md)
+	}
+	if got := m.Status("backup").Metadata; !maps.Equal(got, md) {
+		t.Errorf("status Metadata = %v, want %v", got, md)
+	}
+
+	result = m.Acquire("backup", "c1", time.Minute)
+	if !maps.Equal(result.Metadata, md) {
+		t.Errorf("renewal without metadata: Metadata = %v, want %v", result.Metadata, md)
+	}
+
+	replaced := map[string]string{"pid": "42"}
+	result = m.Acquire("backup", "c1", time.Minute, WithMetadata(replaced))
+	if !maps.Equal(result.Metadata, replaced) {
+		t.Errorf("Metadata = %v, want %v", result.Metadata, replaced)
+	}
+
+	m.Release("backup", "c1")
+	if got := m.Status("backup").Metadata; got != nil {
+		t.Errorf("Metadata after release = %v, want nil", got)
+	}
+}
+
+func TestMetadataNotInheritedOnTakeover(t *testing.T) {
+	m := New(WithGracePeriod(0))
+
+	m.Acquire("backup", "c1", time.Millisecond, WithMetadata(map[string]string{"host": "laptop1"}))
+	time.Sleep(5 * time.Millisecond)
+
+	result := m.Acquire("backup", "c2", time.Minute)
+	if result.Code != CodeTakenOver {
+		t.Errorf("Code = %q, want %q", result.Code, CodeTakenOver)
+	}
+	if result.Metadata != nil {
+		t.Errorf("Metadata = %v, want nil", result.Metadata)
+	}
+}
@@ -2,12 +2,31 @@ package msg
 
 // Message constants for lock operations
 const (
-	Acquired          = "acquired"
-	Renewed           = "renewed"
-	HeldByAnother     = "held by another client"
-	GracePeriodActive = "grace period active"
-	LockReleased      = "lock released"
-	ClientNotHolder   = "client does not hold the lock"
-	LockHeld          = "lock held"
-	NoLockHeld        = "no lock held"
+	Acquired             = "acquired"
+	Renewed              = "renewed"
+	HeldByAnother        = "held by another client"
+	GracePeriodActive    = "grace period active"
+	LockReleased         = "lock released"
+	ClientNotHolder      = "client does not hold the lock"
+	LockHeld             = "lock held"
+	NoLockHeld           = "no lock held"
+	AllLocksAcquired     = "all locks acquired"
+	AllLocksReleased     = "all locks released"
+	SomeLocksUnavailable = "one or more locks unavailable"
+	RelatedPathHeld      = "related path held by another client"
+	UnknownJob           = "unknown job"
+	ClientNotPermitted   = "client not permitted for this job"
+	ReservedClient       = "client names beginning with uid: are reserved for the Unix socket"
+	TTLOutOfRange        = "ttl outside allowed range"
+	MaxHoldExceeded      = "maximum hold time exceeded"
+	CooldownActive       = "cooldown active"
+	RanRecently          = "job ran recently"
+	Draining             = "server is shutting down"
+	LeaseAbandoned       = "lease lapsed without a release"
+
+	SessionCreated         = "session created"
+	SessionRenewed         = "session renewed"
+	SessionClosed          = "session closed"
+	SessionNotFound        = "session not found or expired"
+	SessionOfAnotherClient = "session belongs to another client"
 )
@@ -0,0 +1,118 @@
+package lockstate
+
+import (
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/shadyabhi/foolock/lockstate/msg"
+)
+
+// Policy overrides the manager defaults for a single job. Zero values mean
+// "use the manager default" or "no limit".
+type Policy struct {
+	DefaultTTL  time.Duration
+	MinTTL      time.Duration
+	MaxTTL      time.Duration
+	GracePeriod time.Duration
+	MaxHold     time.Duration
+	Cooldown    time.Duration
+	// CooldownMode is only used when Cooldown is set
+	CooldownMode CooldownMode
+	// Clients, when non-empty, is the list of clients allowed to acquire
+	Clients []string
+}
+
+// WithPolicies sets per-job policies, keyed by job name. A policy on a path
+// also covers the paths beneath it that have none of their own.
+func WithPolicies(policies map[string]Policy) Option {
+	return func(m *Manager) {
+		m.policies = make(map[string]Policy, len(policies))
+		for job, p := range policies {
+			m.policies[normalizeJob(job)] = p
+		}
+	}
+}
+
+// WithStrictPolicies makes the manager refuse jobs without a policy
+func WithStrictPolicies(strict bool) Option {
+	return func(m *Manager) {
+		m.strict = strict
+	}
+}
+
+// policyFor returns the policy for the normalized job, or else for its
+// nearest ancestor with one, so a policy on "/photos" covers "/photos/2024"
+func (m *Manager) policyFor(job string) (Policy, bool) {
+	for {
+		if p, ok := m.policies[job]; ok {
+			return p, true
+		}
+		parent := path.Dir(job)
+		if !strings.Contains(job, "/") || parent == job {
+			return Policy{}, false
+		}
+		job = parent
+	}
+}
+
+// applyPolicy configures a newly created state from the job's policy;
+// the caller must hold m.mu
+func (m *Manager) applyPolicy(s *State) {
+	p, ok := m.policyFor(s.Job)
+	s.unknown = m.strict && !ok
+	if !ok {
+		return
+	}
+
+	if p.DefaultTTL > 0 {
+		s.ttl = p.DefaultTTL
+	}
+	if p.GracePeriod > 0 {
+		s.gracePeriod = p.GracePeriod
+	}
+	if p.MaxHold > 0 {
+		s.maxHold = p.MaxHold
+	}
+	if p.Cooldown > 0 {
+		s.cooldown = p.Cooldown
+		s.cooldownMode = p.CooldownMode
+	}
+	if p.MaxTTL > 0 {
+		s.maxTTL = p.MaxTTL
+	}
+	s.minTTL = p.MinTTL
+	s.clients = p.Clients
+}
+
+// checkPolicy validates a request against the job's policy and resolves the
+// effective ttl; the caller must hold s.mu
+func (s *State) checkPolicy(client string, ttl time.Duration) (time.Duration, AcquireResult, bool) {
+	if s.unknown {
+		return 0, s.respRejected(CodeUnknownJob, msg.UnknownJob), false
+	}
+	if len(s.clients) > 0 && !slices.Contains(s.clients, client) {
+		return 0, s.respRejected(CodeClientNotPermitted, msg.ClientNotPermitted), false
+	}
+
+	if ttl == 0 {
+		ttl = s.ttl
+	}
+	if ttl <= 0 || (s.minTTL > 0 && ttl < s.minTTL) || (s.maxTTL > 0 && ttl > s.maxTTL) {
+		return 0, s.respRejected(CodeTTLOutOfRange, msg.TTLOutOfRange), false
+	}
+
+	return ttl, AcquireResult{}, true
+}
+
+func (s *State) respRejected(code Code, message string) AcquireResult {
+	return AcquireResult{
+		Success:   false,
+		Code:      code,
+		Job:       s.Job,
+		Holder:    s.Holder,
+		ExpiresAt: s.ExpiresAt,
+		Message:   message,
+	}
+}
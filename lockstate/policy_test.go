@@ -0,0 +1,182 @@
+package lockstate
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/shadyabhi/foolock/lockstate/msg"
+)
+
+func TestPolicyAcquire(t *testing.T) {
+	m := New(WithPolicies(map[string]Policy{
+		"backup": {
+			DefaultTTL: time.Minute,
+			MinTTL:     10 * time.Second,
+			MaxTTL:     10 * time.Minute,
+			Clients:    []string{"client1", "client2"},
+		},
+	}))
+
+	tests := []struct {
+		name    string
+		job     string
+		client  string
+		ttl     time.Duration
+		success bool
+		message string
+	}{
+		{"ttl below min", "backup", "client1", time.Second, false, msg.TTLOutOfRange},
+		{"ttl above max", "backup", "client1", time.Hour, false, msg.TTLOutOfRange},
+		{"client not permitted", "backup", "client3", time.Minute, false, msg.ClientNotPermitted},
+		{"negative ttl on job without policy", "other", "client1", -time.Second, false, msg.TTLOutOfRange},
+		{"job without policy uses defaults", "other", "client3", 0, true, msg.Acquired},
+		{"permitted client within range", "backup", "client1", time.Minute, true, msg.Acquired},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := m.Acquire(tt.job, tt.client, tt.ttl)
+			if result.Success != tt.success {
+				t.Errorf("Success = %v, want %v", result.Success, tt.success)
+			}
+			if result.Message != tt.message {
+				t.Errorf("Message = %q, want %q", result.Message, tt.message)
+			}
+		})
+	}
+}
+
+func TestPolicyDefaults(t *testing.T) {
+	m := New(
+		WithTTL(time.Minute),
+		WithPolicies(map[string]Policy{
+			"backup": {DefaultTTL: time.Hour, GracePeriod: time.Minute},
+		}),
+	)
+
+	result := m.Acquire("backup", "client1", 0)
+	if got := time.Until(result.ExpiresAt); got < 59*time.Minute {
+		t.Errorf("backup expires in %s, want about 1h", got)
+	}
+	status := m.Status("backup")
+	if got := status.GraceUntil.Sub(status.ExpiresAt); got != time.Minute {
+		t.Errorf("backup grace = %s, want 1m", got)
+	}
+
+	// Jobs without a policy fall back to the manager-wide TTL
+	result = m.Acquire("other", "client1", 0)
+	if got := time.Until(result.ExpiresAt); got > time.Minute || got < 59*time.Second {
+		t.Errorf("other expires in %s, want about 1m", got)
+	}
+}
+
+func TestPolicyStrict(t *testing.T) {
+	m := New(
+		WithPolicies(map[string]Policy{"backup": {}}),
+		WithStrictPolicies(true),
+	)
+
+	if result := m.Acquire("other", "client1", time.Minute); result.Message != msg.UnknownJob {
+		t.Errorf("Message = %q, want %q", result.Message, msg.UnknownJob)
+	}
+	if result := m.Acquire("backup", "client1", time.Minute); !result.Success {
+		t.Errorf("expected success for configured job, got %q", result.Message)
+	}
+
+	many := m.AcquireMany([]string{"backup", "other"}, "client1", time.Minute)
+	if many.Success {
+		t.Fatal("expected AcquireMany to fail when one job is unknown")
+	}
+	if len(many.Conflicts) != 1 || many.Conflicts[0].Message != msg.UnknownJob {
+		t.Errorf("conflicts = %+v, want only other as unknown", many.Conflicts)
+	}
+
+	// Requests for unknown jobs leave nothing behind
+	m.Release("other", "client1")
+	m.Status("other")
+	m.History("other")
+	m.Runs("other")
+	err := m.Restore(Snapshot{Version: snapshotVersion, Locks: []LockSnapshot{{Job: "other", Holder: "client1"}}})
+	if err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	if jobs := m.Jobs(); !slices.Equal(jobs, []string{"backup"}) {
+		t.Errorf("Jobs() = %v, want only backup", jobs)
+	}
+}
+
+func TestPolicyInherited(t *testing.T) {
+	m := New(
+		WithPolicies(map[string]Policy{
+			"/photos":     {Clients: []string{"client1"}},
+			"/photos/raw": {Clients: []string{"client2"}},
+			"photos":      {MaxTTL: time.Minute},
+		}),
+		WithStrictPolicies(true),
+	)
+
+	tests := []struct {
+		job     string
+		client  string
+		message string
+	}{
+		{"/photos/2024", "client1", msg.Acquired},
+		{"/photos/2024/jan", "client2", msg.ClientNotPermitted},
+		{"/photos/raw/2024", "client2", msg.Acquired},
+		{"/photos/raw/2024", "client1", msg.ClientNotPermitted},
+		{"/photosets", "client1", msg.UnknownJob},
+		{"/videos/2024", "client1", msg.UnknownJob},
+		{"photos/2024", "client1", msg.Acquired},
+	}
+	for _, tt := range tests {
+		if result := m.Acquire(tt.job, tt.client, time.Minute); result.Message != tt.message {
+			t.Errorf("Acquire(%q, %q) Message = %q, want %q", tt.job, tt.client, result.Message, tt.message)
+		}
+	}
+	if result := m.Acquire("photos/2025", "client1", time.Hour); result.Code != CodeTTLOutOfRange {
+		t.Errorf("Code = %q, want %q from the photos policy", result.Code, CodeTTLOutOfRange)
+	}
+}
+
+func TestPolicyMaxHold(t *testing.T) {
+	m := New(WithPolicies(map[string]Policy{
+		"backup": {MaxHold: 20 * time.Millisecond},
+	}))
+
+	if result := m.Acquire("backup", "client1", time.Minute); !result.Success {
+		t.Fatalf("acquire failed: %s", result.Message)
+	}
+	if result := m.Acquire("backup", "client1", time.Minute); !result.Success {
+		t.Fatalf("renew within max hold failed: %s", result.Message)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+
+	result := m.Acquire("backup", "client1", time.Minute)
+	if result.Success {
+		t.Fatal("expected renewal past max hold to be refused")
+	}
+	if result.Message != msg.MaxHoldExceeded {
+		t.Errorf("Message = %q, want %q", result.Message, msg.MaxHoldExceeded)
+	}
+}
+
+func TestMaxTTL(t *testing.T) {
+	m := New(
+		WithMaxTTL(time.Minute),
+		WithPolicies(map[string]Policy{
+			"backup": {MaxTTL: time.Hour},
+		}),
+	)
+
+	if result := m.Acquire("other", "client1", 2*time.Minute); result.Message != msg.TTLOutOfRange {
+		t.Errorf("Message = %q, want %q", result.Message, msg.TTLOutOfRange)
+	}
+	if result := m.Acquire("other", "client1", time.Minute); !result.Success {
+		t.Errorf("ttl at the limit refused: %s", result.Message)
+	}
+	if result := m.Acquire("backup", "client1", 2*time.Minute); !result.Success {
+		t.Errorf("policy max_ttl should override the manager default: %s", result.Message)
+	}
+}
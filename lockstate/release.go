@@ -8,35 +8,73 @@ import (
 
 type ReleaseResult struct {
 	Success bool
+	Code    Code
 	Job     string
 	Message string
 	HeldFor time.Duration
+
+	CooldownUntil time.Time
 }
 
-func (s *State) Release(client string) ReleaseResult {
+func (s *State) Release(client string, opts ...ReleaseOption) ReleaseResult {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	return s.release(client, newReleaseOptions(opts))
+}
+
+// release frees the lock if client holds it; the caller must hold s.mu
+func (s *State) release(client string, o releaseOptions) ReleaseResult {
+	now := time.Now()
+	s.observe(now)
 	if s.Holder != client {
-		return ReleaseResult{
-			Success: false,
-			Job:     s.Job,
-			Message: msg.ClientNotHolder,
-		}
+		return s.respNotHolder(now)
 	}
 
-	heldFor := time.Since(s.AcquiredAt)
+	acquiredAt := s.AcquiredAt
+	heldFor := now.Sub(acquiredAt)
 	job := s.Job
 
 	s.Holder = ""
 	s.AcquiredAt = time.Time{}
 	s.ExpiresAt = time.Time{}
 	s.GraceUntil = time.Time{}
+	s.Metadata = nil
+	s.session = ""
+	s.resetObserved()
+	s.startCooldown(client, now)
+	s.recordRun(Run{
+		Holder:     client,
+		AcquiredAt: acquiredAt,
+		ReleasedAt: now,
+		HeldFor:    heldFor,
+		Outcome:    o.outcome,
+	})
+	s.record(Event{Time: now, Type: EventReleased, Client: client, Holder: client, Message: msg.LockReleased})
 
 	return ReleaseResult{
 		Success: true,
+		Code:    CodeReleased,
 		Job:     job,
 		Message: msg.LockReleased,
 		HeldFor: heldFor,
+
+		CooldownUntil: s.CooldownUntil,
+	}
+}
+
+// respNotHolder refuses a release by a client that doesn't hold the lock,
+// telling a lock nobody holds from one someone else does; the caller must
+// hold s.mu
+func (s *State) respNotHolder(now time.Time) ReleaseResult {
+	code := CodeNotHolder
+	if !s.isHeldByAnother(now) && !s.isInGracePeriod(now) {
+		code = CodeNotHeld
+	}
+	return ReleaseResult{
+		Success: false,
+		Code:    code,
+		Job:     s.Job,
+		Message: msg.ClientNotHolder,
 	}
 }
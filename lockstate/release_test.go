@@ -13,17 +13,28 @@ func TestRelease(t *testing.T) {
 		setup   func(*State)
 		client  string
 		success bool
+		code    Code
 		message string
 	}{
 		{"release own lock", func(s *State) {
 			s.Holder = "client1"
 			s.ExpiresAt = time.Now().Add(time.Minute)
-		}, "client1", true, msg.LockReleased},
+		}, "client1", true, CodeReleased, msg.LockReleased},
 		{"release other's lock", func(s *State) {
 			s.Holder = "client1"
 			s.ExpiresAt = time.Now().Add(time.Minute)
-		}, "client2", false, msg.ClientNotHolder},
-		{"release empty lock", func(s *State) {}, "client1", false, msg.ClientNotHolder},
+		}, "client2", false, CodeNotHolder, msg.ClientNotHolder},
+		{"release other's lock in grace", func(s *State) {
+			s.Holder = "client1"
+			s.ExpiresAt = time.Now().Add(-time.Second)
+			s.GraceUntil = time.Now().Add(time.Minute)
+		}, "client2", false, CodeNotHolder, msg.ClientNotHolder},
+		{"release other's lapsed lock", func(s *State) {
+			s.Holder = "client1"
+			s.ExpiresAt = time.Now().Add(-time.Minute)
+			s.GraceUntil = time.Now().Add(-time.Second)
+		}, "client2", false, CodeNotHeld, msg.ClientNotHolder},
+		{"release empty lock", func(s *State) {}, "client1", false, CodeNotHeld, msg.ClientNotHolder},
 	}
 
 	for _, tt := range tests {
@@ -34,6 +45,9 @@ func TestRelease(t *testing.T) {
 			if result.Success != tt.success {
 				t.Errorf("Success = %v, want %v", result.Success, tt.success)
 			}
+			if result.Code != tt.code {
+				t.Errorf("Code = %q, want %q", result.Code, tt.code)
+			}
 			if result.Message != tt.message {
 				t.Errorf("Message = %q, want %q", result.Message, tt.message)
 			}
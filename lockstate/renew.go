@@ -0,0 +1,30 @@
+package lockstate
+
+import "github.com/shadyabhi/foolock/lockstate/msg"
+
+// RenewOnly refuses the acquisition with CodeNotHolder unless the client
+// already holds the lock, so a client that lost it finds out instead of
+// silently taking it again
+func RenewOnly() AcquireOption {
+	return func(o *acquireOptions) {
+		o.renewOnly = true
+	}
+}
+
+// checkRenewing refuses a renewal by a client that doesn't hold the lock; the
+// caller must hold s.mu
+func (s *State) checkRenewing(client string, o acquireOptions) (AcquireResult, bool) {
+	if !o.renewOnly || s.isCurrentHolderRenewing(client) {
+		return AcquireResult{}, false
+	}
+	return AcquireResult{
+		Success:    false,
+		Code:       CodeNotHolder,
+		Job:        s.Job,
+		Holder:     s.Holder,
+		AcquiredAt: s.AcquiredAt,
+		ExpiresAt:  s.ExpiresAt,
+		GraceUntil: s.GraceUntil,
+		Message:    msg.ClientNotHolder,
+	}, true
+}
@@ -0,0 +1,48 @@
+package lockstate
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRenewOnly(t *testing.T) {
+	m := New(WithGracePeriod(0))
+
+	result := m.Acquire("backup", "c1", time.Minute, RenewOnly())
+	if result.Success || result.Code != CodeNotHolder {
+		t.Errorf("Success = %v, Code = %q, want false, %q", result.Success, result.Code, CodeNotHolder)
+	}
+	if holder := m.Status("backup").Holder; holder != "" {
+		t.Errorf("a refused renewal doesn't acquire: Holder = %q", holder)
+	}
+
+	m.Acquire("backup", "c1", time.Minute)
+	result = m.Acquire("backup", "c1", time.Minute, RenewOnly())
+	if !result.Success || result.Code != CodeRenewed {
+		t.Errorf("Success = %v, Code = %q, want true, %q", result.Success, result.Code, CodeRenewed)
+	}
+
+	result = m.Acquire("backup", "c2", time.Minute, RenewOnly())
+	if result.Code != CodeNotHolder {
+		t.Errorf("Code = %q, want %q", result.Code, CodeNotHolder)
+	}
+	if result.Holder != "c1" {
+		t.Errorf("Holder = %q, want c1", result.Holder)
+	}
+}
+
+func TestRenewOnlyAfterTakeover(t *testing.T) {
+	m := New(WithGracePeriod(0))
+
+	m.Acquire("backup", "c1", time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	m.Acquire("backup", "c2", time.Minute)
+
+	result := m.Acquire("backup", "c1", time.Minute, RenewOnly())
+	if result.Code != CodeNotHolder {
+		t.Errorf("Code = %q, want %q", result.Code, CodeNotHolder)
+	}
+	if result.Holder != "c2" {
+		t.Errorf("Holder = %q, want c2", result.Holder)
+	}
+}
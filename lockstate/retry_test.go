@@ -0,0 +1,54 @@
+package lockstate
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shadyabhi/foolock/lockstate/msg"
+)
+
+func TestAvailableAt(t *testing.T) {
+	m := New(WithGracePeriod(time.Minute), WithCooldown(time.Hour))
+
+	m.Acquire("backup", "c1", time.Minute)
+
+	result := m.Acquire("backup", "c2", time.Minute)
+	if result.Success {
+		t.Fatal("expected the held lock to be refused")
+	}
+	if !result.AvailableAt.Equal(result.GraceUntil) {
+		t.Errorf("AvailableAt = %s, want GraceUntil %s", result.AvailableAt, result.GraceUntil)
+	}
+	if !result.AvailableAt.After(result.ExpiresAt) {
+		t.Errorf("AvailableAt = %s, want after ExpiresAt %s", result.AvailableAt, result.ExpiresAt)
+	}
+
+	result = m.Acquire("backup/db", "c2", time.Minute)
+	if result.Message != msg.RelatedPathHeld {
+		t.Errorf("Message = %q, want %q", result.Message, msg.RelatedPathHeld)
+	}
+	if !result.AvailableAt.Equal(result.GraceUntil) {
+		t.Errorf("related path AvailableAt = %s, want GraceUntil %s", result.AvailableAt, result.GraceUntil)
+	}
+
+	m.Release("backup", "c1")
+	result = m.Acquire("backup", "c2", time.Minute)
+	if result.Message != msg.CooldownActive {
+		t.Errorf("Message = %q, want %q", result.Message, msg.CooldownActive)
+	}
+	if !result.AvailableAt.Equal(result.CooldownUntil) {
+		t.Errorf("cooldown AvailableAt = %s, want CooldownUntil %s", result.AvailableAt, result.CooldownUntil)
+	}
+}
+
+func TestAvailableAtNotRetryable(t *testing.T) {
+	m := New(WithPolicies(map[string]Policy{"backup": {Clients: []string{"c1"}}}))
+
+	result := m.Acquire("backup", "c2", time.Minute)
+	if result.Message != msg.ClientNotPermitted {
+		t.Errorf("Message = %q, want %q", result.Message, msg.ClientNotPermitted)
+	}
+	if !result.AvailableAt.IsZero() {
+		t.Errorf("AvailableAt = %s, want zero", result.AvailableAt)
+	}
+}
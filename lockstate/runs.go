@@ -0,0 +1,113 @@
+package lockstate
+
+import (
+	"time"
+
+	"github.com/shadyabhi/foolock/lockstate/msg"
+)
+
+const defaultRunsSize = 50
+
+// Outcome is how a job run went, as reported by the client on release
+type Outcome struct {
+	Success  bool
+	ExitCode int
+	Message  string
+	// Abandoned is set when the holder let its lease and grace period lapse
+	// instead of releasing, typically because it crashed
+	Abandoned bool
+}
+
+// Run records one tenure of a lock, from acquisition to release, or to the
+// end of the lease if the holder never released it
+type Run struct {
+	Job        string
+	Holder     string
+	AcquiredAt time.Time
+	ReleasedAt time.Time
+	HeldFor    time.Duration
+	// Outcome is nil when the client didn't report one
+	Outcome *Outcome
+}
+
+type releaseOptions struct {
+	outcome *Outcome
+}
+
+// ReleaseOption tweaks a single release
+type ReleaseOption func(*releaseOptions)
+
+// WithOutcome reports how the run that held the lock went
+func WithOutcome(o Outcome) ReleaseOption {
+	return func(opts *releaseOptions) {
+		opts.outcome = &o
+	}
+}
+
+// Succeeded marks the run that held the lock as successfully completed
+func Succeeded() ReleaseOption {
+	return WithOutcome(Outcome{Success: true})
+}
+
+func newReleaseOptions(opts []ReleaseOption) releaseOptions {
+	var o releaseOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithRunsSize sets how many runs are kept per job
+func WithRunsSize(n int) Option {
+	return func(m *Manager) {
+		m.runsSize = n
+	}
+}
+
+// recordRun stores a finished run and updates the last success or failure;
+// the caller must hold s.mu
+func (s *State) recordRun(run Run) {
+	run.Job = s.Job
+	if s.runs != nil {
+		s.runs.add(run)
+	}
+
+	switch {
+	case run.Outcome == nil:
+	case run.Outcome.Success:
+		s.LastSuccessAt = run.ReleasedAt
+		s.LastSuccessBy = run.Holder
+	default:
+		s.LastFailureAt = run.ReleasedAt
+		s.LastFailureBy = run.Holder
+	}
+}
+
+// recordAbandoned records the holder's tenure as a failed run once its lease
+// has lapsed for good; the caller must hold s.mu
+func (s *State) recordAbandoned() {
+	s.recordRun(Run{
+		Holder:     s.Holder,
+		AcquiredAt: s.AcquiredAt,
+		ReleasedAt: s.ExpiresAt,
+		HeldFor:    s.ExpiresAt.Sub(s.AcquiredAt),
+		Outcome:    &Outcome{Abandoned: true, Message: msg.LeaseAbandoned},
+	})
+}
+
+// Runs returns the recorded runs for the lock, oldest first
+func (s *State) Runs() []Run {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.runs == nil {
+		return nil
+	}
+	return s.runs.list()
+}
+
+// Runs returns the recorded runs for a job, oldest first
+func (m *Manager) Runs(job string) []Run {
+	s := m.getOrCreateLock(job)
+	return s.Runs()
+}
@@ -0,0 +1,112 @@
+package lockstate
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRuns(t *testing.T) {
+	m := New()
+
+	m.Acquire("backup", "client1", time.Minute)
+	m.Release("backup", "client1", WithOutcome(Outcome{Success: false, ExitCode: 2, Message: "disk full"}))
+
+	m.Acquire("backup", "client2", time.Minute)
+	m.Release("backup", "client2", Succeeded())
+
+	m.Acquire("backup", "client1", time.Minute)
+	m.Release("backup", "client1")
+
+	// A refused release is not a run
+	m.Release("backup", "client3", Succeeded())
+
+	runs := m.Runs("backup")
+	if len(runs) != 3 {
+		t.Fatalf("got %d runs, want 3", len(runs))
+	}
+
+	failed := runs[0]
+	if failed.Holder != "client1" || failed.Job != "backup" {
+		t.Errorf("run 0 = %+v, want backup held by client1", failed)
+	}
+	if failed.Outcome == nil || failed.Outcome.Success || failed.Outcome.ExitCode != 2 || failed.Outcome.Message != "disk full" {
+		t.Errorf("run 0 outcome = %+v, want failure with exit code 2", failed.Outcome)
+	}
+	if failed.AcquiredAt.IsZero() || failed.ReleasedAt.Before(failed.AcquiredAt) {
+		t.Errorf("run 0 times = %s..%s", failed.AcquiredAt, failed.ReleasedAt)
+	}
+	if failed.HeldFor != failed.ReleasedAt.Sub(failed.AcquiredAt) {
+		t.Errorf("run 0 HeldFor = %s, want ReleasedAt - AcquiredAt", failed.HeldFor)
+	}
+
+	if runs[1].Outcome == nil || !runs[1].Outcome.Success {
+		t.Errorf("run 1 outcome = %+v, want success", runs[1].Outcome)
+	}
+	if runs[2].Outcome != nil {
+		t.Errorf("run 2 outcome = %+v, want nil", runs[2].Outcome)
+	}
+
+	status := m.Status("backup")
+	if status.LastSuccessBy != "client2" || status.LastSuccessAt != runs[1].ReleasedAt {
+		t.Errorf("last success = %s by %q, want run 1", status.LastSuccessAt, status.LastSuccessBy)
+	}
+	if status.LastFailureBy != "client1" || status.LastFailureAt != runs[0].ReleasedAt {
+		t.Errorf("last failure = %s by %q, want run 0", status.LastFailureAt, status.LastFailureBy)
+	}
+}
+
+func TestRunsAbandoned(t *testing.T) {
+	m := New(WithGracePeriod(10 * time.Millisecond))
+
+	m.Acquire("backup", "client1", 10*time.Millisecond)
+	time.Sleep(30 * time.Millisecond)
+	// Looking at the job notices the lease is gone for good
+	m.Status("backup")
+	// Taking over doesn't record the same run again
+	m.Acquire("backup", "client2", time.Minute)
+
+	runs := m.Runs("backup")
+	if len(runs) != 1 {
+		t.Fatalf("got %d runs, want 1", len(runs))
+	}
+	run := runs[0]
+	if run.Holder != "client1" || run.Outcome == nil || !run.Outcome.Abandoned || run.Outcome.Success {
+		t.Errorf("run = %+v outcome %+v, want abandoned by client1", run, run.Outcome)
+	}
+	if run.HeldFor != 10*time.Millisecond {
+		t.Errorf("HeldFor = %s, want the 10ms lease", run.HeldFor)
+	}
+	if status := m.Status("backup"); status.LastFailureBy != "client1" || !status.LastFailureAt.Equal(run.ReleasedAt) {
+		t.Errorf("last failure = %s at %s, want client1 at %s", status.LastFailureBy, status.LastFailureAt, run.ReleasedAt)
+	}
+}
+
+func TestRunsTakenOverAtExpiry(t *testing.T) {
+	m := New(WithGracePeriod(time.Minute))
+	m.Acquire("backup", "client1", time.Minute)
+
+	// Take over exactly when the lease ends, before the grace period is seen
+	// out; the abandoned run still has to be recorded
+	s := m.getOrCreateLock("backup")
+	s.mu.Lock()
+	s.ExpiresAt = time.Now().Add(-time.Second)
+	s.GraceUntil = s.ExpiresAt
+	s.acquireLock("client2", time.Now(), time.Minute)
+	s.mu.Unlock()
+
+	runs := m.Runs("backup")
+	if len(runs) != 1 || runs[0].Holder != "client1" || !runs[0].Outcome.Abandoned {
+		t.Fatalf("runs = %+v, want client1's abandoned run", runs)
+	}
+}
+
+func TestRunsSize(t *testing.T) {
+	m := New(WithRunsSize(2))
+	for range 3 {
+		m.Acquire("backup", "client1", time.Minute)
+		m.Release("backup", "client1")
+	}
+	if runs := m.Runs("backup"); len(runs) != 2 {
+		t.Errorf("got %d runs, want 2", len(runs))
+	}
+}
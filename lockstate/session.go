@@ -0,0 +1,269 @@
+package lockstate
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/shadyabhi/foolock/lockstate/msg"
+)
+
+// Session is a lease shared by the locks bound to it: one heartbeat renews
+// all of them, and when the session expires they all enter their grace
+// periods at once. Locks are bound by acquiring them with InSession, and
+// unbound by being released or renewed outside the session.
+type Session struct {
+	ID        string
+	Client    string
+	TTL       time.Duration
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	// Jobs are the locks bound to the session, sorted
+	Jobs []string
+}
+
+// SessionResult is the outcome of creating, renewing or closing a session
+type SessionResult struct {
+	Success bool
+	Code    Code
+	Message string
+	Session Session
+	// Released holds the locks a closed session released
+	Released []ReleaseResult
+}
+
+// sessions is the manager's session table. Its mutex may be taken while
+// holding a state's, never the other way round.
+type sessions struct {
+	mu   sync.Mutex
+	byID map[string]*Session
+	// bound holds the states acquired in each session. Entries go stale when
+	// a lock is released or leaves its session, so users check the binding
+	// under the state's mutex and prune the ones that no longer hold.
+	bound map[string]map[*State]struct{}
+}
+
+// live returns the session unless it doesn't exist or has expired, dropping
+// it in that case; the caller must hold t.mu
+func (t *sessions) live(id string, now time.Time) (*Session, bool) {
+	sess, ok := t.byID[id]
+	if !ok {
+		return nil, false
+	}
+	if !now.Before(sess.ExpiresAt) {
+		t.drop(id)
+		return nil, false
+	}
+	return sess, true
+}
+
+// drop forgets a session; the caller must hold t.mu
+func (t *sessions) drop(id string) {
+	delete(t.byID, id)
+	delete(t.bound, id)
+}
+
+// bind records s as acquired in session id
+func (t *sessions) bind(id string, s *State) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.bound == nil {
+		t.bound = make(map[string]map[*State]struct{})
+	}
+	if t.bound[id] == nil {
+		t.bound[id] = make(map[*State]struct{})
+	}
+	t.bound[id][s] = struct{}{}
+}
+
+// unbind forgets that s was acquired in session id
+func (t *sessions) unbind(id string, s *State) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.bound[id], s)
+}
+
+// states returns the states recorded as acquired in session id
+func (t *sessions) states(id string) []*State {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return slices.Collect(maps.Keys(t.bound[id]))
+}
+
+// get returns a copy of a live session
+func (t *sessions) get(id string, now time.Time) (Session, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	sess, ok := t.live(id, now)
+	if !ok {
+		return Session{}, false
+	}
+	return *sess, true
+}
+
+// InSession binds the acquired lock to a session, so its lease follows the
+// session's instead of the ttl. The session must belong to the acquiring
+// client.
+func InSession(id string) AcquireOption {
+	return func(o *acquireOptions) {
+		o.session = id
+	}
+}
+
+// CreateSession starts a session for client lasting ttl from each heartbeat;
+// a zero ttl uses the manager default
+func (m *Manager) CreateSession(client string, ttl time.Duration) SessionResult {
+	if ttl == 0 {
+		ttl = m.ttl
+	}
+	if ttl <= 0 || (m.maxTTL > 0 && ttl > m.maxTTL) {
+		return SessionResult{Code: CodeTTLOutOfRange, Message: msg.TTLOutOfRange}
+	}
+	if m.draining.Load() {
+		return SessionResult{Code: CodeDraining, Message: msg.Draining}
+	}
+
+	now := time.Now()
+	sess := &Session{
+		ID:        newSessionID(),
+		Client:    client,
+		TTL:       ttl,
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+	}
+
+	m.sessions.mu.Lock()
+	defer m.sessions.mu.Unlock()
+	if m.sessions.byID == nil {
+		m.sessions.byID = make(map[string]*Session)
+	}
+	// Expired sessions are otherwise only dropped when looked up
+	for id := range m.sessions.byID {
+		m.sessions.live(id, now)
+	}
+	m.sessions.byID[sess.ID] = sess
+	return SessionResult{Success: true, Code: CodeSessionCreated, Message: msg.SessionCreated, Session: *sess}
+}
+
+// Session returns a live session and the locks bound to it
+func (m *Manager) Session(id string) (Session, bool) {
+	sess, ok := m.sessions.get(id, time.Now())
+	if !ok {
+		return Session{}, false
+	}
+	sess.Jobs = m.eachBound(sess, func(*State) bool { return true })
+	return sess, true
+}
+
+// Heartbeat renews a session and every lock bound to it, except those past
+// their maximum hold time. An expired session can't be renewed: its holder
+// has to start a new one and reclaim its locks within their grace periods.
+func (m *Manager) Heartbeat(id string) SessionResult {
+	now := time.Now()
+	m.sessions.mu.Lock()
+	live, ok := m.sessions.live(id, now)
+	if ok {
+		live.ExpiresAt = now.Add(live.TTL)
+	}
+	var sess Session
+	if ok {
+		sess = *live
+	}
+	m.sessions.mu.Unlock()
+	if !ok {
+		return SessionResult{Code: CodeSessionNotFound, Message: msg.SessionNotFound}
+	}
+
+	sess.Jobs = m.eachBound(sess, func(s *State) bool {
+		if !now.Before(s.ExpiresAt) || s.isMaxHoldExceeded(now) {
+			return false
+		}
+		// A concurrent heartbeat may have moved the session on since
+		current, ok := m.sessions.get(id, now)
+		if !ok {
+			return false
+		}
+		s.respRenewLock(now, current.ExpiresAt.Sub(now))
+		return true
+	})
+	return SessionResult{Success: true, Code: CodeSessionRenewed, Message: msg.SessionRenewed, Session: sess}
+}
+
+// CloseSession ends a session and releases the locks bound to it
+func (m *Manager) CloseSession(id string, opts ...ReleaseOption) SessionResult {
+	o := newReleaseOptions(opts)
+	now := time.Now()
+	m.sessions.mu.Lock()
+	live, ok := m.sessions.live(id, now)
+	var sess Session
+	if ok {
+		sess = *live
+		delete(m.sessions.byID, id)
+	}
+	m.sessions.mu.Unlock()
+	if !ok {
+		return SessionResult{Code: CodeSessionNotFound, Message: msg.SessionNotFound}
+	}
+
+	var released []ReleaseResult
+	sess.Jobs = m.eachBound(sess, func(s *State) bool {
+		released = append(released, s.release(sess.Client, o))
+		return true
+	})
+	m.sessions.mu.Lock()
+	m.sessions.drop(id)
+	m.sessions.mu.Unlock()
+	return SessionResult{Success: true, Code: CodeSessionClosed, Message: msg.SessionClosed, Session: sess, Released: released}
+}
+
+// eachBound calls fn on every lock sess's client holds through it, with the
+// state locked, and returns the sorted jobs for which fn returned true. Only
+// the locks recorded as acquired in the session are looked at.
+func (m *Manager) eachBound(sess Session, fn func(*State) bool) []string {
+	jobs := []string{}
+	for _, s := range m.sessions.states(sess.ID) {
+		s.mu.Lock()
+		switch {
+		case s.session != sess.ID || s.Holder != sess.Client:
+			m.sessions.unbind(sess.ID, s)
+		case fn(s):
+			jobs = append(jobs, s.Job)
+		}
+		s.mu.Unlock()
+	}
+	slices.Sort(jobs)
+	return jobs
+}
+
+// bindSession records s as acquired in the session result names, if any, and
+// passes result on; the caller must hold s.mu
+func (m *Manager) bindSession(s *State, result AcquireResult) AcquireResult {
+	if result.Success && result.Session != "" {
+		m.sessions.bind(result.Session, s)
+	}
+	return result
+}
+
+// sessionLease resolves an acquisition bound to a session into the ttl its
+// policy is checked against, the session's, and when the lease ends, with
+// the session; ok is false, with the refusal, if the session can't be used.
+// The caller must hold s.mu.
+func (m *Manager) sessionLease(s *State, client, id string, now time.Time) (time.Duration, time.Time, AcquireResult, bool) {
+	sess, ok := m.sessions.get(id, now)
+	if !ok {
+		return 0, time.Time{}, s.respRejected(CodeSessionNotFound, msg.SessionNotFound), false
+	}
+	if sess.Client != client {
+		return 0, time.Time{}, s.respRejected(CodeClientNotPermitted, msg.SessionOfAnotherClient), false
+	}
+	return sess.TTL, sess.ExpiresAt, AcquireResult{}, true
+}
+
+func newSessionID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
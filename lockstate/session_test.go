@@ -0,0 +1,281 @@
+package lockstate
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shadyabhi/foolock/lockstate/msg"
+)
+
+func TestSessionLifecycle(t *testing.T) {
+	m := New()
+
+	created := m.CreateSession("c1", time.Minute)
+	if !created.Success || created.Code != CodeSessionCreated {
+		t.Fatalf("CreateSession: Success = %v, Code = %q", created.Success, created.Code)
+	}
+	id := created.Session.ID
+	if len(id) != 32 {
+		t.Errorf("len(ID) = %d, want 32", len(id))
+	}
+	if d := time.Until(created.Session.ExpiresAt) - time.Minute; d < -time.Second || d > time.Second {
+		t.Errorf("ExpiresAt = %s, want a minute from now", created.Session.ExpiresAt)
+	}
+
+	// The ttl is the session's, whatever the acquisition asks for
+	result := m.Acquire("backup", "c1", time.Hour, InSession(id))
+	if !result.Success {
+		t.Fatalf("Acquire in session: Code = %q", result.Code)
+	}
+	if result.Session != id {
+		t.Errorf("Session = %q, want %q", result.Session, id)
+	}
+	if !result.ExpiresAt.Equal(created.Session.ExpiresAt) {
+		t.Errorf("the lease ends with the session: ExpiresAt = %s, want %s", result.ExpiresAt, created.Session.ExpiresAt)
+	}
+	if many := m.AcquireMany([]string{"sync", "/photos"}, "c1", 0, InSession(id)); !many.Success {
+		t.Fatalf("AcquireMany in session failed: %+v", many.Conflicts)
+	}
+	m.Acquire("other", "c1", time.Minute)
+
+	sess, ok := m.Session(id)
+	if !ok {
+		t.Fatalf("session %s not found", id)
+	}
+	if want := []string{"/photos", "backup", "sync"}; !slices.Equal(sess.Jobs, want) {
+		t.Errorf("Jobs = %v, want %v", sess.Jobs, want)
+	}
+	if got := m.Status("backup").Session; got != id {
+		t.Errorf("backup Session = %q, want %q", got, id)
+	}
+	if got := m.Status("other").Session; got != "" {
+		t.Errorf("other Session = %q, want none", got)
+	}
+
+	// Renewing outside the session unbinds the lock
+	m.Acquire("sync", "c1", time.Minute)
+	sess, _ = m.Session(id)
+	if want := []string{"/photos", "backup"}; !slices.Equal(sess.Jobs, want) {
+		t.Errorf("Jobs = %v, want %v", sess.Jobs, want)
+	}
+	if n := len(m.sessions.states(id)); n != 2 {
+		t.Errorf("the unbound lock is forgotten: %d bound states, want 2", n)
+	}
+
+	closed := m.CloseSession(id, Succeeded())
+	if !closed.Success || closed.Code != CodeSessionClosed {
+		t.Errorf("CloseSession: Success = %v, Code = %q", closed.Success, closed.Code)
+	}
+	if len(closed.Released) != 2 {
+		t.Errorf("len(Released) = %d, want 2", len(closed.Released))
+	}
+	for _, job := range []string{"backup", "/photos"} {
+		if holder := m.Status(job).Holder; holder != "" {
+			t.Errorf("%s Holder = %q after closing the session", job, holder)
+		}
+	}
+	if holder := m.Status("sync").Holder; holder != "c1" {
+		t.Errorf("unbound locks stay held: Holder = %q, want c1", holder)
+	}
+	if by := m.Status("backup").LastSuccessBy; by != "c1" {
+		t.Errorf("LastSuccessBy = %q, want c1", by)
+	}
+
+	if _, ok := m.Session(id); ok {
+		t.Error("expected the closed session to be gone")
+	}
+	if n := len(m.sessions.states(id)); n != 0 {
+		t.Errorf("%d bound states after close, want 0", n)
+	}
+	codes := []Code{
+		m.Heartbeat(id).Code,
+		m.CloseSession(id).Code,
+		m.Acquire("backup", "c1", 0, InSession(id)).Code,
+	}
+	for _, code := range codes {
+		if code != CodeSessionNotFound {
+			t.Errorf("Code = %q, want %q", code, CodeSessionNotFound)
+		}
+	}
+}
+
+func TestSessionHeartbeat(t *testing.T) {
+	m := New()
+	id := m.CreateSession("c1", 100*time.Millisecond).Session.ID
+	m.Acquire("backup", "c1", 0, InSession(id))
+	m.Acquire("sync", "c1", 0, InSession(id))
+	m.Acquire("other", "c1", 100*time.Millisecond)
+
+	for range 3 {
+		time.Sleep(60 * time.Millisecond)
+		result := m.Heartbeat(id)
+		if !result.Success || result.Code != CodeSessionRenewed {
+			t.Fatalf("Heartbeat: Success = %v, Code = %q", result.Success, result.Code)
+		}
+		if want := []string{"backup", "sync"}; !slices.Equal(result.Session.Jobs, want) {
+			t.Errorf("Jobs = %v, want %v", result.Session.Jobs, want)
+		}
+	}
+
+	backup := m.Status("backup")
+	if backup.IsExpired {
+		t.Error("one heartbeat keeps every bound lock alive")
+	}
+	if sync := m.Status("sync").ExpiresAt; !sync.Equal(backup.ExpiresAt) {
+		t.Errorf("sync ExpiresAt = %s, want %s", sync, backup.ExpiresAt)
+	}
+	if !m.Status("other").IsExpired {
+		t.Error("expected the lock outside the session to expire")
+	}
+	if typ := m.History("backup")[1].Type; typ != EventRenewed {
+		t.Errorf("History[1].Type = %q, want %q", typ, EventRenewed)
+	}
+}
+
+func TestSessionExpiry(t *testing.T) {
+	m := New(WithGracePeriod(time.Minute))
+	id := m.CreateSession("c1", 20*time.Millisecond).Session.ID
+	m.Acquire("backup", "c1", 0, InSession(id))
+	m.Acquire("sync", "c1", 0, InSession(id))
+	time.Sleep(30 * time.Millisecond)
+
+	// Every bound lock enters its grace period when the session expires
+	for _, job := range []string{"backup", "sync"} {
+		if !m.Status(job).InGrace {
+			t.Errorf("%s not in grace after the session expired", job)
+		}
+		if code := m.Acquire(job, "c2", time.Minute).Code; code != CodeGraceActive {
+			t.Errorf("%s Code = %q, want %q", job, code, CodeGraceActive)
+		}
+	}
+	if code := m.Heartbeat(id).Code; code != CodeSessionNotFound {
+		t.Errorf("Heartbeat Code = %q, want %q", code, CodeSessionNotFound)
+	}
+
+	// The holder reclaims them with a new session
+	id = m.CreateSession("c1", time.Minute).Session.ID
+	result := m.AcquireMany([]string{"backup", "sync"}, "c1", 0, InSession(id))
+	if !result.Success {
+		t.Fatalf("AcquireMany failed: %+v", result.Conflicts)
+	}
+	for _, r := range result.Acquired {
+		if r.Code != CodeReclaimed || r.Session != id {
+			t.Errorf("%s: Code = %q, Session = %q, want %q, %q", r.Job, r.Code, r.Session, CodeReclaimed, id)
+		}
+	}
+}
+
+func TestSessionRefusals(t *testing.T) {
+	m := New(
+		WithMaxTTL(time.Hour),
+		WithPolicies(map[string]Policy{"backup": {MinTTL: time.Minute}}),
+	)
+
+	for _, ttl := range []time.Duration{2 * time.Hour, -time.Second} {
+		if code := m.CreateSession("c1", ttl).Code; code != CodeTTLOutOfRange {
+			t.Errorf("CreateSession(%s) Code = %q, want %q", ttl, code, CodeTTLOutOfRange)
+		}
+	}
+	if ttl := m.CreateSession("c1", 0).Session.TTL; ttl != defaultTTL {
+		t.Errorf("TTL = %s, want %s", ttl, defaultTTL)
+	}
+
+	id := m.CreateSession("c1", 30*time.Second).Session.ID
+	result := m.Acquire("backup", "c1", 0, InSession(id))
+	if result.Code != CodeTTLOutOfRange {
+		t.Errorf("the session's ttl is checked against the job's policy: Code = %q", result.Code)
+	}
+
+	result = m.Acquire("sync", "c2", 0, InSession(id))
+	if result.Code != CodeClientNotPermitted {
+		t.Errorf("Code = %q, want %q", result.Code, CodeClientNotPermitted)
+	}
+	if result.Message != msg.SessionOfAnotherClient {
+		t.Errorf("Message = %q, want %q", result.Message, msg.SessionOfAnotherClient)
+	}
+
+	many := m.AcquireMany([]string{"sync", "cron"}, "c1", 0, InSession("nope"))
+	if many.Success {
+		t.Error("expected AcquireMany with an unknown session to fail")
+	}
+	if len(many.Conflicts) != 2 || many.Conflicts[0].Code != CodeSessionNotFound {
+		t.Errorf("Conflicts = %+v, want two %q conflicts", many.Conflicts, CodeSessionNotFound)
+	}
+	if holder := m.Status("sync").Holder; holder != "" {
+		t.Errorf("Holder = %q, want none", holder)
+	}
+
+	m.SetDraining(true)
+	if code := m.CreateSession("c1", time.Minute).Code; code != CodeDraining {
+		t.Errorf("Code = %q, want %q", code, CodeDraining)
+	}
+	if !m.Heartbeat(id).Success {
+		t.Error("existing sessions keep renewing while draining")
+	}
+}
+
+func TestSessionMaxHold(t *testing.T) {
+	m := New(WithMaxHold(50 * time.Millisecond))
+	id := m.CreateSession("c1", time.Minute).Session.ID
+	result := m.Acquire("backup", "c1", 0, InSession(id))
+	if !result.ExpiresAt.Equal(result.MaxHoldUntil) {
+		t.Errorf("the lease stops at the maximum hold time: ExpiresAt = %s, want %s", result.ExpiresAt, result.MaxHoldUntil)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if jobs := m.Heartbeat(id).Session.Jobs; len(jobs) != 0 {
+		t.Errorf("locks past their maximum hold time aren't renewed: Jobs = %v", jobs)
+	}
+	if !m.Status("backup").IsExpired {
+		t.Error("expected the lock to expire at its maximum hold time")
+	}
+}
+
+func TestSessionSnapshot(t *testing.T) {
+	m := New()
+	id := m.CreateSession("c1", time.Minute).Session.ID
+	m.Acquire("backup", "c1", 0, InSession(id))
+	m.CreateSession("c2", time.Nanosecond)
+
+	data, err := json.Marshal(m.Snapshot())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"ttl":"1m0s"`) {
+		t.Errorf("snapshot %s doesn't store the ttl as a duration", data)
+	}
+	var snap Snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		t.Fatal(err)
+	}
+	if len(snap.Sessions) != 1 {
+		t.Fatalf("expired sessions aren't carried over: len(Sessions) = %d, want 1", len(snap.Sessions))
+	}
+
+	restored := New()
+	if err := restored.Restore(snap); err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	sess, ok := restored.Session(id)
+	if !ok {
+		t.Fatalf("session %s not restored", id)
+	}
+	if sess.Client != "c1" {
+		t.Errorf("Client = %q, want c1", sess.Client)
+	}
+	want := []string{"backup"}
+	if !slices.Equal(sess.Jobs, want) {
+		t.Errorf("Jobs = %v, want %v", sess.Jobs, want)
+	}
+	if jobs := restored.Heartbeat(id).Session.Jobs; !slices.Equal(jobs, want) {
+		t.Errorf("Heartbeat Jobs = %v, want %v", jobs, want)
+	}
+
+	snap.Sessions[0].TTL = "soon"
+	if err := New().Restore(snap); err == nil {
+		t.Error("expected an error restoring a session with an invalid ttl")
+	}
+}
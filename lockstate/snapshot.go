@@ -0,0 +1,172 @@
+package lockstate
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+)
+
+// snapshotVersion is bumped whenever Snapshot changes incompatibly
+const snapshotVersion = 1
+
+// Snapshot is the part of a manager's state worth carrying across a restart:
+// who holds what until when, the live sessions, plus the bookkeeping behind
+// cooldowns and min_interval. History and runs are not included.
+type Snapshot struct {
+	Version  int               `json:"version"`
+	TakenAt  time.Time         `json:"taken_at"`
+	Locks    []LockSnapshot    `json:"locks"`
+	Sessions []SessionSnapshot `json:"sessions,omitempty"`
+}
+
+type LockSnapshot struct {
+	Job        string            `json:"job"`
+	Holder     string            `json:"holder,omitempty"`
+	AcquiredAt time.Time         `json:"acquired_at,omitzero"`
+	ExpiresAt  time.Time         `json:"expires_at,omitzero"`
+	GraceUntil time.Time         `json:"grace_until,omitzero"`
+	Metadata   map[string]string `json:"metadata,omitempty"`
+	Session    string            `json:"session,omitempty"`
+
+	CooldownUntil time.Time `json:"cooldown_until,omitzero"`
+	ReleasedBy    string    `json:"released_by,omitempty"`
+
+	LastAcquiredAt time.Time `json:"last_acquired_at,omitzero"`
+	LastSuccessAt  time.Time `json:"last_success_at,omitzero"`
+	LastSuccessBy  string    `json:"last_success_by,omitempty"`
+	LastFailureAt  time.Time `json:"last_failure_at,omitzero"`
+	LastFailureBy  string    `json:"last_failure_by,omitempty"`
+}
+
+type SessionSnapshot struct {
+	ID     string `json:"id"`
+	Client string `json:"client"`
+	// TTL is a Go duration, such as "1m0s"
+	TTL       string    `json:"ttl"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Snapshot captures the state of every known job, sorted by job
+func (m *Manager) Snapshot() Snapshot {
+	snap := Snapshot{Version: snapshotVersion, TakenAt: time.Now()}
+	for _, job := range m.Jobs() {
+		s := m.getOrCreateLock(job)
+		s.mu.Lock()
+		snap.Locks = append(snap.Locks, LockSnapshot{
+			Job:        s.Job,
+			Holder:     s.Holder,
+			AcquiredAt: s.AcquiredAt,
+			ExpiresAt:  s.ExpiresAt,
+			GraceUntil: s.GraceUntil,
+			Metadata:   maps.Clone(s.Metadata),
+			Session:    s.session,
+
+			CooldownUntil: s.CooldownUntil,
+			ReleasedBy:    s.releasedBy,
+
+			LastAcquiredAt: s.LastAcquiredAt,
+			LastSuccessAt:  s.LastSuccessAt,
+			LastSuccessBy:  s.LastSuccessBy,
+			LastFailureAt:  s.LastFailureAt,
+			LastFailureBy:  s.LastFailureBy,
+		})
+		s.mu.Unlock()
+	}
+
+	m.sessions.mu.Lock()
+	defer m.sessions.mu.Unlock()
+	for _, sess := range m.sessions.byID {
+		if snap.TakenAt.Before(sess.ExpiresAt) {
+			snap.Sessions = append(snap.Sessions, SessionSnapshot{
+				ID:        sess.ID,
+				Client:    sess.Client,
+				TTL:       sess.TTL.String(),
+				CreatedAt: sess.CreatedAt,
+				ExpiresAt: sess.ExpiresAt,
+			})
+		}
+	}
+	slices.SortFunc(snap.Sessions, func(a, b SessionSnapshot) int {
+		return strings.Compare(a.ID, b.ID)
+	})
+	return snap
+}
+
+// Restore loads a snapshot into the manager, replacing the state of the jobs
+// it names. Policies are applied as for any new job, and the locks of jobs
+// strict mode refuses are dropped. Restored times are
+// rebased onto time.Now(), so from then on they carry a monotonic clock
+// reading like any other deadline; they are only as accurate as the wall
+// clocks of the machines involved at the moment of the restore.
+func (m *Manager) Restore(snap Snapshot) error {
+	if snap.Version != snapshotVersion {
+		return fmt.Errorf("unsupported snapshot version %d, want %d", snap.Version, snapshotVersion)
+	}
+
+	ttls := make([]time.Duration, len(snap.Sessions))
+	for i, sess := range snap.Sessions {
+		ttl, err := time.ParseDuration(sess.TTL)
+		if err != nil || ttl <= 0 {
+			return fmt.Errorf("session %s: invalid ttl %q", sess.ID, sess.TTL)
+		}
+		ttls[i] = ttl
+	}
+
+	now := time.Now()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, l := range snap.Locks {
+		s := m.getOrCreateLockLocked(normalizeJob(l.Job))
+		if s.unknown {
+			continue
+		}
+		s.mu.Lock()
+		s.Holder = l.Holder
+		s.AcquiredAt = rebase(l.AcquiredAt, now)
+		s.ExpiresAt = rebase(l.ExpiresAt, now)
+		s.GraceUntil = rebase(l.GraceUntil, now)
+		s.Metadata = maps.Clone(l.Metadata)
+		s.session = l.Session
+		if s.session != "" {
+			m.sessions.bind(s.session, s)
+		}
+		s.CooldownUntil = rebase(l.CooldownUntil, now)
+		s.releasedBy = l.ReleasedBy
+		s.LastAcquiredAt = rebase(l.LastAcquiredAt, now)
+		s.LastSuccessAt = rebase(l.LastSuccessAt, now)
+		s.LastSuccessBy = l.LastSuccessBy
+		s.LastFailureAt = rebase(l.LastFailureAt, now)
+		s.LastFailureBy = l.LastFailureBy
+		s.resetObserved()
+		s.mu.Unlock()
+	}
+
+	m.sessions.mu.Lock()
+	defer m.sessions.mu.Unlock()
+	if m.sessions.byID == nil {
+		m.sessions.byID = make(map[string]*Session)
+	}
+	for i, sess := range snap.Sessions {
+		m.sessions.byID[sess.ID] = &Session{
+			ID:        sess.ID,
+			Client:    sess.Client,
+			TTL:       ttls[i],
+			CreatedAt: rebase(sess.CreatedAt, now),
+			ExpiresAt: rebase(sess.ExpiresAt, now),
+		}
+	}
+	return nil
+}
+
+// rebase returns t as an offset from now, which gives it now's monotonic
+// clock reading. Zero times stay zero.
+func rebase(t, now time.Time) time.Time {
+	if t.IsZero() {
+		return t
+	}
+	return now.Add(t.Sub(now))
+}
@@ -0,0 +1,80 @@
+package lockstate
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestSnapshotRestore(t *testing.T) {
+	m := New(WithCooldown(time.Hour), WithCooldownMode(CooldownOthers))
+	m.Acquire("backup", "c1", time.Minute, WithMetadata(map[string]string{"host": "laptop1"}))
+	m.Acquire("/photos/2024", "c2", time.Minute)
+	m.Acquire("cron", "c3", time.Minute)
+	m.Release("cron", "c3", Succeeded())
+
+	data, err := json.Marshal(m.Snapshot())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var snap Snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		t.Fatal(err)
+	}
+	if len(snap.Locks) != 3 {
+		t.Fatalf("len(Locks) = %d, want 3", len(snap.Locks))
+	}
+
+	restored := New(WithCooldown(time.Hour), WithCooldownMode(CooldownOthers))
+	if err := restored.Restore(snap); err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	if got, want := restored.Jobs(), m.Jobs(); !slices.Equal(got, want) {
+		t.Errorf("Jobs() = %v, want %v", got, want)
+	}
+
+	status := restored.Status("backup")
+	if status.Holder != "c1" {
+		t.Errorf("Holder = %q, want c1", status.Holder)
+	}
+	if status.Metadata["host"] != "laptop1" {
+		t.Errorf("Metadata = %v, want host=laptop1", status.Metadata)
+	}
+	want := m.Status("backup").ExpiresAt
+	if d := status.ExpiresAt.Sub(want); d < -time.Millisecond || d > time.Millisecond {
+		t.Errorf("ExpiresAt = %s, want %s", status.ExpiresAt, want)
+	}
+
+	tests := []struct {
+		job    string
+		client string
+		code   Code
+	}{
+		{"backup", "c2", CodeHeldByAnother},
+		{"/photos", "c1", CodeRelatedPathHeld},
+		{"backup", "c1", CodeRenewed},
+	}
+	for _, tt := range tests {
+		if code := restored.Acquire(tt.job, tt.client, time.Minute).Code; code != tt.code {
+			t.Errorf("Acquire(%q, %q) Code = %q, want %q", tt.job, tt.client, code, tt.code)
+		}
+	}
+
+	if by := restored.Status("cron").LastSuccessBy; by != "c3" {
+		t.Errorf("LastSuccessBy = %q, want c3", by)
+	}
+	if code := restored.Acquire("cron", "c1", time.Minute).Code; code != CodeCooldownActive {
+		t.Errorf("Code = %q, want %q", code, CodeCooldownActive)
+	}
+	if !restored.Acquire("cron", "c3", time.Minute).Success {
+		t.Error("cooldown mode others still exempts the releaser")
+	}
+}
+
+func TestRestoreVersion(t *testing.T) {
+	if err := New().Restore(Snapshot{Version: 99}); err == nil {
+		t.Error("expected an error restoring an unknown snapshot version")
+	}
+}
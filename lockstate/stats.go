@@ -0,0 +1,33 @@
+package lockstate
+
+import "time"
+
+// Stats summarises what the manager is tracking
+type Stats struct {
+	// Jobs is the number of jobs the manager knows about
+	Jobs int
+	// Held counts locks with an unexpired lease
+	Held int
+	// InGrace counts expired locks still reserved for their holder
+	InGrace int
+}
+
+// Stats counts jobs and holders without creating any lock state
+func (m *Manager) Stats() Stats {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	now := time.Now()
+	stats := Stats{Jobs: len(m.locks)}
+	for _, s := range m.locks {
+		s.mu.Lock()
+		switch {
+		case s.isHeldByAnother(now):
+			stats.Held++
+		case s.isInGracePeriod(now):
+			stats.InGrace++
+		}
+		s.mu.Unlock()
+	}
+	return stats
+}
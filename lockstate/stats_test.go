@@ -0,0 +1,26 @@
+package lockstate
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStats(t *testing.T) {
+	m := New(WithGracePeriod(time.Minute))
+	if got := m.Stats(); got != (Stats{}) {
+		t.Errorf("Stats() = %+v, want zero", got)
+	}
+
+	m.Acquire("a", "c1", time.Minute)
+	m.Acquire("b", "c2", time.Nanosecond)
+	m.Acquire("c", "c3", time.Minute)
+	m.Release("c", "c3")
+	time.Sleep(time.Millisecond)
+
+	if got, want := m.Stats(), (Stats{Jobs: 3, Held: 1, InGrace: 1}); got != want {
+		t.Errorf("Stats() = %+v, want %+v", got, want)
+	}
+	if n := len(m.Jobs()); n != 3 {
+		t.Errorf("len(Jobs()) = %d, want 3: Stats must not create jobs", n)
+	}
+}
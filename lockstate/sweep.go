@@ -0,0 +1,38 @@
+package lockstate
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"time"
+)
+
+// Sweep records expiries and grace-period ends as they happen, checking
+// every interval until ctx is done. Without it they are only recorded when
+// the job is next looked at, so the event sink and watchers never hear about
+// a lock that lapsed on a job nobody touches again.
+func (m *Manager) Sweep(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.sweep(time.Now())
+		}
+	}
+}
+
+// sweep records the transitions every lock went through by now
+func (m *Manager) sweep(now time.Time) {
+	m.mu.RLock()
+	states := slices.Collect(maps.Values(m.locks))
+	m.mu.RUnlock()
+
+	for _, s := range states {
+		s.mu.Lock()
+		s.observe(now)
+		s.mu.Unlock()
+	}
+}
@@ -0,0 +1,61 @@
+package lockstate
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSweep(t *testing.T) {
+	var mu sync.Mutex
+	var types []EventType
+	m := New(WithGracePeriod(10*time.Millisecond), WithEventSink(func(e Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		types = append(types, e.Type)
+	}))
+	defer m.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go m.Sweep(ctx, 5*time.Millisecond)
+
+	m.Acquire("backup", "client1", 10*time.Millisecond)
+	// Nothing looks at the job again; the sweeper has to notice
+	time.Sleep(60 * time.Millisecond)
+	m.Flush()
+
+	mu.Lock()
+	defer mu.Unlock()
+	want := []EventType{EventAcquired, EventExpired, EventGraceEnded}
+	if len(types) != len(want) {
+		t.Fatalf("events = %v, want %v", types, want)
+	}
+	for i := range want {
+		if types[i] != want[i] {
+			t.Errorf("event %d = %q, want %q", i, types[i], want[i])
+		}
+	}
+}
+
+func TestCloseDeliversQueuedEvents(t *testing.T) {
+	var n int
+	m := New(WithEventSink(func(Event) {
+		time.Sleep(time.Millisecond)
+		n++
+	}))
+	for range 10 {
+		m.Acquire("backup", "client1", time.Minute)
+	}
+	m.Close()
+	if n != 10 {
+		t.Errorf("delivered %d events before Close returned, want 10", n)
+	}
+
+	m.Acquire("backup", "client1", time.Minute)
+	m.Flush()
+	if n != 10 {
+		t.Errorf("delivered %d events, want none after Close", n)
+	}
+}
@@ -0,0 +1,85 @@
+package lockstate
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+// A time.Time only prints its monotonic reading ("m=...") when it has one
+func hasMonotonic(t time.Time) bool {
+	return strings.Contains(t.String(), " m=")
+}
+
+func TestDeadlinesAreMonotonic(t *testing.T) {
+	m := New(WithMaxHold(time.Hour), WithCooldown(time.Minute))
+
+	result := m.Acquire("backup", "c1", time.Minute)
+	renewed := m.Acquire("backup", "c1", time.Minute)
+	status := m.Status("backup")
+	release := m.Release("backup", "c1")
+
+	tests := []struct {
+		name string
+		t    time.Time
+	}{
+		{"AcquiredAt", result.AcquiredAt},
+		{"ExpiresAt", result.ExpiresAt},
+		{"MaxHoldUntil", result.MaxHoldUntil},
+		{"renewed ExpiresAt", renewed.ExpiresAt},
+		{"GraceUntil", status.GraceUntil},
+		{"CooldownUntil", release.CooldownUntil},
+	}
+	for _, tt := range tests {
+		if !hasMonotonic(tt.t) {
+			t.Errorf("%s = %v, want a monotonic clock reading", tt.name, tt.t)
+		}
+	}
+}
+
+func TestRestoredDeadlinesAreMonotonic(t *testing.T) {
+	src := New()
+	src.Acquire("backup", "c1", time.Minute)
+	sess := src.CreateSession("c1", time.Minute).Session
+	src.Acquire("sync", "c1", time.Minute, InSession(sess.ID))
+
+	// A snapshot read back from disk or a handover has no monotonic readings
+	data, err := json.Marshal(src.Snapshot())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var snap Snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		t.Fatal(err)
+	}
+
+	m := New()
+	if err := m.Restore(snap); err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+
+	s := m.getOrCreateLock("backup")
+	restored, ok := m.Session(sess.ID)
+	if !ok {
+		t.Fatalf("session %s not restored", sess.ID)
+	}
+	tests := []struct {
+		name string
+		got  time.Time
+		want time.Time
+	}{
+		{"AcquiredAt", s.AcquiredAt, src.Status("backup").AcquiredAt},
+		{"ExpiresAt", s.ExpiresAt, src.Status("backup").ExpiresAt},
+		{"GraceUntil", s.GraceUntil, src.Status("backup").GraceUntil},
+		{"session ExpiresAt", restored.ExpiresAt, sess.ExpiresAt},
+	}
+	for _, tt := range tests {
+		if !hasMonotonic(tt.got) {
+			t.Errorf("%s = %v, want a monotonic clock reading", tt.name, tt.got)
+		}
+		if d := tt.got.Sub(tt.want); d < -time.Millisecond || d > time.Millisecond {
+			t.Errorf("%s = %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package lockstate
+
+import "sync"
+
+// watchers fans recorded events out to subscribers added with Watch
+type watchers struct {
+	mu   sync.Mutex
+	next int
+	subs map[int]watcher
+}
+
+type watcher struct {
+	jobs []string
+	sink EventSink
+}
+
+// matches reports whether the watcher asked for job, directly or as a path
+// beneath one of its jobs
+func (w watcher) matches(job string) bool {
+	if len(w.jobs) == 0 {
+		return true
+	}
+	for _, j := range w.jobs {
+		if j == job || isAncestor(j, job) {
+			return true
+		}
+	}
+	return false
+}
+
+// Watch calls sink for every event recorded from now on for jobs, or paths
+// beneath them, or for every job if none are given, until the returned
+// function is called. Sinks run one event at a time on the manager's
+// delivery goroutine, so a slow one holds up the others, though not lock
+// operations.
+func (m *Manager) Watch(jobs []string, sink EventSink) (stop func()) {
+	normalized := make([]string, len(jobs))
+	for i, job := range jobs {
+		normalized[i] = normalizeJob(job)
+	}
+
+	m.watchers.mu.Lock()
+	defer m.watchers.mu.Unlock()
+	if m.watchers.subs == nil {
+		m.watchers.subs = make(map[int]watcher)
+	}
+	id := m.watchers.next
+	m.watchers.next++
+	m.watchers.subs[id] = watcher{jobs: normalized, sink: sink}
+
+	return func() {
+		m.watchers.mu.Lock()
+		defer m.watchers.mu.Unlock()
+		delete(m.watchers.subs, id)
+	}
+}
+
+// outbox queues recorded events for delivery, so sinks such as the audit log
+// never do I/O while a job's lock is held
+type outbox struct {
+	mu   sync.Mutex
+	cond *sync.Cond
+	// queue holds events yet to be delivered, each with the sinks that were
+	// subscribed when it was recorded
+	queue      []delivery
+	delivering bool
+	started    bool
+	closed     bool
+}
+
+type delivery struct {
+	event Event
+	sinks []EventSink
+}
+
+// publish is every state's event sink: it queues the event for the sink
+// given with WithEventSink and for the watchers. It runs with the job's lock
+// held, so it only appends to the queue.
+func (m *Manager) publish(e Event) {
+	var sinks []EventSink
+	if m.sink != nil {
+		sinks = append(sinks, m.sink)
+	}
+	m.watchers.mu.Lock()
+	for _, w := range m.watchers.subs {
+		if w.matches(e.Job) {
+			sinks = append(sinks, w.sink)
+		}
+	}
+	m.watchers.mu.Unlock()
+	if len(sinks) == 0 {
+		return
+	}
+
+	o := &m.outbox
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.closed {
+		return
+	}
+	if !o.started {
+		o.started = true
+		go m.deliver()
+	}
+	o.queue = append(o.queue, delivery{event: e, sinks: sinks})
+	o.cond.Broadcast()
+}
+
+// deliver hands queued events to their sinks, in the order they were
+// recorded, until the manager is closed
+func (m *Manager) deliver() {
+	o := &m.outbox
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for {
+		for len(o.queue) == 0 && !o.closed {
+			o.cond.Wait()
+		}
+		if len(o.queue) == 0 {
+			o.started = false
+			o.cond.Broadcast()
+			return
+		}
+
+		batch := o.queue
+		o.queue = nil
+		o.delivering = true
+		o.mu.Unlock()
+		for _, d := range batch {
+			for _, sink := range d.sinks {
+				sink(d.event)
+			}
+		}
+		o.mu.Lock()
+		o.delivering = false
+		o.cond.Broadcast()
+	}
+}
+
+// Flush waits until every event recorded so far has reached its sinks
+func (m *Manager) Flush() {
+	o := &m.outbox
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for len(o.queue) > 0 || o.delivering {
+		o.cond.Wait()
+	}
+}
+
+// Close delivers the events still queued and stops delivering new ones; call
+// it before closing the event sink
+func (m *Manager) Close() {
+	o := &m.outbox
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.closed = true
+	o.cond.Broadcast()
+	for o.started {
+		o.cond.Wait()
+	}
+}
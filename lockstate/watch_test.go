@@ -0,0 +1,59 @@
+package lockstate
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestWatch(t *testing.T) {
+	var audited []EventType
+	m := New(WithEventSink(func(e Event) { audited = append(audited, e.Type) }))
+
+	var all, photos []Event
+	stopAll := m.Watch(nil, func(e Event) { all = append(all, e) })
+	stopPhotos := m.Watch([]string{"/photos/"}, func(e Event) { photos = append(photos, e) })
+
+	m.Acquire("backup", "c1", time.Minute)
+	m.Acquire("/photos/2024", "c1", time.Minute)
+	m.Acquire("/photos", "c2", time.Minute)
+	m.Release("/photos/2024", "c1")
+	m.Flush()
+
+	want := []EventType{EventAcquired, EventAcquired, EventConflict, EventReleased}
+	if got := eventTypes(all); !slices.Equal(got, want) {
+		t.Errorf("all = %v, want %v", got, want)
+	}
+	want = []EventType{EventAcquired, EventConflict, EventReleased}
+	if got := eventTypes(photos); !slices.Equal(got, want) {
+		t.Fatalf("photos = %v, want %v", got, want)
+	}
+	if photos[0].Job != "/photos/2024" {
+		t.Errorf("photos[0].Job = %q, want /photos/2024", photos[0].Job)
+	}
+	if !slices.Equal(audited, eventTypes(all)) {
+		t.Errorf("the event sink still sees everything: audited = %v, want %v", audited, eventTypes(all))
+	}
+
+	stopPhotos()
+	m.Release("backup", "c1")
+	m.Flush()
+	if len(all) != 5 || len(photos) != 3 {
+		t.Errorf("after stopping photos: len(all) = %d, len(photos) = %d, want 5, 3", len(all), len(photos))
+	}
+
+	stopAll()
+	m.Acquire("backup", "c1", time.Minute)
+	m.Flush()
+	if len(all) != 5 {
+		t.Errorf("after stopping all: len(all) = %d, want 5", len(all))
+	}
+}
+
+func eventTypes(events []Event) []EventType {
+	types := make([]EventType, len(events))
+	for i, e := range events {
+		types[i] = e.Type
+	}
+	return types
+}
@@ -0,0 +1,125 @@
+package lockstategrpc
+
+import (
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/shadyabhi/foolock/lockpb"
+	"github.com/shadyabhi/foolock/lockstate"
+)
+
+// duration converts an optional request duration, where unset means zero
+func duration(field string, d *durationpb.Duration) (time.Duration, error) {
+	if d == nil {
+		return 0, nil
+	}
+	if err := d.CheckValid(); err != nil || d.AsDuration() < 0 {
+		return 0, status.Errorf(codes.InvalidArgument, "invalid %s", field)
+	}
+	return d.AsDuration(), nil
+}
+
+// timestamp leaves zero times unset rather than sending the Unix epoch
+func timestamp(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t)
+}
+
+func lock(result lockstate.StatusResult) *lockpb.Lock {
+	return &lockpb.Lock{
+		Job:        result.Job,
+		Holder:     result.Holder,
+		AcquiredAt: timestamp(result.AcquiredAt),
+		ExpiresAt:  timestamp(result.ExpiresAt),
+		GraceUntil: timestamp(result.GraceUntil),
+		Expired:    result.IsExpired,
+		InGrace:    result.InGrace,
+		Metadata:   result.Metadata,
+
+		BlockedBy:       result.BlockedBy,
+		BlockedByHolder: result.BlockedByHolder,
+
+		MaxHoldUntil:  timestamp(result.MaxHoldUntil),
+		CooldownUntil: timestamp(result.CooldownUntil),
+
+		LastSuccessAt: timestamp(result.LastSuccessAt),
+		LastSuccessBy: result.LastSuccessBy,
+		LastFailureAt: timestamp(result.LastFailureAt),
+		LastFailureBy: result.LastFailureBy,
+	}
+}
+
+// acquireResponse describes the outcome of an acquire or renew and the lock
+// as it left it. Everything comes from result, which the manager built while
+// holding the lock, so the two can't disagree.
+func acquireResponse(result lockstate.AcquireResult, now time.Time) *lockpb.AcquireResponse {
+	return &lockpb.AcquireResponse{
+		Success:        result.Success,
+		Code:           string(result.Code),
+		Message:        result.Message,
+		Lock:           acquiredLock(result, now),
+		PreviousHolder: result.PreviousHolder,
+		AvailableAt:    timestamp(result.AvailableAt),
+		NextRunAt:      timestamp(result.NextRunAt),
+	}
+}
+
+// acquiredLock describes the lock an acquire left behind. When a relative
+// blocked it, result describes the relative, so only its name and holder are
+// passed on.
+func acquiredLock(result lockstate.AcquireResult, now time.Time) *lockpb.Lock {
+	if result.BlockedBy != "" {
+		return &lockpb.Lock{
+			Job:             result.Job,
+			BlockedBy:       result.BlockedBy,
+			BlockedByHolder: result.Holder,
+		}
+	}
+	expired := !result.ExpiresAt.IsZero() && !now.Before(result.ExpiresAt)
+	return &lockpb.Lock{
+		Job:        result.Job,
+		Holder:     result.Holder,
+		AcquiredAt: timestamp(result.AcquiredAt),
+		ExpiresAt:  timestamp(result.ExpiresAt),
+		GraceUntil: timestamp(result.GraceUntil),
+		Expired:    expired,
+		InGrace:    expired && now.Before(result.GraceUntil),
+		Metadata:   result.Metadata,
+
+		MaxHoldUntil:  timestamp(result.MaxHoldUntil),
+		CooldownUntil: timestamp(result.CooldownUntil),
+
+		LastSuccessAt: timestamp(result.LastSuccessAt),
+		LastSuccessBy: result.LastSuccessBy,
+	}
+}
+
+func releaseResponse(result lockstate.ReleaseResult) *lockpb.ReleaseResponse {
+	response := &lockpb.ReleaseResponse{
+		Success:       result.Success,
+		Code:          string(result.Code),
+		Message:       result.Message,
+		CooldownUntil: timestamp(result.CooldownUntil),
+	}
+	if result.Success {
+		response.HeldFor = durationpb.New(result.HeldFor)
+	}
+	return response
+}
+
+func event(e lockstate.Event) *lockpb.Event {
+	return &lockpb.Event{
+		Time:    timestamp(e.Time),
+		Job:     e.Job,
+		Type:    string(e.Type),
+		Client:  e.Client,
+		Holder:  e.Holder,
+		Message: e.Message,
+	}
+}
@@ -0,0 +1,30 @@
+package lockstategrpc
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"google.golang.org/grpc/peer"
+
+	"github.com/shadyabhi/foolock/lockstate"
+	"github.com/shadyabhi/foolock/lockstate/locklog"
+)
+
+// callLogger returns the server's logger annotated with the caller's address
+func (s *Server) callLogger(ctx context.Context) *slog.Logger {
+	if p, ok := peer.FromContext(ctx); ok {
+		return s.logger.With("remote_addr", p.Addr.String())
+	}
+	return s.logger
+}
+
+// logAcquire records an acquisition or renewal, see locklog.Acquire
+func (s *Server) logAcquire(ctx context.Context, client string, ttl time.Duration, result lockstate.AcquireResult) {
+	locklog.Acquire(ctx, s.callLogger(ctx), client, ttl, result)
+}
+
+// logRelease records a release, see locklog.Release
+func (s *Server) logRelease(ctx context.Context, client string, result lockstate.ReleaseResult) {
+	locklog.Release(ctx, s.callLogger(ctx), client, result)
+}
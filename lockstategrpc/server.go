@@ -0,0 +1,195 @@
+// Package lockstategrpc serves the lock manager over gRPC, as defined in
+// lockpb/lock.proto.
+package lockstategrpc
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"github.com/shadyabhi/foolock/lockpb"
+	"github.com/shadyabhi/foolock/lockstate"
+	"github.com/shadyabhi/foolock/lockstate/msg"
+	"github.com/shadyabhi/foolock/peercred"
+	"github.com/shadyabhi/foolock/ratelimit"
+)
+
+// Server implements lockpb.LocksServer on top of a lock manager
+type Server struct {
+	lockpb.UnimplementedLocksServer
+
+	manager *lockstate.Manager
+	logger  *slog.Logger
+	limiter *ratelimit.Limiter
+
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+// Option configures a Server
+type Option func(*Server)
+
+// WithLogger sets where lock operations are logged; the default is
+// slog.Default()
+func WithLogger(logger *slog.Logger) Option {
+	return func(s *Server) {
+		s.logger = logger
+	}
+}
+
+// WithRateLimiter throttles acquisitions, renewals and releases before they
+// reach the lock manager
+func WithRateLimiter(l *ratelimit.Limiter) Option {
+	return func(s *Server) {
+		s.limiter = l
+	}
+}
+
+func New(manager *lockstate.Manager, opts ...Option) *Server {
+	s := &Server{
+		manager: manager,
+		logger:  slog.Default(),
+		closed:  make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Close ends every Watch stream, which would otherwise keep a graceful stop
+// waiting forever. Other calls are unaffected.
+func (s *Server) Close() {
+	s.closeOnce.Do(func() { close(s.closed) })
+}
+
+func (s *Server) Acquire(ctx context.Context, req *lockpb.AcquireRequest) (*lockpb.AcquireResponse, error) {
+	if err := checkJobClient(req.GetJob(), req.GetClient()); err != nil {
+		return nil, err
+	}
+	ttl, err := duration("ttl", req.GetTtl())
+	if err != nil {
+		return nil, err
+	}
+	interval, err := duration("min_interval", req.GetMinInterval())
+	if err != nil {
+		return nil, err
+	}
+
+	var opts []lockstate.AcquireOption
+	if interval > 0 {
+		opts = append(opts, lockstate.MinInterval(interval))
+	}
+	if req.GetMetadata() != nil {
+		opts = append(opts, lockstate.WithMetadata(req.GetMetadata()))
+	}
+	return s.acquire(ctx, req.GetJob(), req.GetClient(), ttl, opts...)
+}
+
+func (s *Server) Renew(ctx context.Context, req *lockpb.RenewRequest) (*lockpb.AcquireResponse, error) {
+	if err := checkJobClient(req.GetJob(), req.GetClient()); err != nil {
+		return nil, err
+	}
+	ttl, err := duration("ttl", req.GetTtl())
+	if err != nil {
+		return nil, err
+	}
+	return s.acquire(ctx, req.GetJob(), req.GetClient(), ttl, lockstate.RenewOnly())
+}
+
+func (s *Server) acquire(ctx context.Context, job, client string, ttl time.Duration, opts ...lockstate.AcquireOption) (*lockpb.AcquireResponse, error) {
+	if err := s.throttled(ctx, client, job); err != nil {
+		return nil, err
+	}
+	result := s.manager.Acquire(job, client, ttl, opts...)
+	s.logAcquire(ctx, client, ttl, result)
+	return acquireResponse(result, time.Now()), nil
+}
+
+func (s *Server) Release(ctx context.Context, req *lockpb.ReleaseRequest) (*lockpb.ReleaseResponse, error) {
+	if err := checkJobClient(req.GetJob(), req.GetClient()); err != nil {
+		return nil, err
+	}
+
+	var opts []lockstate.ReleaseOption
+	switch {
+	case req.Success != nil || req.ExitCode != nil:
+		outcome := lockstate.Outcome{ExitCode: int(req.GetExitCode()), Message: req.GetMessage()}
+		outcome.Success = outcome.ExitCode == 0
+		if req.Success != nil {
+			outcome.Success = req.GetSuccess()
+		}
+		opts = append(opts, lockstate.WithOutcome(outcome))
+	case req.GetMessage() != "":
+		return nil, status.Error(codes.InvalidArgument, "message requires success or exit_code")
+	}
+
+	if err := s.throttled(ctx, req.GetClient(), req.GetJob()); err != nil {
+		return nil, err
+	}
+	result := s.manager.Release(req.GetJob(), req.GetClient(), opts...)
+	s.logRelease(ctx, req.GetClient(), result)
+	return releaseResponse(result), nil
+}
+
+func (s *Server) Status(ctx context.Context, req *lockpb.StatusRequest) (*lockpb.Lock, error) {
+	if req.GetJob() == "" {
+		return nil, status.Error(codes.InvalidArgument, "job required")
+	}
+	return lock(s.manager.Status(req.GetJob())), nil
+}
+
+func (s *Server) List(ctx context.Context, req *lockpb.ListRequest) (*lockpb.ListResponse, error) {
+	response := &lockpb.ListResponse{}
+	for _, job := range s.manager.Jobs() {
+		response.Locks = append(response.Locks, lock(s.manager.Status(job)))
+	}
+	return response, nil
+}
+
+// checkJobClient rejects requests that don't say which lock or who for, or
+// that name a client only Unix socket peers can be
+func checkJobClient(job, client string) error {
+	if job == "" {
+		return status.Error(codes.InvalidArgument, "job required")
+	}
+	if client == "" {
+		return status.Error(codes.InvalidArgument, "client required")
+	}
+	if peercred.Reserved(client) {
+		return status.Error(codes.PermissionDenied, msg.ReservedClient)
+	}
+	return nil
+}
+
+// throttled returns a ResourceExhausted error if the rate limiter refuses the
+// request
+func (s *Server) throttled(ctx context.Context, client string, jobs ...string) error {
+	if s.limiter == nil {
+		return nil
+	}
+	ok, retry := s.limiter.Allow(client, peerIP(ctx), jobs)
+	if ok {
+		return nil
+	}
+	return status.Errorf(codes.ResourceExhausted, "rate limit exceeded, retry in %s", retry.Round(time.Millisecond))
+}
+
+// peerIP returns the caller's address without the port, or "" if it isn't
+// an IP connection
+func peerIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ""
+	}
+	if addr, ok := p.Addr.(*net.TCPAddr); ok {
+		return addr.IP.String()
+	}
+	return ""
+}
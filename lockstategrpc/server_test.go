@@ -0,0 +1,249 @@
+package lockstategrpc
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	"github.com/shadyabhi/foolock/lockpb"
+	"github.com/shadyabhi/foolock/lockstate"
+	"github.com/shadyabhi/foolock/ratelimit"
+)
+
+// serve runs srv on an in-process listener and returns a client for it
+func serve(t *testing.T, srv *Server) lockpb.LocksClient {
+	t.Helper()
+	ln := bufconn.Listen(1 << 20)
+	s := grpc.NewServer()
+	lockpb.RegisterLocksServer(s, srv)
+	go s.Serve(ln)
+	t.Cleanup(s.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return ln.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return lockpb.NewLocksClient(conn)
+}
+
+func TestAcquireRenewRelease(t *testing.T) {
+	ctx := t.Context()
+	client := serve(t, New(lockstate.New(lockstate.WithGracePeriod(0))))
+
+	resp, err := client.Acquire(ctx, &lockpb.AcquireRequest{
+		Job:      "backup",
+		Client:   "c1",
+		Ttl:      durationpb.New(time.Minute),
+		Metadata: map[string]string{"host": "laptop1"},
+	})
+	require.NoError(t, err)
+	require.True(t, resp.GetSuccess())
+	require.Equal(t, "acquired", resp.GetCode())
+	require.Equal(t, "c1", resp.GetLock().GetHolder())
+	require.Equal(t, map[string]string{"host": "laptop1"}, resp.GetLock().GetMetadata())
+	require.WithinDuration(t, time.Now().Add(time.Minute), resp.GetLock().GetExpiresAt().AsTime(), time.Second)
+
+	resp, err = client.Acquire(ctx, &lockpb.AcquireRequest{Job: "backup", Client: "c2"})
+	require.NoError(t, err, "refusals aren't RPC errors")
+	require.False(t, resp.GetSuccess())
+	require.Equal(t, "held_by_another", resp.GetCode())
+	require.Equal(t, "c1", resp.GetLock().GetHolder())
+	require.NotNil(t, resp.GetAvailableAt())
+
+	resp, err = client.Renew(ctx, &lockpb.RenewRequest{Job: "backup", Client: "c1", Ttl: durationpb.New(2 * time.Minute)})
+	require.NoError(t, err)
+	require.Equal(t, "renewed", resp.GetCode())
+	require.WithinDuration(t, time.Now().Add(2*time.Minute), resp.GetLock().GetExpiresAt().AsTime(), time.Second)
+
+	resp, err = client.Renew(ctx, &lockpb.RenewRequest{Job: "other", Client: "c1"})
+	require.NoError(t, err)
+	require.Equal(t, "not_holder", resp.GetCode())
+	require.Empty(t, resp.GetLock().GetHolder(), "renewing a free lock doesn't acquire it")
+
+	release, err := client.Release(ctx, &lockpb.ReleaseRequest{Job: "backup", Client: "c2"})
+	require.NoError(t, err)
+	require.False(t, release.GetSuccess())
+	require.Equal(t, "not_holder", release.GetCode())
+
+	release, err = client.Release(ctx, &lockpb.ReleaseRequest{Job: "backup", Client: "c1", ExitCode: proto.Int32(0)})
+	require.NoError(t, err)
+	require.True(t, release.GetSuccess())
+	require.Equal(t, "released", release.GetCode())
+	require.NotNil(t, release.GetHeldFor())
+
+	status, err := client.Status(ctx, &lockpb.StatusRequest{Job: "backup"})
+	require.NoError(t, err)
+	require.Empty(t, status.GetHolder())
+	require.Equal(t, "c1", status.GetLastSuccessBy())
+	require.Nil(t, status.GetExpiresAt())
+}
+
+func TestAcquireBlockedByRelative(t *testing.T) {
+	ctx := t.Context()
+	m := lockstate.New()
+	client := serve(t, New(m))
+	m.Acquire("/reports/daily", "c1", time.Minute)
+
+	resp, err := client.Acquire(ctx, &lockpb.AcquireRequest{Job: "/reports", Client: "c2"})
+	require.NoError(t, err)
+	require.False(t, resp.GetSuccess())
+	require.Equal(t, string(lockstate.CodeRelatedPathHeld), resp.GetCode())
+	require.Equal(t, "/reports", resp.GetLock().GetJob())
+	require.Empty(t, resp.GetLock().GetHolder())
+	require.Equal(t, "/reports/daily", resp.GetLock().GetBlockedBy())
+	require.Equal(t, "c1", resp.GetLock().GetBlockedByHolder())
+	require.NotNil(t, resp.GetAvailableAt())
+}
+
+func TestList(t *testing.T) {
+	ctx := t.Context()
+	m := lockstate.New()
+	client := serve(t, New(m))
+
+	m.Acquire("backup", "c1", time.Minute)
+	m.Acquire("photos", "c2", time.Minute)
+
+	resp, err := client.List(ctx, &lockpb.ListRequest{})
+	require.NoError(t, err)
+	require.Len(t, resp.GetLocks(), 2)
+	require.Equal(t, "backup", resp.GetLocks()[0].GetJob())
+	require.Equal(t, "c1", resp.GetLocks()[0].GetHolder())
+	require.Equal(t, "photos", resp.GetLocks()[1].GetJob())
+	require.Equal(t, "c2", resp.GetLocks()[1].GetHolder())
+}
+
+func TestInvalidArgument(t *testing.T) {
+	ctx := t.Context()
+	client := serve(t, New(lockstate.New()))
+
+	calls := map[string]func() error{
+		"acquire without job": func() error {
+			_, err := client.Acquire(ctx, &lockpb.AcquireRequest{Client: "c1"})
+			return err
+		},
+		"acquire without client": func() error {
+			_, err := client.Acquire(ctx, &lockpb.AcquireRequest{Job: "backup"})
+			return err
+		},
+		"negative ttl": func() error {
+			_, err := client.Acquire(ctx, &lockpb.AcquireRequest{Job: "backup", Client: "c1", Ttl: durationpb.New(-time.Second)})
+			return err
+		},
+		"renew without client": func() error {
+			_, err := client.Renew(ctx, &lockpb.RenewRequest{Job: "backup"})
+			return err
+		},
+		"release message without outcome": func() error {
+			_, err := client.Release(ctx, &lockpb.ReleaseRequest{Job: "backup", Client: "c1", Message: "done"})
+			return err
+		},
+		"status without job": func() error {
+			_, err := client.Status(ctx, &lockpb.StatusRequest{})
+			return err
+		},
+	}
+	for name, call := range calls {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, codes.InvalidArgument, status.Code(call()))
+		})
+	}
+}
+
+func TestReservedClient(t *testing.T) {
+	ctx := t.Context()
+	m := lockstate.New()
+	client := serve(t, New(m))
+
+	_, err := client.Acquire(ctx, &lockpb.AcquireRequest{Job: "backup", Client: "uid:1000/c1"})
+	require.Equal(t, codes.PermissionDenied, status.Code(err))
+	_, err = client.Release(ctx, &lockpb.ReleaseRequest{Job: "backup", Client: "uid:1000/c1"})
+	require.Equal(t, codes.PermissionDenied, status.Code(err))
+	require.Empty(t, m.Jobs(), "refused before reaching the manager")
+}
+
+func TestRateLimit(t *testing.T) {
+	ctx := t.Context()
+	m := lockstate.New()
+	limiter := ratelimit.New(ratelimit.Config{Client: ratelimit.Limit{Rate: 0.1, Burst: 1}})
+	client := serve(t, New(m, WithRateLimiter(limiter)))
+
+	_, err := client.Acquire(ctx, &lockpb.AcquireRequest{Job: "backup", Client: "c1"})
+	require.NoError(t, err)
+
+	_, err = client.Release(ctx, &lockpb.ReleaseRequest{Job: "backup", Client: "c1"})
+	require.Equal(t, codes.ResourceExhausted, status.Code(err))
+	require.Equal(t, "c1", m.Status("backup").Holder, "throttled requests never reach the manager")
+
+	_, err = client.Status(ctx, &lockpb.StatusRequest{Job: "backup"})
+	require.NoError(t, err, "status isn't limited")
+}
+
+func TestWatch(t *testing.T) {
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	m := lockstate.New()
+	client := serve(t, New(m))
+
+	stream, err := client.Watch(ctx, &lockpb.WatchRequest{Jobs: []string{"/photos"}})
+	require.NoError(t, err)
+	// The first event can only be matched once the server is subscribed, so
+	// keep generating it until one arrives
+	received := make(chan *lockpb.Event, 1)
+	go func() {
+		e, err := stream.Recv()
+		if err == nil {
+			received <- e
+		}
+	}()
+	var first *lockpb.Event
+	require.Eventually(t, func() bool {
+		m.Acquire("/photos/2024", "c1", time.Minute)
+		select {
+		case first = <-received:
+			return true
+		default:
+			return false
+		}
+	}, time.Second, 10*time.Millisecond)
+	require.Equal(t, "/photos/2024", first.GetJob())
+	require.Contains(t, []string{"acquired", "renewed"}, first.GetType())
+
+	m.Acquire("backup", "c1", time.Minute)
+	m.Release("/photos/2024", "c1")
+	for {
+		e, err := stream.Recv()
+		require.NoError(t, err)
+		require.NotEqual(t, "backup", e.GetJob(), "other jobs are filtered out")
+		if e.GetType() == "released" {
+			require.Equal(t, "c1", e.GetClient())
+			require.NotNil(t, e.GetTime())
+			break
+		}
+	}
+}
+
+func TestCloseEndsWatch(t *testing.T) {
+	srv := New(lockstate.New())
+	client := serve(t, srv)
+
+	stream, err := client.Watch(t.Context(), &lockpb.WatchRequest{})
+	require.NoError(t, err)
+	srv.Close()
+
+	_, err = stream.Recv()
+	require.Equal(t, codes.Unavailable, status.Code(err))
+}
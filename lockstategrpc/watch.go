@@ -0,0 +1,48 @@
+package lockstategrpc
+
+import (
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/shadyabhi/foolock/lockpb"
+	"github.com/shadyabhi/foolock/lockstate"
+)
+
+// watchBuffer is how many events a Watch stream may fall behind by before it
+// is ended; waiting for a slow client would hold up every other watcher and
+// the audit log
+const watchBuffer = 256
+
+func (s *Server) Watch(req *lockpb.WatchRequest, stream grpc.ServerStreamingServer[lockpb.Event]) error {
+	events := make(chan lockstate.Event, watchBuffer)
+	overflow := make(chan struct{})
+	overflowed := false
+	stop := s.manager.Watch(req.GetJobs(), func(e lockstate.Event) {
+		if overflowed {
+			return
+		}
+		select {
+		case events <- e:
+		default:
+			overflowed = true
+			close(overflow)
+		}
+	})
+	defer stop()
+
+	for {
+		select {
+		case e := <-events:
+			if err := stream.Send(event(e)); err != nil {
+				return err
+			}
+		case <-overflow:
+			return status.Error(codes.ResourceExhausted, "watcher fell behind")
+		case <-s.closed:
+			return status.Error(codes.Unavailable, "server shutting down")
+		case <-stream.Context().Done():
+			return nil
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package lockstatehttp
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// AccessLogFormat selects how AccessLog writes each request
+type AccessLogFormat string
+
+const (
+	// AccessLogCommon is the Common Log Format understood by most log tools
+	AccessLogCommon AccessLogFormat = "common"
+	// AccessLogJSON writes one JSON object per request
+	AccessLogJSON AccessLogFormat = "json"
+)
+
+// commonLogTime is the timestamp layout of the Common Log Format
+const commonLogTime = "02/Jan/2006:15:04:05 -0700"
+
+type accessEntry struct {
+	Time       time.Time `json:"time"`
+	RemoteAddr string    `json:"remote_addr"`
+	Method     string    `json:"method"`
+	URI        string    `json:"uri"`
+	Proto      string    `json:"proto"`
+	Status     int       `json:"status"`
+	Bytes      int64     `json:"bytes"`
+	DurationMs float64   `json:"duration_ms"`
+	RequestID  string    `json:"request_id,omitempty"`
+	UserAgent  string    `json:"user_agent,omitempty"`
+}
+
+// AccessLog writes a line to w for every request once it has been answered.
+// Put it inside WithRequestID to get request IDs in the JSON format.
+func AccessLog(w io.Writer, format AccessLogFormat) Middleware {
+	var mu sync.Mutex
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			rec := &statusRecorder{ResponseWriter: rw}
+			next.ServeHTTP(rec, r)
+
+			entry := accessEntry{
+				Time:       start,
+				RemoteAddr: r.RemoteAddr,
+				Method:     r.Method,
+				URI:        r.RequestURI,
+				Proto:      r.Proto,
+				Status:     rec.status,
+				Bytes:      rec.bytes,
+				DurationMs: float64(time.Since(start).Microseconds()) / 1000,
+				RequestID:  RequestID(r.Context()),
+				UserAgent:  r.UserAgent(),
+			}
+			if entry.Status == 0 {
+				entry.Status = http.StatusOK
+			}
+
+			var line []byte
+			if format == AccessLogJSON {
+				line, _ = json.Marshal(entry)
+				line = append(line, '\n')
+			} else {
+				line = commonLogLine(entry)
+			}
+			mu.Lock()
+			w.Write(line)
+			mu.Unlock()
+		})
+	}
+}
+
+// commonLogLine renders entry as host ident authuser [date] "request" status bytes
+func commonLogLine(e accessEntry) []byte {
+	host, _, err := net.SplitHostPort(e.RemoteAddr)
+	if err != nil {
+		host = e.RemoteAddr
+	}
+	size := "-"
+	if e.Bytes > 0 {
+		size = strconv.FormatInt(e.Bytes, 10)
+	}
+	return fmt.Appendf(nil, "%s - - [%s] %q %d %s\n", host, e.Time.Format(commonLogTime), e.Method+" "+e.URI+" "+e.Proto, e.Status, size)
+}
@@ -0,0 +1,56 @@
+package lockstatehttp
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccessLogCommon(t *testing.T) {
+	var buf bytes.Buffer
+	h := AccessLog(&buf, AccessLogCommon)(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusConflict)
+		io.WriteString(w, "held")
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/lock?job=backup&client=c1", nil)
+	req.RemoteAddr = "192.0.2.7:51234"
+	serve(h, req)
+
+	require.Regexp(t, regexp.MustCompile(`^192\.0\.2\.7 - - \[\d{2}/\w{3}/\d{4}:\d{2}:\d{2}:\d{2} [+-]\d{4}\] "POST /lock\?job=backup&client=c1 HTTP/1\.1" 409 4\n$`), buf.String())
+
+	// Nothing written: implicit 200 and "-" for the size
+	buf.Reset()
+	h = AccessLog(&buf, AccessLogCommon)(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	serve(h, httptest.NewRequest(http.MethodGet, "/lock", nil))
+	require.Regexp(t, `"GET /lock HTTP/1\.1" 200 -\n$`, buf.String())
+}
+
+func TestAccessLogJSON(t *testing.T) {
+	var buf bytes.Buffer
+	h := WithRequestID(AccessLog(&buf, AccessLogJSON)(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		io.WriteString(w, "ok")
+	})))
+
+	req := httptest.NewRequest(http.MethodGet, "/lock?job=backup", nil)
+	req.Header.Set(RequestIDHeader, "req-9")
+	req.Header.Set("User-Agent", "foolock.sh")
+	serve(h, req)
+
+	var entry map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+	require.Equal(t, "GET", entry["method"])
+	require.Equal(t, "/lock?job=backup", entry["uri"])
+	require.EqualValues(t, 200, entry["status"])
+	require.EqualValues(t, 2, entry["bytes"])
+	require.Equal(t, "req-9", entry["request_id"])
+	require.Equal(t, "foolock.sh", entry["user_agent"])
+	require.Contains(t, entry, "duration_ms")
+	require.Contains(t, entry, "time")
+}
@@ -0,0 +1,34 @@
+package lockstatehttp
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/shadyabhi/foolock/lockstate"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleCodes(t *testing.T) {
+	m := lockstate.New(lockstate.WithGracePeriod(0))
+	h := New(m)
+
+	_, resp := doLock[map[string]any](t, h, http.MethodPost, "?client=c1&ttl=10ms")
+	require.Equal(t, string(lockstate.CodeAcquired), resp["code"])
+
+	_, resp = doLock[map[string]any](t, h, http.MethodPost, "?client=c1&ttl=10ms")
+	require.Equal(t, string(lockstate.CodeRenewed), resp["code"])
+
+	time.Sleep(15 * time.Millisecond)
+
+	_, resp = doLock[map[string]any](t, h, http.MethodPost, "?client=c2")
+	require.Equal(t, string(lockstate.CodeTakenOver), resp["code"])
+	require.Equal(t, "c1", resp["previous_holder"])
+
+	w, resp := doLock[map[string]any](t, h, http.MethodDelete, "?client=c1")
+	require.Equal(t, http.StatusForbidden, w.Code)
+	require.Equal(t, string(lockstate.CodeNotHolder), resp["code"])
+
+	_, resp = doLock[map[string]any](t, h, http.MethodDelete, "?client=c2")
+	require.Equal(t, string(lockstate.CodeReleased), resp["code"])
+}
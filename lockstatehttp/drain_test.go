@@ -0,0 +1,32 @@
+package lockstatehttp
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/shadyabhi/foolock/lockstate"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleDraining(t *testing.T) {
+	m := lockstate.New()
+	h := New(m)
+	m.Acquire("backup", "c1", time.Minute)
+	m.SetDraining(true)
+
+	tests := []struct {
+		method string
+		query  string
+		status int
+	}{
+		{http.MethodPost, "?client=c2&job=sync", http.StatusServiceUnavailable},
+		{http.MethodPost, "?client=c1&job=backup", http.StatusOK},
+		{http.MethodGet, "?job=backup", http.StatusOK},
+		{http.MethodDelete, "?client=c1&job=backup", http.StatusOK},
+	}
+	for _, tt := range tests {
+		w, _ := doLock[LockResponse](t, h, tt.method, tt.query)
+		require.Equal(t, tt.status, w.Code, "%s %s", tt.method, tt.query)
+	}
+}
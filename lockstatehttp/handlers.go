@@ -2,34 +2,97 @@ package lockstatehttp
 
 import (
 	"encoding/json"
-	"log"
+	"fmt"
+	"log/slog"
 	"net/http"
+	"runtime"
+	"strconv"
 	"time"
 
 	"github.com/shadyabhi/foolock/lockstate"
 	"github.com/shadyabhi/foolock/lockstate/msg"
+	"github.com/shadyabhi/foolock/peercred"
+	"github.com/shadyabhi/foolock/ratelimit"
 )
 
+const defaultJob = "default"
+
 type LockResponse struct {
 	Success    bool   `json:"success"`
+	Code       string `json:"code,omitempty"`
 	Job        string `json:"job,omitempty"`
 	Holder     string `json:"holder"`
 	Message    string `json:"message,omitempty"`
 	ExpiresAt  string `json:"expires_at,omitempty"`
 	IsExpired  bool   `json:"is_expired,omitempty"`
 	GraceUntil string `json:"grace_until,omitempty"`
+
+	Metadata        map[string]string `json:"metadata,omitempty"`
+	Session         string            `json:"session,omitempty"`
+	PreviousHolder  string            `json:"previous_holder,omitempty"`
+	BlockedBy       string            `json:"blocked_by,omitempty"`
+	BlockedByHolder string            `json:"blocked_by_holder,omitempty"`
+
+	MaxHoldUntil     string `json:"max_hold_until,omitempty"`
+	MaxHoldRemaining string `json:"max_hold_remaining,omitempty"`
+	CooldownUntil    string `json:"cooldown_until,omitempty"`
+
+	LastSuccessAt string `json:"last_success_at,omitempty"`
+	LastSuccessBy string `json:"last_success_by,omitempty"`
+	LastFailureAt string `json:"last_failure_at,omitempty"`
+	LastFailureBy string `json:"last_failure_by,omitempty"`
+	NextRunAt     string `json:"next_run_at,omitempty"`
+
+	AvailableAt  string `json:"available_at,omitempty"`
+	RetryAfterMs *int64 `json:"retry_after_ms,omitempty"`
+
+	// Relative to ServerTime, for clients whose clocks can't be trusted
+	ServerTime       string `json:"server_time"`
+	TTLRemainingMs   *int64 `json:"ttl_remaining_ms,omitempty"`
+	GraceRemainingMs *int64 `json:"grace_remaining_ms,omitempty"`
+	HeldForMs        *int64 `json:"held_for_ms,omitempty"`
 }
 
 type ErrorResponse struct {
-	Error string `json:"error"`
+	Error      string `json:"error"`
+	Code       string `json:"code,omitempty"`
+	ServerTime string `json:"server_time"`
 }
 
 type Handler struct {
 	manager *lockstate.Manager
+	logger  *slog.Logger
+	limiter *ratelimit.Limiter
+	// Jobs each uid may lock over the Unix socket
+	peerPolicy peercred.Policy
+
+	started time.Time
+	build   BuildInfo
+	checks  []namedCheck
 }
 
-func New(manager *lockstate.Manager) *Handler {
-	return &Handler{manager: manager}
+// Option configures a Handler
+type Option func(*Handler)
+
+// WithLogger sets where lock operations are logged; the default is
+// slog.Default()
+func WithLogger(logger *slog.Logger) Option {
+	return func(h *Handler) {
+		h.logger = logger
+	}
+}
+
+func New(manager *lockstate.Manager, opts ...Option) *Handler {
+	h := &Handler{
+		manager: manager,
+		logger:  slog.Default(),
+		started: time.Now(),
+		build:   BuildInfo{Version: "unknown", GoVersion: runtime.Version()},
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
 }
 
 func (h *Handler) HandleLock(w http.ResponseWriter, r *http.Request) {
@@ -48,134 +111,271 @@ func (h *Handler) HandleLock(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *Handler) handleAcquire(w http.ResponseWriter, r *http.Request) {
-	client := r.URL.Query().Get("client")
+	client, ok := requestClient(r, r.URL.Query().Get("client"))
+	if !ok {
+		reservedClient(w)
+		return
+	}
 	if client == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		if err := json.NewEncoder(w).Encode(ErrorResponse{Error: "client parameter required"}); err != nil {
-			log.Printf("Error encoding response: %v", err)
-		}
+		writeError(w, http.StatusBadRequest, "client parameter required", "")
 		return
 	}
 
-	job := r.URL.Query().Get("job")
-	if job == "" {
-		job = "default"
+	job := jobParam(r)
+
+	ttl, ok := ttlParam(r)
+	if !ok {
+		writeError(w, http.StatusBadRequest, "invalid ttl format", "")
+		return
 	}
 
-	ttlStr := r.URL.Query().Get("ttl")
-	ttl := 30 * time.Second
-	if ttlStr != "" {
-		parsedTTL, err := time.ParseDuration(ttlStr)
-		if err != nil {
-			w.WriteHeader(http.StatusBadRequest)
-			if err := json.NewEncoder(w).Encode(ErrorResponse{Error: "invalid ttl format"}); err != nil {
-				log.Printf("Error encoding response: %v", err)
-			}
-			return
-		}
-		ttl = parsedTTL
+	opts, ok := acquireOptions(r)
+	if !ok {
+		writeError(w, http.StatusBadRequest, "invalid min_interval format", "")
+		return
 	}
 
-	result := h.manager.Acquire(job, client, ttl)
+	if !h.peerPermitted(r, job) {
+		peerNotPermitted(w)
+		return
+	}
+	if h.throttled(w, r, client, job) {
+		rateLimited(w)
+		return
+	}
+	result := h.manager.Acquire(job, client, ttl, opts...)
+	status, response := acquireReply(result, time.Now())
+	h.logAcquire(r, client, ttl, result)
 
 	if result.Success {
-		log.Printf("Lock %s by %s for job %s until %s (in %s)", result.Message, client, job, result.ExpiresAt.Format(time.RFC3339), time.Until(result.ExpiresAt).Round(time.Second))
-		w.WriteHeader(http.StatusOK)
-		if err := json.NewEncoder(w).Encode(LockResponse{
-			Success:   true,
-			Job:       job,
-			Holder:    result.Holder,
-			ExpiresAt: result.ExpiresAt.Format(time.RFC3339),
-			Message:   result.Message,
-		}); err != nil {
-			log.Printf("Error encoding response: %v", err)
-		}
+		writeJSON(w, status, response)
 		return
 	}
 
-	if result.Message == msg.GracePeriodActive {
-		w.WriteHeader(http.StatusConflict)
-		if err := json.NewEncoder(w).Encode(ErrorResponse{Error: msg.GracePeriodActive}); err != nil {
-			log.Printf("Error encoding response: %v", err)
-		}
+	switch result.Code {
+	case lockstate.CodeTTLOutOfRange, lockstate.CodeClientNotPermitted, lockstate.CodeUnknownJob, lockstate.CodeSessionNotFound:
+		writeError(w, status, result.Message, string(result.Code))
 		return
 	}
 
-	w.WriteHeader(http.StatusConflict)
-	if err := json.NewEncoder(w).Encode(LockResponse{
-		Success:   result.Success,
-		Job:       job,
-		Holder:    result.Holder,
-		Message:   result.Message,
-		ExpiresAt: result.ExpiresAt.Format(time.RFC3339),
-	}); err != nil {
-		log.Printf("Error encoding response: %v", err)
+	setRetryAfter(w, result.AvailableAt)
+	writeJSON(w, status, response)
+}
+
+// acquireReply builds the response for a single-lock acquisition along with
+// its HTTP status code
+func acquireReply(result lockstate.AcquireResult, now time.Time) (int, LockResponse) {
+	response := acquireResponse(result, now)
+
+	switch result.Code {
+	case lockstate.CodeAcquired, lockstate.CodeRenewed, lockstate.CodeReclaimed, lockstate.CodeTakenOver:
+		setMaxHold(&response, result.MaxHoldUntil, now)
+		return http.StatusOK, response
+	case lockstate.CodeTTLOutOfRange:
+		return http.StatusBadRequest, response
+	case lockstate.CodeClientNotPermitted:
+		return http.StatusForbidden, response
+	case lockstate.CodeUnknownJob, lockstate.CodeSessionNotFound:
+		return http.StatusNotFound, response
+	case lockstate.CodeRanRecently:
+		response.Message = fmt.Sprintf("%s: last ran at %s by %s", result.Message, result.LastSuccessAt.Format(time.RFC3339), result.LastSuccessBy)
+		return http.StatusTooEarly, response
+	case lockstate.CodeMaxHoldExceeded:
+		return http.StatusGone, response
+	case lockstate.CodeDraining:
+		return http.StatusServiceUnavailable, response
+	default:
+		return http.StatusConflict, response
 	}
 }
 
 func (h *Handler) handleRelease(w http.ResponseWriter, r *http.Request) {
-	client := r.URL.Query().Get("client")
+	client, ok := requestClient(r, r.URL.Query().Get("client"))
+	if !ok {
+		reservedClient(w)
+		return
+	}
 	if client == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		if err := json.NewEncoder(w).Encode(ErrorResponse{Error: "client parameter required"}); err != nil {
-			log.Printf("Error encoding response: %v", err)
-		}
+		writeError(w, http.StatusBadRequest, "client parameter required", "")
 		return
 	}
 
-	job := r.URL.Query().Get("job")
-	if job == "" {
-		job = "default"
+	job := jobParam(r)
+
+	opts, errMsg := releaseOptions(r)
+	if errMsg != "" {
+		writeError(w, http.StatusBadRequest, errMsg, "")
+		return
 	}
 
-	result := h.manager.Release(job, client)
+	if h.throttled(w, r, client, job) {
+		rateLimited(w)
+		return
+	}
+	result := h.manager.Release(job, client, opts...)
+	h.logRelease(r, client, result)
 
 	if !result.Success {
-		w.WriteHeader(http.StatusForbidden)
-		if err := json.NewEncoder(w).Encode(ErrorResponse{Error: result.Message}); err != nil {
-			log.Printf("Error encoding response: %v", err)
-		}
+		writeError(w, http.StatusForbidden, result.Message, string(result.Code))
 		return
 	}
 
-	log.Printf("Lock released by %s for job %s (held for %s)", client, job, result.HeldFor.Round(time.Second))
-	w.WriteHeader(http.StatusOK)
-	if err := json.NewEncoder(w).Encode(LockResponse{
-		Success: true,
-		Job:     job,
-		Message: result.Message,
-	}); err != nil {
-		log.Printf("Error encoding response: %v", err)
-	}
+	writeJSON(w, http.StatusOK, releaseResponse(result, time.Now()))
 }
 
 func (h *Handler) handleStatus(w http.ResponseWriter, r *http.Request) {
-	job := r.URL.Query().Get("job")
-	if job == "" {
-		job = "default"
-	}
-
-	status := h.manager.Status(job)
+	job := jobParam(r)
+	writeJSON(w, http.StatusOK, statusResponse(h.manager.Status(job), time.Now()))
+}
 
+// statusResponse describes the current state of a lock
+func statusResponse(status lockstate.StatusResult, now time.Time) LockResponse {
 	response := LockResponse{
 		Success:   true,
-		Job:       job,
+		Job:       status.Job,
 		Holder:    status.Holder,
 		IsExpired: status.IsExpired,
+		Metadata:  status.Metadata,
+		Session:   status.Session,
+
+		BlockedBy:       status.BlockedBy,
+		BlockedByHolder: status.BlockedByHolder,
 	}
 
 	if status.Holder != "" {
 		response.Message = msg.LockHeld
 		response.ExpiresAt = status.ExpiresAt.Format(time.RFC3339)
+		var graceUntil time.Time
 		if status.InGrace {
-			response.GraceUntil = status.GraceUntil.Format(time.RFC3339)
+			graceUntil = status.GraceUntil
+			response.GraceUntil = graceUntil.Format(time.RFC3339)
 		}
+		setMaxHold(&response, status.MaxHoldUntil, now)
+		setTiming(&response, now, status.AcquiredAt, status.ExpiresAt, graceUntil)
 	} else {
 		response.Message = msg.NoLockHeld
+		setTiming(&response, now, time.Time{}, time.Time{}, time.Time{})
+		if !status.CooldownUntil.IsZero() {
+			response.CooldownUntil = status.CooldownUntil.Format(time.RFC3339)
+		}
+	}
+	if !status.LastSuccessAt.IsZero() {
+		response.LastSuccessAt = status.LastSuccessAt.Format(time.RFC3339)
+		response.LastSuccessBy = status.LastSuccessBy
+	}
+	if !status.LastFailureAt.IsZero() {
+		response.LastFailureAt = status.LastFailureAt.Format(time.RFC3339)
+		response.LastFailureBy = status.LastFailureBy
+	}
+	return response
+}
+
+// acquireOptions parses optional acquisition parameters such as min_interval
+// and session
+func acquireOptions(r *http.Request) ([]lockstate.AcquireOption, bool) {
+	var opts []lockstate.AcquireOption
+	if v := r.URL.Query().Get("min_interval"); v != "" {
+		interval, err := time.ParseDuration(v)
+		if err != nil || interval < 0 {
+			return nil, false
+		}
+		opts = append(opts, lockstate.MinInterval(interval))
+	}
+	if v := r.URL.Query().Get("session"); v != "" {
+		opts = append(opts, lockstate.InSession(v))
+	}
+	return opts, true
+}
+
+// releaseOptions parses the run outcome reported on release: success,
+// exit_code and message. It returns an error message for invalid input.
+func releaseOptions(r *http.Request) ([]lockstate.ReleaseOption, string) {
+	q := r.URL.Query()
+
+	var succeeded *bool
+	if v := q.Get("success"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, "invalid success value"
+		}
+		succeeded = &b
+	}
+	var exitCode *int
+	if v := q.Get("exit_code"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, "invalid exit_code value"
+		}
+		exitCode = &n
+	}
+	return outcomeOptions(succeeded, exitCode, q.Get("message"))
+}
+
+// outcomeOptions turns a reported run outcome into release options. A
+// non-zero exit code implies failure unless success says otherwise.
+func outcomeOptions(succeeded *bool, exitCode *int, message string) ([]lockstate.ReleaseOption, string) {
+	if succeeded == nil && exitCode == nil {
+		if message != "" {
+			return nil, "message requires success or exit_code"
+		}
+		return nil, ""
+	}
+
+	outcome := lockstate.Outcome{Message: message}
+	if exitCode != nil {
+		outcome.ExitCode = *exitCode
+		outcome.Success = *exitCode == 0
+	}
+	if succeeded != nil {
+		outcome.Success = *succeeded
 	}
+	return []lockstate.ReleaseOption{lockstate.WithOutcome(outcome)}, ""
+}
+
+// setMaxHold fills in the holder's remaining maximum-hold budget as of now,
+// if limited
+func setMaxHold(response *LockResponse, until, now time.Time) {
+	if until.IsZero() {
+		return
+	}
+	response.MaxHoldUntil = until.Format(time.RFC3339)
+	response.MaxHoldRemaining = max(until.Sub(now), 0).Round(time.Second).String()
+}
+
+// jobParam returns the job query parameter, falling back to the default job
+func jobParam(r *http.Request) string {
+	job := r.URL.Query().Get("job")
+	if job == "" {
+		job = defaultJob
+	}
+	return job
+}
+
+// ttlParam parses the ttl query parameter; zero means the job's default
+func ttlParam(r *http.Request) (time.Duration, bool) {
+	ttlStr := r.URL.Query().Get("ttl")
+	if ttlStr == "" {
+		return 0, true
+	}
+	ttl, err := time.ParseDuration(ttlStr)
+	if err != nil {
+		return 0, false
+	}
+	return ttl, true
+}
+
+// writeError answers with an ErrorResponse; code may be empty
+func writeError(w http.ResponseWriter, status int, message, code string) {
+	writeJSON(w, status, ErrorResponse{
+		Error:      message,
+		Code:       code,
+		ServerTime: time.Now().Format(time.RFC3339Nano),
+	})
+}
 
-	w.WriteHeader(http.StatusOK)
-	if err := json.NewEncoder(w).Encode(response); err != nil {
-		log.Printf("Error encoding response: %v", err)
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Error("Error encoding response", "err", err)
 	}
 }
@@ -12,6 +12,19 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
+// doLock sends a request with query to h's /lock endpoint and decodes the
+// JSON reply into T
+func doLock[T any](t *testing.T, h *Handler, method, query string) (*httptest.ResponseRecorder, T) {
+	t.Helper()
+	req := httptest.NewRequest(method, "/lock"+query, nil)
+	w := httptest.NewRecorder()
+	h.HandleLock(w, req)
+
+	var resp T
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp), w.Body.String())
+	return w, resp
+}
+
 func TestHandleAcquire(t *testing.T) {
 	tests := []struct {
 		name   string
@@ -42,6 +55,20 @@ func TestHandleAcquire(t *testing.T) {
 				}
 			},
 		},
+		{
+			name:   "negative ttl",
+			setup:  nil,
+			query:  "?client=c1&ttl=-5s",
+			status: http.StatusBadRequest,
+			check: func(t *testing.T, m map[string]any) {
+				if m["error"] != msg.TTLOutOfRange {
+					t.Errorf("error = %v, want %v", m["error"], msg.TTLOutOfRange)
+				}
+				if m["code"] != string(lockstate.CodeTTLOutOfRange) {
+					t.Errorf("code = %v, want %v", m["code"], lockstate.CodeTTLOutOfRange)
+				}
+			},
+		},
 		{
 			name:   "success with default job",
 			setup:  nil,
@@ -60,6 +87,9 @@ func TestHandleAcquire(t *testing.T) {
 				if m["message"] != msg.Acquired {
 					t.Errorf("message = %v, want %v", m["message"], msg.Acquired)
 				}
+				if m["code"] != string(lockstate.CodeAcquired) {
+					t.Errorf("code = %v, want %v", m["code"], lockstate.CodeAcquired)
+				}
 			},
 		},
 		{
@@ -96,6 +126,28 @@ func TestHandleAcquire(t *testing.T) {
 				if m["message"] != msg.HeldByAnother {
 					t.Errorf("message = %v, want %v", m["message"], msg.HeldByAnother)
 				}
+				if m["code"] != string(lockstate.CodeHeldByAnother) {
+					t.Errorf("code = %v, want %v", m["code"], lockstate.CodeHeldByAnother)
+				}
+			},
+		},
+		{
+			name: "blocked by parent path",
+			setup: func(m *lockstate.Manager) {
+				m.Acquire("/photos", "other", time.Minute)
+			},
+			query:  "?client=c1&job=/photos/2024",
+			status: http.StatusConflict,
+			check: func(t *testing.T, m map[string]any) {
+				if m["holder"] != "other" {
+					t.Errorf("holder = %v, want other", m["holder"])
+				}
+				if m["blocked_by"] != "/photos" {
+					t.Errorf("blocked_by = %v, want /photos", m["blocked_by"])
+				}
+				if m["message"] != msg.RelatedPathHeld {
+					t.Errorf("message = %v, want %v", m["message"], msg.RelatedPathHeld)
+				}
 			},
 		},
 		{
@@ -122,17 +174,10 @@ func TestHandleAcquire(t *testing.T) {
 			if tt.setup != nil {
 				tt.setup(m)
 			}
-			h := New(m)
-			req := httptest.NewRequest(http.MethodPost, "/lock"+tt.query, nil)
-			w := httptest.NewRecorder()
-			h.HandleLock(w, req)
-
+			w, resp := doLock[map[string]any](t, New(m), http.MethodPost, tt.query)
 			if w.Code != tt.status {
 				t.Errorf("status = %d, want %d", w.Code, tt.status)
 			}
-			var resp map[string]any
-			err := json.Unmarshal(w.Body.Bytes(), &resp)
-			require.NoError(t, err)
 			tt.check(t, resp)
 		})
 	}
@@ -226,17 +271,10 @@ func TestHandleRelease(t *testing.T) {
 			if tt.setup != nil {
 				tt.setup(m)
 			}
-			h := New(m)
-			req := httptest.NewRequest(http.MethodDelete, "/lock"+tt.query, nil)
-			w := httptest.NewRecorder()
-			h.HandleLock(w, req)
-
+			w, resp := doLock[map[string]any](t, New(m), http.MethodDelete, tt.query)
 			if w.Code != tt.status {
 				t.Errorf("status = %d, want %d", w.Code, tt.status)
 			}
-			var resp map[string]any
-			err := json.Unmarshal(w.Body.Bytes(), &resp)
-			require.NoError(t, err)
 			tt.check(t, resp)
 		})
 	}
@@ -310,6 +348,24 @@ func TestHandleStatus(t *testing.T) {
 				}
 			},
 		},
+		{
+			name: "blocked by child path",
+			setup: func(m *lockstate.Manager) {
+				m.Acquire("/photos/2024", "c1", time.Minute)
+			},
+			query: "?job=/photos",
+			check: func(t *testing.T, m map[string]any) {
+				if m["holder"] != "" {
+					t.Errorf("holder = %v, want empty", m["holder"])
+				}
+				if m["blocked_by"] != "/photos/2024" {
+					t.Errorf("blocked_by = %v, want /photos/2024", m["blocked_by"])
+				}
+				if m["blocked_by_holder"] != "c1" {
+					t.Errorf("blocked_by_holder = %v, want c1", m["blocked_by_holder"])
+				}
+			},
+		},
 		{
 			name: "different jobs are independent",
 			setup: func(m *lockstate.Manager) {
@@ -339,19 +395,10 @@ func TestHandleStatus(t *testing.T) {
 			if tt.setup != nil {
 				tt.setup(m)
 			}
-			h := New(m)
-			req := httptest.NewRequest(http.MethodGet, "/lock"+tt.query, nil)
-			w := httptest.NewRecorder()
-			h.HandleLock(w, req)
-
+			w, resp := doLock[map[string]any](t, New(m), http.MethodGet, tt.query)
 			if w.Code != http.StatusOK {
 				t.Errorf("status = %d, want %d", w.Code, http.StatusOK)
 			}
-			var resp map[string]any
-
-			err := json.Unmarshal(w.Body.Bytes(), &resp)
-			require.NoError(t, err)
-
 			tt.check(t, resp)
 		})
 	}
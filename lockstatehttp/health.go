@@ -0,0 +1,153 @@
+package lockstatehttp
+
+import (
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"time"
+
+	"github.com/shadyabhi/foolock/lockstate/msg"
+)
+
+// BuildInfo identifies the running binary
+type BuildInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit,omitempty"`
+	Date      string `json:"date,omitempty"`
+	Modified  bool   `json:"modified,omitempty"`
+	GoVersion string `json:"go_version"`
+}
+
+// ReadBuildInfo combines the version, commit and date a release build sets
+// through -ldflags with what the Go toolchain embedded in the binary, which
+// fills in whatever the flags left empty
+func ReadBuildInfo(version, commit, date string) BuildInfo {
+	info := BuildInfo{Version: version, Commit: commit, Date: date, GoVersion: runtime.Version()}
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+	if info.Version == "" {
+		info.Version = bi.Main.Version
+	}
+	for _, s := range bi.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			if info.Commit == "" {
+				info.Commit = s.Value
+			}
+		case "vcs.time":
+			if info.Date == "" {
+				info.Date = s.Value
+			}
+		case "vcs.modified":
+			info.Modified = s.Value == "true"
+		}
+	}
+	return info
+}
+
+// WithBuildInfo sets what /version reports about the binary
+func WithBuildInfo(info BuildInfo) Option {
+	return func(h *Handler) {
+		h.build = info
+	}
+}
+
+// ReadinessCheck reports why a dependency isn't ready, or nil if it is
+type ReadinessCheck func() error
+
+type namedCheck struct {
+	name  string
+	check ReadinessCheck
+}
+
+// WithReadinessCheck adds a dependency /readyz must find ready
+func WithReadinessCheck(name string, check ReadinessCheck) Option {
+	return func(h *Handler) {
+		h.checks = append(h.checks, namedCheck{name, check})
+	}
+}
+
+type HealthResponse struct {
+	Status string `json:"status"`
+}
+
+type ReadyResponse struct {
+	Ready bool `json:"ready"`
+	// Checks maps each check to "ok" or the reason it failed
+	Checks map[string]string `json:"checks"`
+}
+
+type VersionResponse struct {
+	BuildInfo
+	StartedAt string `json:"started_at"`
+	UptimeMs  int64  `json:"uptime_ms"`
+	Jobs      int    `json:"jobs"`
+	Held      int    `json:"held"`
+	InGrace   int    `json:"in_grace"`
+}
+
+// HandleHealthz serves GET /healthz, which succeeds as long as the process
+// can answer at all
+func HandleHealthz(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	writeJSON(w, http.StatusOK, HealthResponse{Status: "ok"})
+}
+
+// HandleReadyz serves GET /readyz: 200 when the server should get traffic,
+// 503 while it's draining or a readiness check fails
+func (h *Handler) HandleReadyz(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+
+	response := ReadyResponse{Ready: true, Checks: map[string]string{"manager": "ok", "draining": "ok"}}
+	fail := func(name, reason string) {
+		response.Ready = false
+		response.Checks[name] = reason
+	}
+	if h.manager == nil {
+		fail("manager", "not initialised")
+	} else if h.manager.Draining() {
+		fail("draining", msg.Draining)
+	}
+	for _, c := range h.checks {
+		if err := c.check(); err != nil {
+			fail(c.name, err.Error())
+		} else {
+			response.Checks[c.name] = "ok"
+		}
+	}
+
+	status := http.StatusOK
+	if !response.Ready {
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, response)
+}
+
+// HandleVersion serves GET /version
+func (h *Handler) HandleVersion(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+
+	stats := h.manager.Stats()
+	writeJSON(w, http.StatusOK, VersionResponse{
+		BuildInfo: h.build,
+		StartedAt: h.started.Format(time.RFC3339),
+		UptimeMs:  time.Since(h.started).Milliseconds(),
+		Jobs:      stats.Jobs,
+		Held:      stats.Held,
+		InGrace:   stats.InGrace,
+	})
+}
@@ -0,0 +1,82 @@
+package lockstatehttp
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/shadyabhi/foolock/lockstate"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleHealthz(t *testing.T) {
+	w := serve(http.HandlerFunc(HandleHealthz), httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.JSONEq(t, `{"status":"ok"}`, w.Body.String())
+}
+
+func TestHandleReadyz(t *testing.T) {
+	var storeErr error
+	m := lockstate.New()
+	h := New(m, WithReadinessCheck("store", func() error { return storeErr }))
+
+	ready := func() (int, ReadyResponse) {
+		w := serve(http.HandlerFunc(h.HandleReadyz), httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		var resp ReadyResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		return w.Code, resp
+	}
+
+	status, resp := ready()
+	require.Equal(t, http.StatusOK, status)
+	require.True(t, resp.Ready)
+	require.Equal(t, map[string]string{"manager": "ok", "draining": "ok", "store": "ok"}, resp.Checks)
+
+	storeErr = errors.New("disk gone")
+	status, resp = ready()
+	require.Equal(t, http.StatusServiceUnavailable, status)
+	require.False(t, resp.Ready)
+	require.Equal(t, "disk gone", resp.Checks["store"])
+
+	storeErr = nil
+	m.SetDraining(true)
+	status, resp = ready()
+	require.Equal(t, http.StatusServiceUnavailable, status)
+	require.NotEqual(t, "ok", resp.Checks["draining"])
+
+	require.Empty(t, m.Jobs(), "probes must not create lock state")
+}
+
+func TestHandleVersion(t *testing.T) {
+	m := lockstate.New()
+	m.Acquire("a", "c1", time.Minute)
+	m.Acquire("b", "c2", time.Minute)
+	m.Release("b", "c2")
+	h := New(m, WithBuildInfo(BuildInfo{Version: "v1.2.3", Commit: "abc123", GoVersion: "go1.25"}))
+
+	w := serve(http.HandlerFunc(h.HandleVersion), httptest.NewRequest(http.MethodGet, "/version", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var resp VersionResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Equal(t, "v1.2.3", resp.Version)
+	require.Equal(t, "abc123", resp.Commit)
+	require.Equal(t, 2, resp.Jobs)
+	require.Equal(t, 1, resp.Held)
+	require.GreaterOrEqual(t, resp.UptimeMs, int64(0))
+	require.NotEmpty(t, resp.StartedAt)
+}
+
+func TestReadBuildInfo(t *testing.T) {
+	info := ReadBuildInfo("v1.2.3", "abc123", "2026-01-02T03:04:05Z")
+	require.Equal(t, "v1.2.3", info.Version)
+	require.Equal(t, "abc123", info.Commit)
+	require.Equal(t, "2026-01-02T03:04:05Z", info.Date)
+	require.NotEmpty(t, info.GoVersion)
+
+	// Without ldflags the module version fills in
+	require.NotEmpty(t, ReadBuildInfo("", "", "").Version)
+}
@@ -0,0 +1,48 @@
+package lockstatehttp
+
+import (
+	"net/http"
+	"time"
+)
+
+type HistoryResponse struct {
+	Job    string          `json:"job"`
+	Events []EventResponse `json:"events"`
+}
+
+type EventResponse struct {
+	Time    string `json:"time"`
+	Type    string `json:"type"`
+	Client  string `json:"client,omitempty"`
+	Holder  string `json:"holder,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// HandleHistory serves GET /lock/history, listing recent events for a job
+func (h *Handler) HandleHistory(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	job := jobParam(r)
+	events := h.manager.History(job)
+
+	response := HistoryResponse{
+		Job:    job,
+		Events: make([]EventResponse, 0, len(events)),
+	}
+	for _, e := range events {
+		response.Events = append(response.Events, EventResponse{
+			Time:    e.Time.Format(time.RFC3339Nano),
+			Type:    string(e.Type),
+			Client:  e.Client,
+			Holder:  e.Holder,
+			Message: e.Message,
+		})
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
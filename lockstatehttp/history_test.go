@@ -0,0 +1,44 @@
+package lockstatehttp
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/shadyabhi/foolock/lockstate"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleHistory(t *testing.T) {
+	m := lockstate.New()
+	m.Acquire("backup", "c1", time.Minute)
+	m.Acquire("backup", "c2", time.Minute)
+	m.Release("backup", "c1")
+
+	h := New(m)
+	req := httptest.NewRequest(http.MethodGet, "/lock/history?job=backup", nil)
+	w := httptest.NewRecorder()
+	h.HandleHistory(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var resp HistoryResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Equal(t, "backup", resp.Job)
+	require.Len(t, resp.Events, 3)
+	require.Equal(t, "acquired", resp.Events[0].Type)
+	require.Equal(t, "conflict", resp.Events[1].Type)
+	require.Equal(t, "c2", resp.Events[1].Client)
+	require.Equal(t, "released", resp.Events[2].Type)
+}
+
+func TestHandleHistoryMethodNotAllowed(t *testing.T) {
+	h := New(lockstate.New())
+	req := httptest.NewRequest(http.MethodPost, "/lock/history", nil)
+	w := httptest.NewRecorder()
+	h.HandleHistory(w, req)
+
+	require.Equal(t, http.StatusMethodNotAllowed, w.Code)
+}
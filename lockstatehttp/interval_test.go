@@ -0,0 +1,38 @@
+package lockstatehttp
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/shadyabhi/foolock/lockstate"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleMinInterval(t *testing.T) {
+	h := New(lockstate.New())
+
+	w, resp := doLock[map[string]any](t, h, http.MethodPost, "?client=c1&job=cron&min_interval=soon")
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	require.Equal(t, "invalid min_interval format", resp["error"])
+
+	w, _ = doLock[map[string]any](t, h, http.MethodPost, "?client=c1&job=cron&min_interval=1h")
+	require.Equal(t, http.StatusOK, w.Code)
+
+	w, resp = doLock[map[string]any](t, h, http.MethodDelete, "?client=c1&job=cron&success=maybe")
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	require.Equal(t, "invalid success value", resp["error"])
+
+	w, _ = doLock[map[string]any](t, h, http.MethodDelete, "?client=c1&job=cron&success=true")
+	require.Equal(t, http.StatusOK, w.Code)
+
+	w, resp = doLock[map[string]any](t, h, http.MethodPost, "?client=c2&job=cron&min_interval=1h")
+	require.Equal(t, http.StatusTooEarly, w.Code)
+	require.True(t, strings.HasSuffix(resp["message"].(string), " by c1"), resp["message"])
+	require.Equal(t, "c1", resp["last_success_by"])
+	require.NotEmpty(t, resp["next_run_at"])
+
+	_, resp = doLock[map[string]any](t, h, http.MethodGet, "?job=cron")
+	require.Equal(t, "c1", resp["last_success_by"])
+	require.NotEmpty(t, resp["last_success_at"])
+}
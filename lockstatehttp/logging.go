@@ -0,0 +1,82 @@
+package lockstatehttp
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/shadyabhi/foolock/lockstate"
+	"github.com/shadyabhi/foolock/lockstate/locklog"
+	"github.com/shadyabhi/foolock/peercred"
+)
+
+// RequestIDHeader carries the request ID in both directions
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLen bounds incoming request IDs so clients can't bloat the logs
+const maxRequestIDLen = 128
+
+type requestIDKey struct{}
+
+// WithRequestID gives every request an ID, taken from the X-Request-ID header
+// when the client sent a sensible one, and echoes it in the response so both
+// sides can find the same request in their logs
+func WithRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		w.Header().Set(RequestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+	})
+}
+
+// RequestID returns the ID WithRequestID assigned to the request, if any
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLen {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// requestLogger returns the handler's logger annotated with the request
+func (h *Handler) requestLogger(r *http.Request) *slog.Logger {
+	logger := h.logger.With("remote_addr", r.RemoteAddr)
+	if id := RequestID(r.Context()); id != "" {
+		logger = logger.With("request_id", id)
+	}
+	if cred, ok := peercred.FromContext(r.Context()); ok {
+		logger = logger.With("peer_uid", cred.UID, "peer_pid", cred.PID, "peer_process", cred.Process)
+	}
+	return logger
+}
+
+// logAcquire records an acquisition attempt, see locklog.Acquire
+func (h *Handler) logAcquire(r *http.Request, client string, ttl time.Duration, result lockstate.AcquireResult) {
+	locklog.Acquire(r.Context(), h.requestLogger(r), client, ttl, result)
+}
+
+// logRelease records a release attempt, see locklog.Release
+func (h *Handler) logRelease(r *http.Request, client string, result lockstate.ReleaseResult) {
+	locklog.Release(r.Context(), h.requestLogger(r), client, result)
+}
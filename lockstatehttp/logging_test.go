@@ -0,0 +1,108 @@
+package lockstatehttp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/shadyabhi/foolock/lockstate"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithRequestID(t *testing.T) {
+	tests := []struct {
+		name     string
+		incoming string
+		kept     bool
+	}{
+		{"generated when missing", "", false},
+		{"incoming honored", "abc-123", true},
+		{"whitespace replaced", "abc 123", false},
+		{"overlong replaced", strings.Repeat("x", maxRequestIDLen+1), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var seen string
+			handler := WithRequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				seen = RequestID(r.Context())
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/lock", nil)
+			if tt.incoming != "" {
+				req.Header.Set(RequestIDHeader, tt.incoming)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			require.NotEmpty(t, seen)
+			require.Equal(t, seen, w.Header().Get(RequestIDHeader))
+			if tt.kept {
+				require.Equal(t, tt.incoming, seen)
+			} else {
+				require.NotEqual(t, tt.incoming, seen)
+			}
+		})
+	}
+}
+
+// logEntries parses JSON log lines
+func logEntries(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var entries []map[string]any
+	scanner := bufio.NewScanner(buf)
+	for scanner.Scan() {
+		var entry map[string]any
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &entry))
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func TestLockOperationLogs(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	h := New(lockstate.New(), WithLogger(logger))
+	handler := WithRequestID(http.HandlerFunc(h.HandleLock))
+
+	do := func(method, url, requestID string) {
+		req := httptest.NewRequest(method, url, nil)
+		req.Header.Set(RequestIDHeader, requestID)
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	do(http.MethodPost, "/lock?job=backup&client=c1&ttl=1m", "req-1")
+	do(http.MethodPost, "/lock?job=backup&client=c2", "req-2")
+	do(http.MethodDelete, "/lock?job=backup&client=c1", "req-3")
+
+	entries := logEntries(t, &buf)
+	require.Len(t, entries, 3)
+
+	acquired := entries[0]
+	require.Equal(t, "INFO", acquired["level"])
+	require.Equal(t, "Lock acquire", acquired["msg"])
+	require.Equal(t, "req-1", acquired["request_id"])
+	require.Equal(t, "backup", acquired["job"])
+	require.Equal(t, "c1", acquired["client"])
+	require.Equal(t, string(lockstate.CodeAcquired), acquired["code"])
+	require.EqualValues(t, 60e9, acquired["ttl"])
+	require.NotEmpty(t, acquired["expires_at"])
+	require.NotEmpty(t, acquired["remote_addr"])
+
+	conflict := entries[1]
+	require.Equal(t, "DEBUG", conflict["level"])
+	require.Equal(t, "req-2", conflict["request_id"])
+	require.Equal(t, string(lockstate.CodeHeldByAnother), conflict["code"])
+	require.Equal(t, "c1", conflict["holder"])
+	require.NotContains(t, conflict, "ttl", "default ttl is not logged")
+
+	released := entries[2]
+	require.Equal(t, "INFO", released["level"])
+	require.Equal(t, "Lock release", released["msg"])
+	require.Equal(t, string(lockstate.CodeReleased), released["code"])
+	require.Contains(t, released, "held_for")
+}
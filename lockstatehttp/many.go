@@ -0,0 +1,208 @@
+package lockstatehttp
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/shadyabhi/foolock/lockstate"
+)
+
+type MultiLockResponse struct {
+	Success    bool           `json:"success"`
+	Holder     string         `json:"holder,omitempty"`
+	Message    string         `json:"message,omitempty"`
+	ServerTime string         `json:"server_time"`
+	Locks      []LockResponse `json:"locks"`
+}
+
+// HandleLocks serves /locks, acquiring or releasing several jobs atomically
+func (h *Handler) HandleLocks(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	switch r.Method {
+	case http.MethodPost:
+		h.handleAcquireMany(w, r)
+	case http.MethodDelete:
+		h.handleReleaseMany(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *Handler) handleAcquireMany(w http.ResponseWriter, r *http.Request) {
+	client, ok := requestClient(r, r.URL.Query().Get("client"))
+	if !ok {
+		reservedClient(w)
+		return
+	}
+	if client == "" {
+		writeError(w, http.StatusBadRequest, "client parameter required", "")
+		return
+	}
+
+	jobs := jobsParam(r)
+	if len(jobs) == 0 {
+		writeError(w, http.StatusBadRequest, "jobs parameter required", "")
+		return
+	}
+
+	ttl, ok := ttlParam(r)
+	if !ok {
+		writeError(w, http.StatusBadRequest, "invalid ttl format", "")
+		return
+	}
+
+	opts, ok := acquireOptions(r)
+	if !ok {
+		writeError(w, http.StatusBadRequest, "invalid min_interval format", "")
+		return
+	}
+
+	if !h.peerPermitted(r, jobs...) {
+		peerNotPermitted(w)
+		return
+	}
+	if h.throttled(w, r, client, jobs...) {
+		rateLimited(w)
+		return
+	}
+	result := h.manager.AcquireMany(jobs, client, ttl, opts...)
+	now := time.Now()
+	for _, res := range slices.Concat(result.Acquired, result.Conflicts) {
+		h.logAcquire(r, client, ttl, res)
+	}
+
+	if !result.Success {
+		status := http.StatusConflict
+		if slices.ContainsFunc(result.Conflicts, func(r lockstate.AcquireResult) bool { return r.Code == lockstate.CodeDraining }) {
+			status = http.StatusServiceUnavailable
+		}
+		setRetryAfter(w, latestAvailable(result.Conflicts))
+		writeJSON(w, status, MultiLockResponse{
+			Success:    false,
+			Message:    result.Message,
+			ServerTime: now.Format(time.RFC3339Nano),
+			Locks:      acquireResponses(result.Conflicts, now),
+		})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, MultiLockResponse{
+		Success:    true,
+		Holder:     client,
+		Message:    result.Message,
+		ServerTime: now.Format(time.RFC3339Nano),
+		Locks:      acquireResponses(result.Acquired, now),
+	})
+}
+
+func (h *Handler) handleReleaseMany(w http.ResponseWriter, r *http.Request) {
+	client, ok := requestClient(r, r.URL.Query().Get("client"))
+	if !ok {
+		reservedClient(w)
+		return
+	}
+	if client == "" {
+		writeError(w, http.StatusBadRequest, "client parameter required", "")
+		return
+	}
+
+	jobs := jobsParam(r)
+	if len(jobs) == 0 {
+		writeError(w, http.StatusBadRequest, "jobs parameter required", "")
+		return
+	}
+
+	opts, errMsg := releaseOptions(r)
+	if errMsg != "" {
+		writeError(w, http.StatusBadRequest, errMsg, "")
+		return
+	}
+
+	if h.throttled(w, r, client, jobs...) {
+		rateLimited(w)
+		return
+	}
+	result := h.manager.ReleaseMany(jobs, client, opts...)
+	now := time.Now()
+	for _, res := range slices.Concat(result.Released, result.NotHeld) {
+		h.logRelease(r, client, res)
+	}
+
+	if !result.Success {
+		writeJSON(w, http.StatusForbidden, MultiLockResponse{
+			Success:    false,
+			Message:    result.Message,
+			ServerTime: now.Format(time.RFC3339Nano),
+			Locks:      releaseResponses(result.NotHeld, now),
+		})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, MultiLockResponse{
+		Success:    true,
+		Message:    result.Message,
+		ServerTime: now.Format(time.RFC3339Nano),
+		Locks:      releaseResponses(result.Released, now),
+	})
+}
+
+// jobsParam collects job names from comma-separated jobs parameters
+func jobsParam(r *http.Request) []string {
+	var jobs []string
+	for _, v := range r.URL.Query()["jobs"] {
+		for job := range strings.SplitSeq(v, ",") {
+			if job = strings.TrimSpace(job); job != "" {
+				jobs = append(jobs, job)
+			}
+		}
+	}
+	return jobs
+}
+
+func acquireResponses(results []lockstate.AcquireResult, now time.Time) []LockResponse {
+	locks := make([]LockResponse, 0, len(results))
+	for _, result := range results {
+		locks = append(locks, acquireResponse(result, now))
+	}
+	return locks
+}
+
+// latestAvailable returns when every conflicting lock should be free
+func latestAvailable(results []lockstate.AcquireResult) time.Time {
+	var latest time.Time
+	for _, result := range results {
+		if result.AvailableAt.After(latest) {
+			latest = result.AvailableAt
+		}
+	}
+	return latest
+}
+
+func releaseResponses(results []lockstate.ReleaseResult, now time.Time) []LockResponse {
+	locks := make([]LockResponse, 0, len(results))
+	for _, result := range results {
+		locks = append(locks, releaseResponse(result, now))
+	}
+	return locks
+}
+
+// releaseResponse describes the result of releasing a single lock
+func releaseResponse(result lockstate.ReleaseResult, now time.Time) LockResponse {
+	response := LockResponse{
+		Success:    result.Success,
+		Code:       string(result.Code),
+		Job:        result.Job,
+		Message:    result.Message,
+		ServerTime: now.Format(time.RFC3339Nano),
+	}
+	if result.Success {
+		response.HeldForMs = millis(result.HeldFor)
+	}
+	if !result.CooldownUntil.IsZero() {
+		response.CooldownUntil = result.CooldownUntil.Format(time.RFC3339)
+	}
+	return response
+}
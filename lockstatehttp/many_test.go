@@ -0,0 +1,131 @@
+package lockstatehttp
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/shadyabhi/foolock/lockstate"
+	"github.com/shadyabhi/foolock/lockstate/msg"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleLocks(t *testing.T) {
+	tests := []struct {
+		name   string
+		setup  func(*lockstate.Manager)
+		method string
+		query  string
+		status int
+		check  func(*testing.T, map[string]any)
+	}{
+		{
+			name:   "missing client",
+			method: http.MethodPost,
+			query:  "?jobs=a,b",
+			status: http.StatusBadRequest,
+			check: func(t *testing.T, m map[string]any) {
+				require.Equal(t, "client parameter required", m["error"])
+			},
+		},
+		{
+			name:   "missing jobs",
+			method: http.MethodPost,
+			query:  "?client=c1",
+			status: http.StatusBadRequest,
+			check: func(t *testing.T, m map[string]any) {
+				require.Equal(t, "jobs parameter required", m["error"])
+			},
+		},
+		{
+			name:   "invalid ttl",
+			method: http.MethodPost,
+			query:  "?client=c1&jobs=a&ttl=bad",
+			status: http.StatusBadRequest,
+			check: func(t *testing.T, m map[string]any) {
+				require.Equal(t, "invalid ttl format", m["error"])
+			},
+		},
+		{
+			name:   "acquire all",
+			method: http.MethodPost,
+			query:  "?client=c1&jobs=photos,backup",
+			status: http.StatusOK,
+			check: func(t *testing.T, m map[string]any) {
+				require.Equal(t, true, m["success"])
+				require.Equal(t, "c1", m["holder"])
+				require.Equal(t, msg.AllLocksAcquired, m["message"])
+				require.Len(t, m["locks"], 2)
+			},
+		},
+		{
+			name: "acquire conflict",
+			setup: func(m *lockstate.Manager) {
+				m.Acquire("backup", "other", time.Minute)
+			},
+			method: http.MethodPost,
+			query:  "?client=c1&jobs=photos,backup",
+			status: http.StatusConflict,
+			check: func(t *testing.T, m map[string]any) {
+				require.Equal(t, false, m["success"])
+				require.Equal(t, msg.SomeLocksUnavailable, m["message"])
+				locks := m["locks"].([]any)
+				require.Len(t, locks, 1)
+				lock := locks[0].(map[string]any)
+				require.Equal(t, "backup", lock["job"])
+				require.Equal(t, "other", lock["holder"])
+			},
+		},
+		{
+			name: "release all",
+			setup: func(m *lockstate.Manager) {
+				m.AcquireMany([]string{"photos", "backup"}, "c1", time.Minute)
+			},
+			method: http.MethodDelete,
+			query:  "?client=c1&jobs=photos&jobs=backup",
+			status: http.StatusOK,
+			check: func(t *testing.T, m map[string]any) {
+				require.Equal(t, true, m["success"])
+				require.Equal(t, msg.AllLocksReleased, m["message"])
+				require.Len(t, m["locks"], 2)
+			},
+		},
+		{
+			name: "release not held",
+			setup: func(m *lockstate.Manager) {
+				m.Acquire("photos", "c1", time.Minute)
+			},
+			method: http.MethodDelete,
+			query:  "?client=c1&jobs=photos,backup",
+			status: http.StatusForbidden,
+			check: func(t *testing.T, m map[string]any) {
+				require.Equal(t, false, m["success"])
+				require.Equal(t, msg.ClientNotHolder, m["message"])
+				require.Len(t, m["locks"], 1)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := lockstate.New()
+			if tt.setup != nil {
+				tt.setup(m)
+			}
+			h := New(m)
+			req := httptest.NewRequest(tt.method, "/locks"+tt.query, nil)
+			w := httptest.NewRecorder()
+			h.HandleLocks(w, req)
+
+			if w.Code != tt.status {
+				t.Errorf("status = %d, want %d", w.Code, tt.status)
+			}
+			var resp map[string]any
+			err := json.Unmarshal(w.Body.Bytes(), &resp)
+			require.NoError(t, err)
+			tt.check(t, resp)
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package lockstatehttp
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/shadyabhi/foolock/lockstate"
+	"github.com/shadyabhi/foolock/lockstate/msg"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleMaxHold(t *testing.T) {
+	m := lockstate.New(lockstate.WithMaxHold(20 * time.Millisecond))
+	h := New(m)
+
+	w, resp := doLock[LockResponse](t, h, http.MethodPost, "?client=c1&ttl=10ms")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NotEmpty(t, resp.MaxHoldUntil)
+
+	_, resp = doLock[LockResponse](t, h, http.MethodGet, "")
+	require.NotEmpty(t, resp.MaxHoldUntil)
+	require.NotEmpty(t, resp.MaxHoldRemaining)
+
+	time.Sleep(25 * time.Millisecond)
+
+	w, resp = doLock[LockResponse](t, h, http.MethodPost, "?client=c1&ttl=10ms")
+	require.Equal(t, http.StatusGone, w.Code)
+	require.Equal(t, msg.MaxHoldExceeded, resp.Message)
+	require.NotEmpty(t, resp.GraceUntil)
+}
+
+func TestSetMaxHold(t *testing.T) {
+	now := time.Now()
+
+	var resp LockResponse
+	setMaxHold(&resp, now.Add(90*time.Second), now)
+	require.Equal(t, "1m30s", resp.MaxHoldRemaining, "measured from now, like the other timings")
+
+	setMaxHold(&resp, now.Add(-time.Second), now)
+	require.Equal(t, "0s", resp.MaxHoldRemaining)
+
+	resp = LockResponse{}
+	setMaxHold(&resp, time.Time{}, now)
+	require.Empty(t, resp.MaxHoldUntil)
+	require.Empty(t, resp.MaxHoldRemaining)
+}
@@ -0,0 +1,247 @@
+package lockstatehttp
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net/http"
+	"runtime/debug"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Middleware wraps a handler with behaviour shared by every endpoint
+type Middleware func(http.Handler) http.Handler
+
+// Chain wraps h in middlewares, the first listed being the outermost
+func Chain(h http.Handler, middlewares ...Middleware) http.Handler {
+	for _, mw := range slices.Backward(middlewares) {
+		h = mw(h)
+	}
+	return h
+}
+
+// Codes for errors raised by middleware rather than by a handler
+const (
+	codeInternalError   = "internal_error"
+	codeTimeout         = "timeout"
+	codeRequestTooLarge = "request_too_large"
+)
+
+// statusRecorder remembers the status and size of a response
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (s *statusRecorder) WriteHeader(status int) {
+	if s.status == 0 {
+		s.status = status
+	}
+	s.ResponseWriter.WriteHeader(status)
+}
+
+func (s *statusRecorder) Write(b []byte) (int, error) {
+	if s.status == 0 {
+		s.status = http.StatusOK
+	}
+	n, err := s.ResponseWriter.Write(b)
+	s.bytes += int64(n)
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (s *statusRecorder) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
+
+// Recover turns a handler panic into a 500 with a JSON ErrorResponse, if the
+// response hasn't started yet, and logs it with its stack. Without it
+// net/http just drops the connection.
+func Recover(logger *slog.Logger) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rec := &statusRecorder{ResponseWriter: w}
+			defer func() {
+				p := recover()
+				if p == nil {
+					return
+				}
+				if p == http.ErrAbortHandler {
+					panic(p)
+				}
+				logger.ErrorContext(r.Context(), "Panic serving request",
+					"method", r.Method,
+					"path", r.URL.Path,
+					"request_id", RequestID(r.Context()),
+					"panic", fmt.Sprint(p),
+					"stack", string(debug.Stack()),
+				)
+				if rec.status == 0 {
+					w.Header().Set("Content-Type", "application/json")
+					writeError(w, http.StatusInternalServerError, "internal server error", codeInternalError)
+				}
+			}()
+			next.ServeHTTP(rec, r)
+		})
+	}
+}
+
+// Timeout answers 503 with a JSON ErrorResponse when a read-only request
+// takes longer than d; whatever the handler writes afterwards is discarded.
+// Requests that can change a lock aren't bounded: the handler would carry on
+// after the 503, so a client told its request timed out could still end up
+// holding the lock.
+func Timeout(d time.Duration) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.Method {
+			case http.MethodGet, http.MethodHead, http.MethodOptions:
+			default:
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			ctx, cancel := context.WithTimeout(r.Context(), d)
+			defer cancel()
+			tw := &timeoutWriter{header: make(http.Header)}
+			done := make(chan struct{})
+			panicked := make(chan any, 1)
+			go func() {
+				defer func() {
+					if p := recover(); p != nil {
+						panicked <- p
+					}
+				}()
+				next.ServeHTTP(tw, r.WithContext(ctx))
+				close(done)
+			}()
+
+			select {
+			case p := <-panicked:
+				panic(p)
+			case <-done:
+				tw.mu.Lock()
+				defer tw.mu.Unlock()
+				maps.Copy(w.Header(), tw.header)
+				w.WriteHeader(cmp.Or(tw.status, http.StatusOK))
+				w.Write(tw.body.Bytes())
+			case <-ctx.Done():
+				tw.mu.Lock()
+				tw.timedOut = true
+				tw.mu.Unlock()
+				w.Header().Set("Content-Type", "application/json")
+				writeError(w, http.StatusServiceUnavailable, "request timed out", codeTimeout)
+			}
+		})
+	}
+}
+
+// timeoutWriter buffers a response so Timeout can drop it if the deadline
+// passes first
+type timeoutWriter struct {
+	mu       sync.Mutex
+	header   http.Header
+	status   int
+	body     bytes.Buffer
+	timedOut bool
+}
+
+func (tw *timeoutWriter) Header() http.Header {
+	return tw.header
+}
+
+func (tw *timeoutWriter) WriteHeader(status int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.status == 0 {
+		tw.status = status
+	}
+}
+
+func (tw *timeoutWriter) Write(p []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	if tw.status == 0 {
+		tw.status = http.StatusOK
+	}
+	return tw.body.Write(p)
+}
+
+// MaxBodySize rejects request bodies larger than n bytes with 413, up front
+// when the client declares the length and otherwise once a handler reads
+// past the limit
+func MaxBodySize(n int64) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.ContentLength > n {
+				w.Header().Set("Content-Type", "application/json")
+				writeError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("request body larger than %d bytes", n), codeRequestTooLarge)
+				return
+			}
+			r.Body = http.MaxBytesReader(w, r.Body, n)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// isBodyTooLarge reports whether err came from reading past MaxBodySize
+func isBodyTooLarge(err error) bool {
+	var maxErr *http.MaxBytesError
+	return errors.As(err, &maxErr)
+}
+
+// CORSConfig lists the browser origins allowed to call the API
+type CORSConfig struct {
+	// AllowedOrigins are matched exactly; "*" allows any origin
+	AllowedOrigins []string
+	// MaxAge is how long browsers may cache a preflight response
+	MaxAge time.Duration
+}
+
+// CORS lets dashboards on the allowed origins call the API from a browser.
+// Requests from other origins are served as usual but without the headers,
+// so the browser withholds the response from the page.
+func CORS(cfg CORSConfig) Middleware {
+	anyOrigin := slices.Contains(cfg.AllowedOrigins, "*")
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			if origin == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			w.Header().Add("Vary", "Origin")
+			if !anyOrigin && !slices.Contains(cfg.AllowedOrigins, origin) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			h := w.Header()
+			h.Set("Access-Control-Allow-Origin", origin)
+			h.Set("Access-Control-Expose-Headers", strings.Join([]string{"Retry-After", RequestIDHeader}, ", "))
+
+			if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+				h.Set("Access-Control-Allow-Methods", "GET, POST, DELETE")
+				h.Set("Access-Control-Allow-Headers", strings.Join([]string{"Content-Type", RequestIDHeader}, ", "))
+				if cfg.MaxAge > 0 {
+					h.Set("Access-Control-Max-Age", strconv.Itoa(int(cfg.MaxAge.Seconds())))
+				}
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
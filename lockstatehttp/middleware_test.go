@@ -0,0 +1,206 @@
+package lockstatehttp
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shadyabhi/foolock/lockstate"
+	"github.com/stretchr/testify/require"
+)
+
+func serve(h http.Handler, req *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w
+}
+
+func decodeError(t *testing.T, w *httptest.ResponseRecorder) ErrorResponse {
+	t.Helper()
+	require.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	var resp ErrorResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp), w.Body.String())
+	return resp
+}
+
+func TestChainOrder(t *testing.T) {
+	var order []string
+	mark := func(name string) Middleware {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				order = append(order, name)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+	h := Chain(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		order = append(order, "handler")
+	}), mark("outer"), mark("inner"))
+
+	serve(h, httptest.NewRequest(http.MethodGet, "/", nil))
+	require.Equal(t, []string{"outer", "inner", "handler"}, order)
+}
+
+func TestRecover(t *testing.T) {
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&logs, nil))
+
+	t.Run("before writing", func(t *testing.T) {
+		logs.Reset()
+		h := Recover(logger)(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+			panic("boom")
+		}))
+		w := serve(h, httptest.NewRequest(http.MethodGet, "/lock", nil))
+
+		require.Equal(t, http.StatusInternalServerError, w.Code)
+		require.Equal(t, codeInternalError, decodeError(t, w).Code)
+		require.Contains(t, logs.String(), `"panic":"boom"`)
+		require.Contains(t, logs.String(), "middleware_test.go", "stack is logged")
+	})
+
+	t.Run("after writing", func(t *testing.T) {
+		h := Recover(logger)(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusAccepted)
+			panic("boom")
+		}))
+		w := serve(h, httptest.NewRequest(http.MethodGet, "/lock", nil))
+
+		require.Equal(t, http.StatusAccepted, w.Code, "a started response can't be replaced")
+		require.Empty(t, w.Body.String())
+	})
+
+	t.Run("abort handler", func(t *testing.T) {
+		h := Recover(logger)(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+			panic(http.ErrAbortHandler)
+		}))
+		require.PanicsWithValue(t, http.ErrAbortHandler, func() {
+			serve(h, httptest.NewRequest(http.MethodGet, "/lock", nil))
+		})
+	})
+}
+
+func TestTimeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	slow := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	})
+	w := serve(Timeout(10*time.Millisecond)(slow), httptest.NewRequest(http.MethodGet, "/lock", nil))
+	require.Equal(t, http.StatusServiceUnavailable, w.Code)
+	resp := decodeError(t, w)
+	require.Equal(t, codeTimeout, resp.Code)
+	require.NotEmpty(t, resp.ServerTime)
+
+	fast := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		io.WriteString(w, "ok")
+	})
+	w = serve(Timeout(time.Second)(fast), httptest.NewRequest(http.MethodGet, "/lock", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "text/plain", w.Header().Get("Content-Type"))
+	require.Equal(t, "ok", w.Body.String())
+
+	// A client told its acquire timed out mustn't end up holding the lock
+	m := lockstate.New()
+	slowLock := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(30 * time.Millisecond)
+		New(m).HandleLock(w, r)
+	})
+	w = serve(Timeout(10*time.Millisecond)(slowLock), httptest.NewRequest(http.MethodPost, "/lock?client=c1", nil))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.Equal(t, "c1", m.Status(defaultJob).Holder)
+
+	require.PanicsWithValue(t, "boom", func() {
+		serve(Timeout(time.Second)(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+			panic("boom")
+		})), httptest.NewRequest(http.MethodGet, "/lock", nil))
+	})
+}
+
+func TestMaxBodySize(t *testing.T) {
+	var readErr error
+	h := MaxBodySize(8)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, readErr = io.ReadAll(r.Body)
+	}))
+
+	// Declared too large: refused without calling the handler
+	w := serve(h, httptest.NewRequest(http.MethodPost, "/lock", strings.NewReader("123456789")))
+	require.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	require.Equal(t, codeRequestTooLarge, decodeError(t, w).Code)
+
+	// Undeclared length: the handler's read fails
+	req := httptest.NewRequest(http.MethodPost, "/lock", strings.NewReader("123456789"))
+	req.ContentLength = -1
+	serve(h, req)
+	require.True(t, isBodyTooLarge(readErr))
+
+	serve(h, httptest.NewRequest(http.MethodPost, "/lock", strings.NewReader("12345678")))
+	require.NoError(t, readErr)
+}
+
+func TestMaxBodySizeV1(t *testing.T) {
+	mux := http.NewServeMux()
+	New(nil).RegisterV1(mux)
+	req := httptest.NewRequest(http.MethodPost, "/v1/locks/backup", strings.NewReader(`{"client":"c1","metadata":{"pad":"xxxxxxxx"}}`))
+	req.ContentLength = -1
+	w := serve(MaxBodySize(16)(mux), req)
+
+	require.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	var env v1Envelope
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &env))
+	require.Equal(t, codeRequestTooLarge, env.Error.Code)
+}
+
+func TestCORS(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		io.WriteString(w, "ok")
+	})
+	h := CORS(CORSConfig{AllowedOrigins: []string{"https://dash.example"}, MaxAge: time.Hour})(ok)
+
+	request := func(method, origin string, preflight bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/lock", nil)
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		if preflight {
+			req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+		}
+		return serve(h, req)
+	}
+
+	w := request(http.MethodGet, "https://dash.example", false)
+	require.Equal(t, "https://dash.example", w.Header().Get("Access-Control-Allow-Origin"))
+	require.Contains(t, w.Header().Get("Access-Control-Expose-Headers"), "Retry-After")
+	require.Equal(t, "Origin", w.Header().Get("Vary"))
+	require.Equal(t, "ok", w.Body.String())
+
+	w = request(http.MethodOptions, "https://dash.example", true)
+	require.Equal(t, http.StatusNoContent, w.Code)
+	require.Equal(t, "GET, POST, DELETE", w.Header().Get("Access-Control-Allow-Methods"))
+	require.Equal(t, "3600", w.Header().Get("Access-Control-Max-Age"))
+	require.Empty(t, w.Body.String())
+
+	w = request(http.MethodGet, "https://evil.example", false)
+	require.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+	require.Equal(t, "ok", w.Body.String())
+
+	w = request(http.MethodOptions, "https://evil.example", true)
+	require.Empty(t, w.Header().Get("Access-Control-Allow-Methods"))
+
+	w = request(http.MethodGet, "", false)
+	require.Empty(t, w.Header().Get("Vary"), "same-origin requests are untouched")
+
+	anyOrigin := CORS(CORSConfig{AllowedOrigins: []string{"*"}})(ok)
+	req := httptest.NewRequest(http.MethodGet, "/lock", nil)
+	req.Header.Set("Origin", "https://anywhere.example")
+	require.Equal(t, "https://anywhere.example", serve(anyOrigin, req).Header().Get("Access-Control-Allow-Origin"))
+}
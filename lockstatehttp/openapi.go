@@ -0,0 +1,26 @@
+package lockstatehttp
+
+import (
+	_ "embed"
+	"log/slog"
+	"net/http"
+)
+
+// openAPISpec describes every endpoint the server exposes. It is maintained
+// by hand; openapi_test.go checks real responses against it.
+//
+//go:embed openapi.json
+var openAPISpec []byte
+
+// HandleOpenAPI serves GET /openapi.json
+func HandleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if _, err := w.Write(openAPISpec); err != nil {
+		slog.Error("Error writing OpenAPI document", "err", err)
+	}
+}
@@ -0,0 +1,234 @@
+package lockstatehttp
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"github.com/shadyabhi/foolock/alert"
+	"github.com/shadyabhi/foolock/lockstate"
+	"github.com/shadyabhi/foolock/ratelimit"
+	"github.com/stretchr/testify/require"
+)
+
+// specMux wires up every endpoint the way main does
+func specMux(m *lockstate.Manager, opts ...Option) *http.ServeMux {
+	h := New(m, opts...)
+	mux := http.NewServeMux()
+	h.RegisterV1(mux)
+	mux.HandleFunc("/lock", h.HandleLock)
+	mux.HandleFunc("/lock/history", h.HandleHistory)
+	mux.HandleFunc("/locks", h.HandleLocks)
+	mux.HandleFunc("/runs", h.HandleRuns)
+	mux.HandleFunc("/openapi.json", HandleOpenAPI)
+	mux.HandleFunc("/healthz", HandleHealthz)
+	mux.HandleFunc("/readyz", h.HandleReadyz)
+	mux.HandleFunc("/version", h.HandleVersion)
+	mux.HandleFunc("/throttled", h.HandleThrottled)
+	mux.HandleFunc("/alerts", alert.NewEngine(m, nil).HandleAlerts)
+	return mux
+}
+
+// pointer escapes s for use as a JSON pointer token
+func pointer(s string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(s)
+}
+
+func TestOpenAPIResponses(t *testing.T) {
+	var spec map[string]any
+	require.NoError(t, json.Unmarshal(openAPISpec, &spec))
+
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(openAPISpec))
+	require.NoError(t, err)
+	compiler := jsonschema.NewCompiler()
+	require.NoError(t, compiler.AddResource("openapi.json", doc))
+
+	held := func(m *lockstate.Manager) { m.Acquire("backup", "c1", time.Minute) }
+	ran := func(m *lockstate.Manager) {
+		m.Acquire("backup", "c1", time.Minute)
+		m.Release("backup", "c1", lockstate.Succeeded())
+	}
+	draining := func(m *lockstate.Manager) { m.SetDraining(true) }
+	// Session ids are random, so urls and bodies name the last one created
+	// as {session}
+	var sessionID string
+	session := func(m *lockstate.Manager) {
+		sessionID = m.CreateSession("c1", time.Minute).Session.ID
+		m.Acquire("backup", "c1", 0, lockstate.InSession(sessionID))
+	}
+	oversized := `{"client":"c1","metadata":{"pad":"` + strings.Repeat("x", maxBodySize) + `"}}`
+	restricted := []lockstate.Option{
+		lockstate.WithPolicies(map[string]lockstate.Policy{"backup": {Clients: []string{"c1"}}}),
+		lockstate.WithStrictPolicies(true),
+	}
+
+	tests := []struct {
+		opts   []lockstate.Option
+		setup  func(*lockstate.Manager)
+		method string
+		url    string
+		body   string
+		// path is the spec's path template for url
+		path   string
+		status int
+	}{
+		{nil, held, http.MethodGet, "/lock?job=backup", "", "/lock", http.StatusOK},
+		{nil, nil, http.MethodGet, "/lock?job=backup", "", "/lock", http.StatusOK},
+		{nil, nil, http.MethodPost, "/lock?client=c1&job=backup", "", "/lock", http.StatusOK},
+		{nil, nil, http.MethodPost, "/lock?job=backup", "", "/lock", http.StatusBadRequest},
+		{nil, nil, http.MethodPost, "/lock?client=c1&ttl=-1s", "", "/lock", http.StatusBadRequest},
+		{restricted, nil, http.MethodPost, "/lock?client=c2&job=backup", "", "/lock", http.StatusForbidden},
+		{restricted, nil, http.MethodPost, "/lock?client=c1&job=other", "", "/lock", http.StatusNotFound},
+		{nil, held, http.MethodPost, "/lock?client=c2&job=backup", "", "/lock", http.StatusConflict},
+		{[]lockstate.Option{lockstate.WithMaxHold(time.Nanosecond)}, held, http.MethodPost, "/lock?client=c1&job=backup", "", "/lock", http.StatusGone},
+		{nil, ran, http.MethodPost, "/lock?client=c2&job=backup&min_interval=1h", "", "/lock", http.StatusTooEarly},
+		{nil, draining, http.MethodPost, "/lock?client=c1&job=backup", "", "/lock", http.StatusServiceUnavailable},
+		{nil, ran, http.MethodDelete, "/lock?client=c1&job=backup", "", "/lock", http.StatusForbidden},
+		{nil, held, http.MethodDelete, "/lock?client=c1&job=backup&exit_code=1", "", "/lock", http.StatusOK},
+		{nil, held, http.MethodDelete, "/lock?client=c1&job=backup&exit_code=x", "", "/lock", http.StatusBadRequest},
+		{nil, ran, http.MethodGet, "/lock/history?job=backup", "", "/lock/history", http.StatusOK},
+		{nil, nil, http.MethodPost, "/locks?client=c1&jobs=a,b", "", "/locks", http.StatusOK},
+		{nil, nil, http.MethodPost, "/locks?client=c1", "", "/locks", http.StatusBadRequest},
+		{nil, held, http.MethodPost, "/locks?client=c2&jobs=a,backup", "", "/locks", http.StatusConflict},
+		{nil, draining, http.MethodPost, "/locks?client=c1&jobs=a,b", "", "/locks", http.StatusServiceUnavailable},
+		{nil, held, http.MethodDelete, "/locks?client=c1&jobs=backup", "", "/locks", http.StatusOK},
+		{nil, held, http.MethodDelete, "/locks?client=c1", "", "/locks", http.StatusBadRequest},
+		{nil, held, http.MethodDelete, "/locks?client=c2&jobs=backup", "", "/locks", http.StatusForbidden},
+		{nil, ran, http.MethodGet, "/runs?job=backup", "", "/runs", http.StatusOK},
+		{nil, nil, http.MethodGet, "/alerts", "", "/alerts", http.StatusOK},
+		{nil, nil, http.MethodGet, "/openapi.json", "", "/openapi.json", http.StatusOK},
+		{nil, nil, http.MethodGet, "/healthz", "", "/healthz", http.StatusOK},
+		{nil, nil, http.MethodGet, "/readyz", "", "/readyz", http.StatusOK},
+		{nil, draining, http.MethodGet, "/readyz", "", "/readyz", http.StatusServiceUnavailable},
+		{nil, held, http.MethodGet, "/version", "", "/version", http.StatusOK},
+		{nil, nil, http.MethodGet, "/throttled", "", "/throttled", http.StatusOK},
+		{nil, held, http.MethodGet, "/v1/locks", "", "/v1/locks", http.StatusOK},
+		{nil, held, http.MethodGet, "/v1/locks/backup", "", "/v1/locks/{job}", http.StatusOK},
+		{nil, nil, http.MethodGet, "/v1/locks/", "", "/v1/locks/{job}", http.StatusBadRequest},
+		{nil, nil, http.MethodPost, "/v1/locks/backup", `{"client":"c1","metadata":{"host":"a"}}`, "/v1/locks/{job}", http.StatusOK},
+		{nil, nil, http.MethodPost, "/v1/locks/backup", `{}`, "/v1/locks/{job}", http.StatusBadRequest},
+		{restricted, nil, http.MethodPost, "/v1/locks/backup", `{"client":"c2"}`, "/v1/locks/{job}", http.StatusForbidden},
+		{restricted, nil, http.MethodPost, "/v1/locks/other", `{"client":"c1"}`, "/v1/locks/{job}", http.StatusNotFound},
+		{nil, held, http.MethodPost, "/v1/locks/backup", `{"client":"c2"}`, "/v1/locks/{job}", http.StatusConflict},
+		{[]lockstate.Option{lockstate.WithMaxHold(time.Nanosecond)}, held, http.MethodPost, "/v1/locks/backup", `{"client":"c1"}`, "/v1/locks/{job}", http.StatusGone},
+		{nil, ran, http.MethodPost, "/v1/locks/backup", `{"client":"c2","min_interval":"1h"}`, "/v1/locks/{job}", http.StatusTooEarly},
+		{nil, draining, http.MethodPost, "/v1/locks/backup", `{"client":"c1"}`, "/v1/locks/{job}", http.StatusServiceUnavailable},
+		{nil, nil, http.MethodPost, "/v1/locks/backup", oversized, "/v1/locks/{job}", http.StatusRequestEntityTooLarge},
+		{nil, held, http.MethodDelete, "/v1/locks/backup", `{"client":"c1","success":true}`, "/v1/locks/{job}", http.StatusOK},
+		{nil, held, http.MethodDelete, "/v1/locks/backup", `{"client":"c1","message":"x"}`, "/v1/locks/{job}", http.StatusBadRequest},
+		{nil, held, http.MethodDelete, "/v1/locks/backup", `{"client":"c2"}`, "/v1/locks/{job}", http.StatusForbidden},
+		{nil, held, http.MethodDelete, "/v1/locks/backup", oversized, "/v1/locks/{job}", http.StatusRequestEntityTooLarge},
+		{nil, session, http.MethodPost, "/v1/locks/other", `{"client":"c1","session":"{session}"}`, "/v1/locks/{job}", http.StatusOK},
+		{nil, nil, http.MethodPost, "/v1/locks/backup", `{"client":"c1","session":"gone"}`, "/v1/locks/{job}", http.StatusNotFound},
+		{nil, session, http.MethodPost, "/lock?client=c1&job=other&session={session}", "", "/lock", http.StatusOK},
+		{nil, nil, http.MethodPost, "/v1/sessions", `{"client":"c1","ttl":"1m"}`, "/v1/sessions", http.StatusOK},
+		{nil, nil, http.MethodPost, "/v1/sessions", `{"ttl":"1m"}`, "/v1/sessions", http.StatusBadRequest},
+		{nil, nil, http.MethodPost, "/v1/sessions", `{"client":"c1","ttl":"-1s"}`, "/v1/sessions", http.StatusBadRequest},
+		{nil, nil, http.MethodPost, "/v1/sessions", oversized, "/v1/sessions", http.StatusRequestEntityTooLarge},
+		{nil, draining, http.MethodPost, "/v1/sessions", `{"client":"c1"}`, "/v1/sessions", http.StatusServiceUnavailable},
+		{nil, session, http.MethodGet, "/v1/sessions/{session}", "", "/v1/sessions/{id}", http.StatusOK},
+		{nil, nil, http.MethodGet, "/v1/sessions/gone", "", "/v1/sessions/{id}", http.StatusNotFound},
+		{nil, session, http.MethodPost, "/v1/sessions/{session}/heartbeat", "", "/v1/sessions/{id}/heartbeat", http.StatusOK},
+		{nil, nil, http.MethodPost, "/v1/sessions/gone/heartbeat", "", "/v1/sessions/{id}/heartbeat", http.StatusNotFound},
+		{nil, session, http.MethodDelete, "/v1/sessions/{session}", "", "/v1/sessions/{id}", http.StatusOK},
+		{nil, nil, http.MethodDelete, "/v1/sessions/gone", "", "/v1/sessions/{id}", http.StatusNotFound},
+	}
+
+	// Each client gets one request, so the second of the same is throttled
+	throttled := []struct {
+		method string
+		url    string
+		body   string
+		path   string
+	}{
+		{http.MethodPost, "/lock?client=c1&job=backup", "", "/lock"},
+		{http.MethodDelete, "/lock?client=c1&job=backup", "", "/lock"},
+		{http.MethodPost, "/locks?client=c1&jobs=a,b", "", "/locks"},
+		{http.MethodDelete, "/locks?client=c1&jobs=a,b", "", "/locks"},
+		{http.MethodPost, "/v1/locks/backup", `{"client":"c1"}`, "/v1/locks/{job}"},
+		{http.MethodDelete, "/v1/locks/backup", `{"client":"c1"}`, "/v1/locks/{job}"},
+		{http.MethodPost, "/v1/sessions", `{"client":"c1"}`, "/v1/sessions"},
+	}
+
+	covered := map[string]bool{}
+	validate := func(t *testing.T, w *httptest.ResponseRecorder, method, path string) {
+		method = strings.ToLower(method)
+		status := strconv.Itoa(w.Code)
+		loc := fmt.Sprintf("/paths/%s/%s/responses/%s/content/application~1json/schema", pointer(path), method, status)
+		covered[path+" "+method+" "+status] = true
+
+		schema, err := compiler.Compile("openapi.json#" + loc)
+		require.NoError(t, err, "response not documented at %s", loc)
+
+		body, err := jsonschema.UnmarshalJSON(w.Body)
+		require.NoError(t, err)
+		require.NoError(t, schema.Validate(body))
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s %s", tt.method, tt.url), func(t *testing.T) {
+			m := lockstate.New(tt.opts...)
+			if tt.setup != nil {
+				tt.setup(m)
+			}
+			named := strings.NewReplacer("{session}", sessionID)
+			req := httptest.NewRequest(tt.method, named.Replace(tt.url), strings.NewReader(named.Replace(tt.body)))
+			w := httptest.NewRecorder()
+			specMux(m).ServeHTTP(w, req)
+			require.Equal(t, tt.status, w.Code, w.Body.String())
+			validate(t, w, tt.method, tt.path)
+		})
+	}
+
+	for _, tt := range throttled {
+		t.Run(fmt.Sprintf("throttled %s %s", tt.method, tt.url), func(t *testing.T) {
+			limiter := ratelimit.New(ratelimit.Config{Client: ratelimit.Limit{Rate: 0.001, Burst: 1}})
+			mux := specMux(lockstate.New(), WithRateLimiter(limiter))
+
+			var w *httptest.ResponseRecorder
+			for range 2 {
+				w = httptest.NewRecorder()
+				mux.ServeHTTP(w, httptest.NewRequest(tt.method, tt.url, strings.NewReader(tt.body)))
+			}
+			require.Equal(t, http.StatusTooManyRequests, w.Code, w.Body.String())
+			validate(t, w, tt.method, tt.path)
+		})
+	}
+
+	// Every documented response must be exercised above, so the spec can't
+	// keep describing behaviour the handlers dropped
+	var uncovered []string
+	for path, item := range spec["paths"].(map[string]any) {
+		for method, op := range item.(map[string]any) {
+			op, ok := op.(map[string]any)
+			if !ok || op["responses"] == nil {
+				continue
+			}
+			for status := range op["responses"].(map[string]any) {
+				if key := path + " " + method + " " + status; !covered[key] {
+					uncovered = append(uncovered, key)
+				}
+			}
+		}
+	}
+	slices.Sort(uncovered)
+	require.Empty(t, uncovered, "documented responses not covered by this test")
+}
+
+func TestHandleOpenAPI(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/openapi.json", nil)
+	w := httptest.NewRecorder()
+	HandleOpenAPI(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	require.JSONEq(t, string(openAPISpec), w.Body.String())
+}
@@ -0,0 +1,56 @@
+package lockstatehttp
+
+import (
+	"net/http"
+
+	"github.com/shadyabhi/foolock/lockstate"
+	"github.com/shadyabhi/foolock/lockstate/msg"
+	"github.com/shadyabhi/foolock/peercred"
+)
+
+// WithPeerPolicy restricts which jobs processes connecting over the Unix
+// socket may lock, by uid; without it they may lock any job
+func WithPeerPolicy(p peercred.Policy) Option {
+	return func(h *Handler) {
+		h.peerPolicy = p
+	}
+}
+
+// requestClient returns the client a request acts as. Over the Unix socket
+// that's the uid the kernel reported, narrowed by the name the request gives
+// if it gives one; elsewhere it's the name given, which is empty if there's
+// none. ok is false if a request from elsewhere names a client only Unix
+// socket peers can be.
+func requestClient(r *http.Request, named string) (client string, ok bool) {
+	if cred, ok := peercred.FromContext(r.Context()); ok {
+		return cred.Client(named), true
+	}
+	return named, !peercred.Reserved(named)
+}
+
+// peerPermitted reports whether the peer policy lets the request lock every
+// one of jobs; requests that didn't come over the Unix socket are left to the
+// job policies
+func (h *Handler) peerPermitted(r *http.Request, jobs ...string) bool {
+	cred, ok := peercred.FromContext(r.Context())
+	if !ok {
+		return true
+	}
+	for _, job := range jobs {
+		if !h.peerPolicy.Allows(cred.UID, job) {
+			return false
+		}
+	}
+	return true
+}
+
+// reservedClient answers a query-string API request naming a client only Unix
+// socket peers can be
+func reservedClient(w http.ResponseWriter) {
+	writeError(w, http.StatusForbidden, msg.ReservedClient, string(lockstate.CodeClientNotPermitted))
+}
+
+// peerNotPermitted answers a query-string API request the peer policy refused
+func peerNotPermitted(w http.ResponseWriter) {
+	writeError(w, http.StatusForbidden, msg.ClientNotPermitted, string(lockstate.CodeClientNotPermitted))
+}
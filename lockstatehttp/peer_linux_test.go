@@ -0,0 +1,59 @@
+package lockstatehttp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/shadyabhi/foolock/lockstate"
+	"github.com/shadyabhi/foolock/peercred"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnixSocketPeer(t *testing.T) {
+	m := lockstate.New()
+	h := New(m, WithPeerPolicy(peercred.Policy{uint32(os.Getuid()): {"backup"}}))
+	mux := http.NewServeMux()
+	h.RegisterV1(mux)
+
+	path := filepath.Join(t.TempDir(), "foolock.sock")
+	ln, err := net.Listen("unix", path)
+	require.NoError(t, err)
+	srv := httptest.NewUnstartedServer(mux)
+	srv.Listener.Close()
+	srv.Listener = ln
+	srv.Config.ConnContext = peercred.ConnContext
+	srv.Start()
+	defer srv.Close()
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", path)
+		},
+	}}
+	post := func(job string) (int, v1Envelope) {
+		resp, err := client.Post("http://foolock/v1/locks/"+job, "application/json", strings.NewReader(`{"client":"laptop1"}`))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		var env v1Envelope
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&env))
+		return resp.StatusCode, env
+	}
+
+	want := fmt.Sprintf("uid:%d/laptop1", os.Getuid())
+
+	status, env := post("backup")
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, want, env.Data.Holder)
+
+	status, env = post("sync")
+	require.Equal(t, http.StatusForbidden, status)
+	require.Equal(t, string(lockstate.CodeClientNotPermitted), env.Error.Code)
+}
@@ -0,0 +1,134 @@
+package lockstatehttp
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/shadyabhi/foolock/lockstate"
+	"github.com/shadyabhi/foolock/lockstate/msg"
+	"github.com/shadyabhi/foolock/peercred"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPeerIdentity(t *testing.T) {
+	m := lockstate.New()
+	mux := http.NewServeMux()
+	h := New(m, WithPeerPolicy(peercred.Policy{1000: {"backup", "/photos"}}))
+	h.RegisterV1(mux)
+	mux.HandleFunc("/lock", h.HandleLock)
+	mux.HandleFunc("/locks", h.HandleLocks)
+
+	cred := peercred.Cred{UID: 1000, PID: 42, Process: "rsync"}
+	do := func(cred *peercred.Cred, method, target, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		if cred != nil {
+			req = req.WithContext(peercred.NewContext(req.Context(), *cred))
+		}
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("the client is namespaced by uid", func(t *testing.T) {
+		w := do(&cred, http.MethodPost, "/lock?job=backup&client=rsync", "")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		require.Equal(t, "uid:1000/rsync", m.Status("backup").Holder)
+
+		w = do(&cred, http.MethodDelete, "/lock?client=uid:1000/rsync&job=backup", "")
+		require.Equal(t, http.StatusOK, w.Code, "the holder read back is the same client")
+	})
+
+	t.Run("an unnamed client is its uid", func(t *testing.T) {
+		w := do(&cred, http.MethodPost, "/lock?job=backup", "")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		require.Equal(t, "uid:1000", m.Status("backup").Holder)
+		w = do(&cred, http.MethodPost, "/v1/locks/backup", `{}`)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		w = do(&cred, http.MethodDelete, "/v1/locks/backup", `{}`)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	})
+
+	t.Run("named clients sharing a uid conflict", func(t *testing.T) {
+		cronA := peercred.Cred{UID: 1000, PID: 50, Process: "cron"}
+		cronB := peercred.Cred{UID: 1000, PID: 51, Process: "cron"}
+		w := do(&cronA, http.MethodPost, "/lock?job=backup&client=cronA", "")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		w = do(&cronB, http.MethodPost, "/lock?job=backup&client=cronB", "")
+		require.Equal(t, http.StatusConflict, w.Code, w.Body.String())
+		require.Equal(t, "uid:1000/cronA", m.Status("backup").Holder)
+
+		w = do(&cronB, http.MethodDelete, "/lock?job=backup&client=cronA", "")
+		require.Equal(t, http.StatusOK, w.Code, "the same user may name the holder")
+	})
+
+	t.Run("TCP clients can't act for a peer", func(t *testing.T) {
+		w := do(&cred, http.MethodPost, "/v1/locks/photos", `{"client":"laptop1"}`)
+		require.Equal(t, http.StatusForbidden, w.Code, "photos isn't /photos")
+
+		w = do(&cred, http.MethodPost, "/v1/locks/%2Fphotos%2F2024", `{"client":"laptop1"}`)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		require.Equal(t, "uid:1000/laptop1", m.Status("/photos/2024").Holder)
+
+		w = do(nil, http.MethodDelete, "/v1/locks/%2Fphotos%2F2024", `{"client":"laptop1"}`)
+		require.Equal(t, http.StatusForbidden, w.Code, "only the peer holds it")
+		other := peercred.Cred{UID: 1001, PID: 43, Process: "rsync"}
+		w = do(&other, http.MethodDelete, "/v1/locks/%2Fphotos%2F2024", `{"client":"uid:1000/laptop1"}`)
+		require.Equal(t, http.StatusForbidden, w.Code, "nor another user")
+		w = do(&cred, http.MethodDelete, "/v1/locks/%2Fphotos%2F2024", `{"client":"laptop1"}`)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	})
+
+	t.Run("TCP clients can't name themselves as a peer", func(t *testing.T) {
+		w := do(&cred, http.MethodPost, "/lock?job=backup&client=rsync", "")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		for _, tt := range []struct{ method, target, body string }{
+			{http.MethodPost, "/lock?job=backup&client=uid:1000/rsync", ""},
+			{http.MethodDelete, "/lock?job=backup&client=uid:1000/rsync", ""},
+			{http.MethodPost, "/locks?jobs=backup&client=uid:1000", ""},
+			{http.MethodDelete, "/locks?jobs=backup&client=uid:1000/rsync", ""},
+			{http.MethodPost, "/v1/locks/backup", `{"client":"uid:1000/rsync"}`},
+			{http.MethodDelete, "/v1/locks/backup", `{"client":"uid:1000/rsync"}`},
+			{http.MethodPost, "/v1/sessions", `{"client":"uid:1000/rsync"}`},
+		} {
+			w := do(nil, tt.method, tt.target, tt.body)
+			require.Equal(t, http.StatusForbidden, w.Code, "%s %s", tt.method, tt.target)
+			require.Contains(t, w.Body.String(), msg.ReservedClient)
+		}
+		require.Equal(t, "uid:1000/rsync", m.Status("backup").Holder)
+
+		w = do(&cred, http.MethodDelete, "/lock?job=backup&client=rsync", "")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	})
+
+	t.Run("the peer policy refuses other jobs", func(t *testing.T) {
+		w := do(&cred, http.MethodPost, "/lock?job=sync&client=rsync", "")
+		require.Equal(t, http.StatusForbidden, w.Code)
+		var resp ErrorResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		require.Equal(t, msg.ClientNotPermitted, resp.Error)
+		require.Equal(t, string(lockstate.CodeClientNotPermitted), resp.Code)
+
+		w = do(&cred, http.MethodPost, "/locks?jobs=backup,sync&client=rsync", "")
+		require.Equal(t, http.StatusForbidden, w.Code)
+		require.Empty(t, m.Status("backup").Holder, "all or nothing")
+
+		other := peercred.Cred{UID: 1001, PID: 43, Process: "rsync"}
+		w = do(&other, http.MethodPost, "/v1/locks/backup", `{"client":"rsync"}`)
+		require.Equal(t, http.StatusForbidden, w.Code)
+		var env v1Envelope
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &env))
+		require.Equal(t, string(lockstate.CodeClientNotPermitted), env.Error.Code)
+	})
+
+	t.Run("TCP clients are left to the job policies", func(t *testing.T) {
+		w := do(nil, http.MethodPost, "/lock?client=laptop1&job=sync", "")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		w = do(nil, http.MethodPost, "/lock?job=other", "")
+		require.Equal(t, http.StatusBadRequest, w.Code, "they still have to name themselves")
+	})
+}
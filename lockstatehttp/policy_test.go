@@ -0,0 +1,84 @@
+package lockstatehttp
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/shadyabhi/foolock/lockstate"
+	"github.com/shadyabhi/foolock/lockstate/msg"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleAcquirePolicy(t *testing.T) {
+	m := lockstate.New(
+		lockstate.WithPolicies(map[string]lockstate.Policy{
+			"backup": {
+				DefaultTTL: time.Hour,
+				MaxTTL:     2 * time.Hour,
+				Clients:    []string{"c1"},
+			},
+		}),
+		lockstate.WithStrictPolicies(true),
+	)
+	h := New(m)
+
+	tests := []struct {
+		name   string
+		query  string
+		status int
+		error  string
+	}{
+		{"unknown job", "?client=c1&job=other", http.StatusNotFound, msg.UnknownJob},
+		{"client not permitted", "?client=c2&job=backup", http.StatusForbidden, msg.ClientNotPermitted},
+		{"ttl above max", "?client=c1&job=backup&ttl=3h", http.StatusBadRequest, msg.TTLOutOfRange},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/lock"+tt.query, nil)
+			w := httptest.NewRecorder()
+			h.HandleLock(w, req)
+
+			require.Equal(t, tt.status, w.Code)
+			var resp ErrorResponse
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+			require.Equal(t, tt.error, resp.Error)
+		})
+	}
+
+	t.Run("default ttl from policy", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/lock?client=c1&job=backup", nil)
+		w := httptest.NewRecorder()
+		h.HandleLock(w, req)
+
+		require.Equal(t, http.StatusOK, w.Code)
+		var resp LockResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		expiresAt, err := time.Parse(time.RFC3339, resp.ExpiresAt)
+		require.NoError(t, err)
+		require.WithinDuration(t, time.Now().Add(time.Hour), expiresAt, 2*time.Second)
+	})
+}
+
+func TestHandleCooldown(t *testing.T) {
+	m := lockstate.New(lockstate.WithCooldown(time.Minute))
+	h := New(m)
+
+	doLock[LockResponse](t, h, http.MethodPost, "?client=c1&job=sync")
+
+	w, resp := doLock[LockResponse](t, h, http.MethodDelete, "?client=c1&job=sync")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NotEmpty(t, resp.CooldownUntil)
+
+	w, resp = doLock[LockResponse](t, h, http.MethodPost, "?client=c2&job=sync")
+	require.Equal(t, http.StatusConflict, w.Code)
+	require.Equal(t, msg.CooldownActive, resp.Message)
+	require.NotEmpty(t, resp.CooldownUntil)
+
+	_, resp = doLock[LockResponse](t, h, http.MethodGet, "?job=sync")
+	require.Equal(t, msg.NoLockHeld, resp.Message)
+	require.NotEmpty(t, resp.CooldownUntil)
+}
@@ -0,0 +1,70 @@
+package lockstatehttp
+
+import (
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/shadyabhi/foolock/peercred"
+	"github.com/shadyabhi/foolock/ratelimit"
+)
+
+// codeRateLimited is returned with 429 when a client or address is throttled
+const codeRateLimited = "rate_limited"
+
+// WithRateLimiter throttles acquisitions and releases before they reach the
+// lock manager
+func WithRateLimiter(l *ratelimit.Limiter) Option {
+	return func(h *Handler) {
+		h.limiter = l
+	}
+}
+
+// throttled reports whether a lock request must be refused, and if so sets
+// Retry-After
+func (h *Handler) throttled(w http.ResponseWriter, r *http.Request, client string, jobs ...string) bool {
+	if h.limiter == nil {
+		return false
+	}
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	if _, ok := peercred.FromContext(r.Context()); ok {
+		// Unix socket peers have no address; the client limit covers them
+		ip = ""
+	}
+	ok, retry := h.limiter.Allow(client, ip, jobs)
+	if ok {
+		return false
+	}
+	setRetryAfter(w, time.Now().Add(retry))
+	return true
+}
+
+// rateLimited answers a throttled query-string API request
+func rateLimited(w http.ResponseWriter) {
+	writeError(w, http.StatusTooManyRequests, "rate limit exceeded", codeRateLimited)
+}
+
+type ThrottledResponse struct {
+	// Refused requests so far, by client and by remote address
+	Clients map[string]uint64 `json:"clients"`
+	IPs     map[string]uint64 `json:"ips"`
+}
+
+// HandleThrottled serves GET /throttled
+func (h *Handler) HandleThrottled(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+
+	response := ThrottledResponse{Clients: map[string]uint64{}, IPs: map[string]uint64{}}
+	if h.limiter != nil {
+		t := h.limiter.Throttled()
+		response.Clients, response.IPs = t.Clients, t.IPs
+	}
+	writeJSON(w, http.StatusOK, response)
+}
@@ -0,0 +1,68 @@
+package lockstatehttp
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/shadyabhi/foolock/lockstate"
+	"github.com/shadyabhi/foolock/ratelimit"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimit(t *testing.T) {
+	m := lockstate.New()
+	limiter := ratelimit.New(ratelimit.Config{
+		Client: ratelimit.Limit{Rate: 0.5, Burst: 2},
+		Jobs:   map[string]ratelimit.Limit{"busy": {Rate: 0.1, Burst: 1}},
+	})
+	h := New(m, WithRateLimiter(limiter))
+	mux := http.NewServeMux()
+	h.RegisterV1(mux)
+	mux.HandleFunc("/lock", h.HandleLock)
+	mux.HandleFunc("/throttled", h.HandleThrottled)
+
+	do := func(method, url, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, url, strings.NewReader(body))
+		req.RemoteAddr = "192.0.2.1:1234"
+		return serve(mux, req)
+	}
+
+	require.Equal(t, http.StatusOK, do(http.MethodPost, "/lock?client=c1&job=backup", "").Code)
+	require.Equal(t, http.StatusConflict, do(http.MethodPost, "/lock?client=c2&job=backup", "").Code)
+	require.Equal(t, http.StatusConflict, do(http.MethodPost, "/lock?client=c2&job=backup", "").Code)
+
+	w := do(http.MethodPost, "/lock?client=c2&job=other", "")
+	require.Equal(t, http.StatusTooManyRequests, w.Code)
+	require.Equal(t, "2", w.Header().Get("Retry-After"))
+	require.Equal(t, codeRateLimited, decodeError(t, w).Code)
+	require.NotContains(t, m.Jobs(), "other", "throttled requests never reach the manager")
+
+	w = do(http.MethodPost, "/v1/locks/backup", `{"client":"c2"}`)
+	require.Equal(t, http.StatusTooManyRequests, w.Code)
+	var env v1Envelope
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &env))
+	require.Equal(t, codeRateLimited, env.Error.Code)
+
+	// The job limit is per client and on top of the client's own
+	require.Equal(t, http.StatusOK, do(http.MethodPost, "/lock?client=c3&job=busy", "").Code)
+	require.Equal(t, http.StatusTooManyRequests, do(http.MethodPost, "/lock?client=c3&job=busy", "").Code)
+	require.Equal(t, http.StatusOK, do(http.MethodPost, "/lock?client=c4&job=busy2", "").Code)
+
+	// Status reads aren't limited
+	require.Equal(t, http.StatusOK, do(http.MethodGet, "/lock?job=backup", "").Code)
+
+	w = do(http.MethodGet, "/throttled", "")
+	var counts ThrottledResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &counts))
+	require.Equal(t, map[string]uint64{"c2": 2, "c3": 1}, counts.Clients)
+	require.Empty(t, counts.IPs)
+}
+
+func TestHandleThrottledWithoutLimiter(t *testing.T) {
+	w := serve(http.HandlerFunc(New(lockstate.New()).HandleThrottled), httptest.NewRequest(http.MethodGet, "/throttled", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.JSONEq(t, `{"clients":{},"ips":{}}`, w.Body.String())
+}
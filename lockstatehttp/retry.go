@@ -0,0 +1,60 @@
+package lockstatehttp
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/shadyabhi/foolock/lockstate"
+)
+
+// acquireResponse describes an acquisition result; refusals include when the
+// client could expect the lock to become available
+func acquireResponse(result lockstate.AcquireResult, now time.Time) LockResponse {
+	response := LockResponse{
+		Success:   result.Success,
+		Code:      string(result.Code),
+		Job:       result.Job,
+		Holder:    result.Holder,
+		Message:   result.Message,
+		BlockedBy: result.BlockedBy,
+		Metadata:  result.Metadata,
+		Session:   result.Session,
+
+		PreviousHolder: result.PreviousHolder,
+	}
+	if !result.ExpiresAt.IsZero() {
+		response.ExpiresAt = result.ExpiresAt.Format(time.RFC3339)
+	}
+	if !result.GraceUntil.IsZero() {
+		response.GraceUntil = result.GraceUntil.Format(time.RFC3339)
+	}
+	if !result.MaxHoldUntil.IsZero() {
+		response.MaxHoldUntil = result.MaxHoldUntil.Format(time.RFC3339)
+	}
+	if !result.CooldownUntil.IsZero() {
+		response.CooldownUntil = result.CooldownUntil.Format(time.RFC3339)
+	}
+	if !result.LastSuccessAt.IsZero() {
+		response.LastSuccessAt = result.LastSuccessAt.Format(time.RFC3339)
+		response.LastSuccessBy = result.LastSuccessBy
+		response.NextRunAt = result.NextRunAt.Format(time.RFC3339)
+	}
+	if !result.AvailableAt.IsZero() {
+		response.AvailableAt = result.AvailableAt.Format(time.RFC3339)
+		response.RetryAfterMs = millis(result.AvailableAt.Sub(now))
+	}
+	setTiming(&response, now, result.AcquiredAt, result.ExpiresAt, result.GraceUntil)
+	return response
+}
+
+// setRetryAfter sets the Retry-After header in whole seconds, rounded up so
+// clients honoring it don't come back just before the lock frees up
+func setRetryAfter(w http.ResponseWriter, availableAt time.Time) {
+	if availableAt.IsZero() {
+		return
+	}
+	wait := time.Until(availableAt)
+	seconds := max(int64((wait+time.Second-1)/time.Second), 1)
+	w.Header().Set("Retry-After", strconv.FormatInt(seconds, 10))
+}
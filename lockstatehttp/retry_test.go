@@ -0,0 +1,61 @@
+package lockstatehttp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/shadyabhi/foolock/lockstate"
+	"github.com/shadyabhi/foolock/lockstate/msg"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleRetryAfter(t *testing.T) {
+	m := lockstate.New(lockstate.WithGracePeriod(time.Minute))
+	h := New(m)
+
+	w, resp := doLock[LockResponse](t, h, http.MethodPost, "?client=c1&ttl=30ms")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Empty(t, w.Header().Get("Retry-After"))
+	require.Nil(t, resp.RetryAfterMs)
+
+	w, resp = doLock[LockResponse](t, h, http.MethodPost, "?client=c2")
+	require.Equal(t, http.StatusConflict, w.Code)
+	require.Equal(t, msg.HeldByAnother, resp.Message)
+	require.Equal(t, resp.GraceUntil, resp.AvailableAt)
+	require.NotNil(t, resp.RetryAfterMs)
+	require.Greater(t, *resp.RetryAfterMs, int64(time.Minute/time.Millisecond/2))
+
+	retryAfter, err := strconv.Atoi(w.Header().Get("Retry-After"))
+	require.NoError(t, err)
+	require.InDelta(t, 60, retryAfter, 1)
+
+	time.Sleep(40 * time.Millisecond)
+
+	w, resp = doLock[LockResponse](t, h, http.MethodPost, "?client=c2")
+	require.Equal(t, http.StatusConflict, w.Code)
+	require.Equal(t, msg.GracePeriodActive, resp.Message)
+	require.Equal(t, "c1", resp.Holder)
+	require.NotEmpty(t, resp.ExpiresAt)
+	require.NotEmpty(t, resp.GraceUntil)
+	require.NotEmpty(t, w.Header().Get("Retry-After"))
+}
+
+func TestHandleRetryAfterMany(t *testing.T) {
+	m := lockstate.New(lockstate.WithGracePeriod(time.Minute))
+	h := New(m)
+
+	m.Acquire("a", "c1", time.Minute)
+	m.Acquire("b", "c1", 2*time.Minute)
+
+	req := httptest.NewRequest(http.MethodPost, "/locks?client=c2&jobs=a,b", nil)
+	w := httptest.NewRecorder()
+	h.HandleLocks(w, req)
+
+	require.Equal(t, http.StatusConflict, w.Code)
+	retryAfter, err := strconv.Atoi(w.Header().Get("Retry-After"))
+	require.NoError(t, err)
+	require.InDelta(t, 180, retryAfter, 1)
+}
@@ -0,0 +1,66 @@
+package lockstatehttp
+
+import (
+	"net/http"
+	"time"
+)
+
+type RunsResponse struct {
+	Job  string        `json:"job"`
+	Runs []RunResponse `json:"runs"`
+}
+
+type RunResponse struct {
+	Holder     string `json:"holder"`
+	AcquiredAt string `json:"acquired_at"`
+	ReleasedAt string `json:"released_at"`
+	HeldFor    string `json:"held_for"`
+	// Outcome is "success", "failure", "abandoned" when the holder let the
+	// lease lapse, or "unknown" when none was reported
+	Outcome  string `json:"outcome"`
+	ExitCode *int   `json:"exit_code,omitempty"`
+	Message  string `json:"message,omitempty"`
+}
+
+// HandleRuns serves GET /runs, listing recent runs of a job and how they went
+func (h *Handler) HandleRuns(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	job := jobParam(r)
+	runs := h.manager.Runs(job)
+
+	response := RunsResponse{
+		Job:  job,
+		Runs: make([]RunResponse, 0, len(runs)),
+	}
+	for _, run := range runs {
+		rr := RunResponse{
+			Holder:     run.Holder,
+			AcquiredAt: run.AcquiredAt.Format(time.RFC3339),
+			ReleasedAt: run.ReleasedAt.Format(time.RFC3339),
+			HeldFor:    run.HeldFor.Round(time.Millisecond).String(),
+			Outcome:    "unknown",
+		}
+		switch {
+		case run.Outcome == nil:
+		case run.Outcome.Abandoned:
+			rr.Outcome = "abandoned"
+			rr.Message = run.Outcome.Message
+		default:
+			rr.Outcome = "failure"
+			if run.Outcome.Success {
+				rr.Outcome = "success"
+			}
+			rr.ExitCode = &run.Outcome.ExitCode
+			rr.Message = run.Outcome.Message
+		}
+		response.Runs = append(response.Runs, rr)
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
@@ -0,0 +1,115 @@
+package lockstatehttp
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/shadyabhi/foolock/lockstate"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleReleaseOutcome(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		status   int
+		error    string
+		outcome  string
+		exitCode *int
+	}{
+		{"no outcome", "", http.StatusOK, "", "unknown", nil},
+		{"success", "&success=true", http.StatusOK, "", "success", intPtr(0)},
+		{"exit code implies failure", "&exit_code=3&message=boom", http.StatusOK, "", "failure", intPtr(3)},
+		{"success overrides exit code", "&exit_code=1&success=true", http.StatusOK, "", "success", intPtr(1)},
+		{"invalid exit code", "&exit_code=x", http.StatusBadRequest, "invalid exit_code value", "", nil},
+		{"message without outcome", "&message=hi", http.StatusBadRequest, "message requires success or exit_code", "", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := lockstate.New()
+			m.Acquire("backup", "c1", time.Minute)
+			h := New(m)
+
+			req := httptest.NewRequest(http.MethodDelete, "/lock?client=c1&job=backup"+tt.query, nil)
+			w := httptest.NewRecorder()
+			h.HandleLock(w, req)
+			require.Equal(t, tt.status, w.Code)
+
+			if tt.error != "" {
+				var resp ErrorResponse
+				require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+				require.Equal(t, tt.error, resp.Error)
+				return
+			}
+
+			req = httptest.NewRequest(http.MethodGet, "/runs?job=backup", nil)
+			w = httptest.NewRecorder()
+			h.HandleRuns(w, req)
+			require.Equal(t, http.StatusOK, w.Code)
+
+			var resp RunsResponse
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+			require.Equal(t, "backup", resp.Job)
+			require.Len(t, resp.Runs, 1)
+			require.Equal(t, "c1", resp.Runs[0].Holder)
+			require.Equal(t, tt.outcome, resp.Runs[0].Outcome)
+			require.Equal(t, tt.exitCode, resp.Runs[0].ExitCode)
+		})
+	}
+}
+
+func TestHandleStatusLastOutcomes(t *testing.T) {
+	m := lockstate.New()
+	m.Acquire("backup", "c1", time.Minute)
+	m.Release("backup", "c1", lockstate.Succeeded())
+	m.Acquire("backup", "c2", time.Minute)
+	m.Release("backup", "c2", lockstate.WithOutcome(lockstate.Outcome{ExitCode: 1}))
+
+	h := New(m)
+	req := httptest.NewRequest(http.MethodGet, "/lock?job=backup", nil)
+	w := httptest.NewRecorder()
+	h.HandleLock(w, req)
+
+	var resp LockResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Equal(t, "c1", resp.LastSuccessBy)
+	require.NotEmpty(t, resp.LastSuccessAt)
+	require.Equal(t, "c2", resp.LastFailureBy)
+	require.NotEmpty(t, resp.LastFailureAt)
+}
+
+func TestHandleRunsAbandoned(t *testing.T) {
+	m := lockstate.New(lockstate.WithGracePeriod(0))
+	m.Acquire("backup", "c1", time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	m.Acquire("backup", "c2", time.Minute)
+
+	h := New(m)
+	req := httptest.NewRequest(http.MethodGet, "/runs?job=backup", nil)
+	w := httptest.NewRecorder()
+	h.HandleRuns(w, req)
+
+	var resp RunsResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Len(t, resp.Runs, 1)
+	require.Equal(t, "c1", resp.Runs[0].Holder)
+	require.Equal(t, "abandoned", resp.Runs[0].Outcome)
+	require.Nil(t, resp.Runs[0].ExitCode)
+}
+
+func TestHandleRunsMethodNotAllowed(t *testing.T) {
+	h := New(lockstate.New())
+	req := httptest.NewRequest(http.MethodPost, "/runs", nil)
+	w := httptest.NewRecorder()
+	h.HandleRuns(w, req)
+
+	require.Equal(t, http.StatusMethodNotAllowed, w.Code)
+}
+
+func intPtr(i int) *int {
+	return &i
+}
@@ -0,0 +1,159 @@
+package lockstatehttp
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/shadyabhi/foolock/lockstate"
+	"github.com/shadyabhi/foolock/lockstate/locklog"
+	"github.com/shadyabhi/foolock/lockstate/msg"
+)
+
+// SessionRequest is the body of POST /v1/sessions
+type SessionRequest struct {
+	Client string `json:"client"`
+	TTL    string `json:"ttl,omitempty"`
+}
+
+// SessionResponse describes a session and the locks bound to it
+type SessionResponse struct {
+	ID        string   `json:"id"`
+	Client    string   `json:"client"`
+	TTL       string   `json:"ttl"`
+	CreatedAt string   `json:"created_at"`
+	ExpiresAt string   `json:"expires_at"`
+	Jobs      []string `json:"jobs"`
+	// Released holds the locks closing the session released
+	Released []LockResponse `json:"released,omitempty"`
+
+	ServerTime     string `json:"server_time"`
+	TTLRemainingMs *int64 `json:"ttl_remaining_ms"`
+}
+
+// registerSessions adds the /v1/sessions endpoints to mux. A session's id is
+// all it takes to renew or close it, so clients should keep it to themselves.
+func (h *Handler) registerSessions(mux *http.ServeMux) {
+	mux.HandleFunc("POST /v1/sessions", h.v1CreateSession)
+	mux.HandleFunc("GET /v1/sessions/{id}", h.v1Session)
+	mux.HandleFunc("POST /v1/sessions/{id}/heartbeat", h.v1Heartbeat)
+	mux.HandleFunc("DELETE /v1/sessions/{id}", h.v1CloseSession)
+}
+
+// sessionMethods returns the methods the session endpoint at path allows, or
+// "" if path isn't one
+func sessionMethods(path string) string {
+	id, rest, _ := strings.Cut(strings.TrimPrefix(path, "/v1/sessions/"), "/")
+	switch {
+	case path == "/v1/sessions":
+		return "POST"
+	case !strings.HasPrefix(path, "/v1/sessions/") || id == "":
+		return ""
+	case rest == "" && !strings.HasSuffix(path, "/"):
+		return "GET, DELETE"
+	case rest == "heartbeat":
+		return "POST"
+	}
+	return ""
+}
+
+func (h *Handler) v1CreateSession(w http.ResponseWriter, r *http.Request) {
+	var req SessionRequest
+	if !decodeBody(w, r, &req) {
+		return
+	}
+	client, ok := requestClient(r, req.Client)
+	if !ok {
+		writeAPIError(w, http.StatusForbidden, string(lockstate.CodeClientNotPermitted), msg.ReservedClient)
+		return
+	}
+	req.Client = client
+	if req.Client == "" {
+		writeAPIError(w, http.StatusBadRequest, codeInvalidRequest, "client required")
+		return
+	}
+
+	var ttl time.Duration
+	if req.TTL != "" {
+		var err error
+		if ttl, err = time.ParseDuration(req.TTL); err != nil {
+			writeAPIError(w, http.StatusBadRequest, codeInvalidRequest, "invalid ttl format")
+			return
+		}
+	}
+
+	if h.throttled(w, r, req.Client) {
+		writeAPIError(w, http.StatusTooManyRequests, codeRateLimited, "rate limit exceeded")
+		return
+	}
+	result := h.manager.CreateSession(req.Client, ttl)
+	locklog.Session(r.Context(), h.requestLogger(r), result.Session.ID, result)
+	writeSession(w, result)
+}
+
+func (h *Handler) v1Session(w http.ResponseWriter, r *http.Request) {
+	sess, ok := h.manager.Session(r.PathValue("id"))
+	if !ok {
+		writeSession(w, lockstate.SessionResult{Code: lockstate.CodeSessionNotFound, Message: msg.SessionNotFound})
+		return
+	}
+	writeEnvelope(w, http.StatusOK, Envelope{OK: true, Data: sessionResponse(sess, time.Now())})
+}
+
+// v1Heartbeat renews a session and its locks. Heartbeats aren't throttled:
+// one stands in for renewing every lock in the session.
+func (h *Handler) v1Heartbeat(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	result := h.manager.Heartbeat(id)
+	locklog.Session(r.Context(), h.requestLogger(r), id, result)
+	writeSession(w, result)
+}
+
+func (h *Handler) v1CloseSession(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	result := h.manager.CloseSession(id)
+	locklog.Session(r.Context(), h.requestLogger(r), id, result)
+	for _, released := range result.Released {
+		h.logRelease(r, result.Session.Client, released)
+	}
+	writeSession(w, result)
+}
+
+// writeSession answers a session request with the result from the manager
+func writeSession(w http.ResponseWriter, result lockstate.SessionResult) {
+	if !result.Success {
+		status := http.StatusNotFound
+		switch result.Code {
+		case lockstate.CodeTTLOutOfRange:
+			status = http.StatusBadRequest
+		case lockstate.CodeDraining:
+			status = http.StatusServiceUnavailable
+		}
+		writeAPIError(w, status, string(result.Code), result.Message)
+		return
+	}
+
+	now := time.Now()
+	response := sessionResponse(result.Session, now)
+	if len(result.Released) > 0 {
+		response.Released = releaseResponses(result.Released, now)
+	}
+	writeEnvelope(w, http.StatusOK, Envelope{OK: true, Data: response})
+}
+
+func sessionResponse(sess lockstate.Session, now time.Time) SessionResponse {
+	jobs := sess.Jobs
+	if jobs == nil {
+		jobs = []string{}
+	}
+	return SessionResponse{
+		ID:             sess.ID,
+		Client:         sess.Client,
+		TTL:            sess.TTL.String(),
+		CreatedAt:      sess.CreatedAt.Format(time.RFC3339),
+		ExpiresAt:      sess.ExpiresAt.Format(time.RFC3339),
+		Jobs:           jobs,
+		ServerTime:     now.Format(time.RFC3339Nano),
+		TTLRemainingMs: millis(sess.ExpiresAt.Sub(now)),
+	}
+}
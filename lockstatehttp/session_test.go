@@ -0,0 +1,146 @@
+package lockstatehttp
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shadyabhi/foolock/lockstate"
+	"github.com/stretchr/testify/require"
+)
+
+type sessionEnvelope struct {
+	OK    bool            `json:"ok"`
+	Data  SessionResponse `json:"data"`
+	Error *APIError       `json:"error"`
+}
+
+func doSession(t *testing.T, mux http.Handler, method, path, body string) (*httptest.ResponseRecorder, sessionEnvelope) {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	var env sessionEnvelope
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &env), w.Body.String())
+	return w, env
+}
+
+func TestV1Sessions(t *testing.T) {
+	m := lockstate.New()
+	mux := newV1Server(m)
+	mux.HandleFunc("/lock", New(m).HandleLock)
+
+	w, sess := doSession(t, mux, http.MethodPost, "/v1/sessions", `{"client":"c1","ttl":"1m"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.True(t, sess.OK)
+	id := sess.Data.ID
+	require.NotEmpty(t, id)
+	require.Equal(t, "c1", sess.Data.Client)
+	require.Equal(t, "1m0s", sess.Data.TTL)
+	require.Empty(t, sess.Data.Jobs)
+
+	w, lock := doV1(t, mux, http.MethodPost, "/v1/locks/backup", `{"client":"c1","session":"`+id+`"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.Equal(t, id, lock.Data.Session)
+	require.LessOrEqual(t, *lock.Data.TTLRemainingMs, time.Minute.Milliseconds())
+
+	req := httptest.NewRequest(http.MethodPost, "/lock?client=c1&job=sync&session="+id, nil)
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	_, lock = doV1(t, mux, http.MethodGet, "/v1/locks/sync", "")
+	require.Equal(t, id, lock.Data.Session)
+
+	w, sess = doSession(t, mux, http.MethodGet, "/v1/sessions/"+id, "")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, []string{"backup", "sync"}, sess.Data.Jobs)
+
+	w, sess = doSession(t, mux, http.MethodPost, "/v1/sessions/"+id+"/heartbeat", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, []string{"backup", "sync"}, sess.Data.Jobs)
+
+	w, sess = doSession(t, mux, http.MethodDelete, "/v1/sessions/"+id, "")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Len(t, sess.Data.Released, 2)
+	for _, released := range sess.Data.Released {
+		require.Equal(t, string(lockstate.CodeReleased), released.Code)
+	}
+	require.Empty(t, m.Status("backup").Holder)
+	require.Empty(t, m.Status("sync").Holder)
+
+	for _, path := range []string{"/v1/sessions/" + id, "/v1/sessions/" + id + "/heartbeat"} {
+		method := http.MethodGet
+		if strings.HasSuffix(path, "/heartbeat") {
+			method = http.MethodPost
+		}
+		w, sess = doSession(t, mux, method, path, "")
+		require.Equal(t, http.StatusNotFound, w.Code)
+		require.Equal(t, string(lockstate.CodeSessionNotFound), sess.Error.Code)
+	}
+}
+
+func TestV1SessionErrors(t *testing.T) {
+	m := lockstate.New()
+	other := m.CreateSession("c2", time.Minute).Session.ID
+	mux := newV1Server(m)
+	mux.HandleFunc("/lock", New(m).HandleLock)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		status int
+		code   string
+	}{
+		{"missing client", http.MethodPost, "/v1/sessions", `{"ttl":"1m"}`, http.StatusBadRequest, codeInvalidRequest},
+		{"invalid ttl", http.MethodPost, "/v1/sessions", `{"client":"c1","ttl":"soon"}`, http.StatusBadRequest, codeInvalidRequest},
+		{"ttl out of range", http.MethodPost, "/v1/sessions", `{"client":"c1","ttl":"-1s"}`, http.StatusBadRequest, string(lockstate.CodeTTLOutOfRange)},
+		{"unknown session", http.MethodPost, "/v1/locks/backup", `{"client":"c1","session":"gone"}`, http.StatusNotFound, string(lockstate.CodeSessionNotFound)},
+		{"another client's session", http.MethodPost, "/v1/locks/backup", `{"client":"c1","session":"` + other + `"}`, http.StatusForbidden, string(lockstate.CodeClientNotPermitted)},
+		{"list method not allowed", http.MethodGet, "/v1/sessions", "", http.StatusMethodNotAllowed, codeMethodNotAllowed},
+		{"method not allowed", http.MethodPut, "/v1/sessions/" + other, "", http.StatusMethodNotAllowed, codeMethodNotAllowed},
+		{"heartbeat method not allowed", http.MethodGet, "/v1/sessions/" + other + "/heartbeat", "", http.StatusMethodNotAllowed, codeMethodNotAllowed},
+		{"unknown endpoint", http.MethodGet, "/v1/sessions/" + other + "/nope", "", http.StatusNotFound, codeNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, env := doV1(t, mux, tt.method, tt.path, tt.body)
+			require.Equal(t, tt.status, w.Code, w.Body.String())
+			require.False(t, env.OK)
+			require.Equal(t, tt.code, env.Error.Code)
+		})
+	}
+
+	t.Run("legacy unknown session", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/lock?client=c1&session=gone", nil)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		require.Equal(t, http.StatusNotFound, w.Code)
+
+		var resp ErrorResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		require.Equal(t, string(lockstate.CodeSessionNotFound), resp.Code)
+	})
+}
+
+func TestSessionMethods(t *testing.T) {
+	tests := map[string]string{
+		"/v1/sessions":               "POST",
+		"/v1/sessions/abc":           "GET, DELETE",
+		"/v1/sessions/abc/heartbeat": "POST",
+		"/v1/sessions/":              "",
+		"/v1/sessions/abc/":          "",
+		"/v1/sessions/abc/nope":      "",
+		"/v1/locks/backup":           "",
+	}
+	for path, expected := range tests {
+		require.Equal(t, expected, sessionMethods(path), path)
+	}
+}
@@ -0,0 +1,25 @@
+package lockstatehttp
+
+import "time"
+
+// setTiming fills in server_time and durations relative to it, so clients
+// with a skewed clock can still tell how long a lease has left. now must come
+// from time.Now() so the durations use the monotonic clock.
+func setTiming(response *LockResponse, now, acquiredAt, expiresAt, graceUntil time.Time) {
+	response.ServerTime = now.Format(time.RFC3339Nano)
+	if !acquiredAt.IsZero() {
+		response.HeldForMs = millis(now.Sub(acquiredAt))
+	}
+	if !expiresAt.IsZero() {
+		response.TTLRemainingMs = millis(expiresAt.Sub(now))
+	}
+	if !graceUntil.IsZero() {
+		response.GraceRemainingMs = millis(graceUntil.Sub(now))
+	}
+}
+
+// millis converts d to whole milliseconds, never negative
+func millis(d time.Duration) *int64 {
+	ms := max(d, 0).Milliseconds()
+	return &ms
+}
@@ -0,0 +1,66 @@
+package lockstatehttp
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/shadyabhi/foolock/lockstate"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleRelativeDurations(t *testing.T) {
+	m := lockstate.New(lockstate.WithGracePeriod(time.Minute))
+	h := New(m)
+
+	_, resp := doLock[LockResponse](t, h, http.MethodPost, "?client=c1&ttl=1m")
+	_, err := time.Parse(time.RFC3339Nano, resp.ServerTime)
+	require.NoError(t, err)
+	require.NotNil(t, resp.TTLRemainingMs)
+	require.InDelta(t, time.Minute.Milliseconds(), *resp.TTLRemainingMs, 1000)
+	require.NotNil(t, resp.HeldForMs)
+	require.Nil(t, resp.GraceRemainingMs)
+
+	time.Sleep(20 * time.Millisecond)
+
+	_, resp = doLock[LockResponse](t, h, http.MethodGet, "")
+	require.NotEmpty(t, resp.ServerTime)
+	require.GreaterOrEqual(t, *resp.HeldForMs, int64(20))
+	require.Less(t, *resp.TTLRemainingMs, time.Minute.Milliseconds())
+
+	_, resp = doLock[LockResponse](t, h, http.MethodPost, "?client=c2")
+	require.NotNil(t, resp.GraceRemainingMs)
+	require.InDelta(t, 2*time.Minute.Milliseconds(), *resp.GraceRemainingMs, 1000)
+
+	_, resp = doLock[LockResponse](t, h, http.MethodDelete, "?client=c1")
+	require.NotEmpty(t, resp.ServerTime)
+	require.GreaterOrEqual(t, *resp.HeldForMs, int64(20))
+	require.Nil(t, resp.TTLRemainingMs)
+
+	_, resp = doLock[LockResponse](t, h, http.MethodGet, "")
+	require.NotEmpty(t, resp.ServerTime)
+	require.Nil(t, resp.HeldForMs)
+}
+
+func TestErrorServerTime(t *testing.T) {
+	m := lockstate.New()
+	mux := newV1Server(m)
+	mux.HandleFunc("/lock", New(m).HandleLock)
+
+	req := httptest.NewRequest(http.MethodPost, "/lock", nil)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+
+	var resp ErrorResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	_, err := time.Parse(time.RFC3339Nano, resp.ServerTime)
+	require.NoError(t, err)
+
+	w, env := doV1(t, mux, http.MethodPost, "/v1/locks/backup", `{}`)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	_, err = time.Parse(time.RFC3339Nano, env.Error.ServerTime)
+	require.NoError(t, err)
+}
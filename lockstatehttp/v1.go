@@ -0,0 +1,265 @@
+package lockstatehttp
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/shadyabhi/foolock/lockstate"
+	"github.com/shadyabhi/foolock/lockstate/msg"
+)
+
+// Codes for /v1 errors that don't come from the lock manager
+const (
+	codeInvalidRequest   = "invalid_request"
+	codeNotFound         = "not_found"
+	codeMethodNotAllowed = "method_not_allowed"
+)
+
+// maxBodySize bounds /v1 request bodies; they only carry a few fields
+const maxBodySize = 64 << 10
+
+// Envelope is the body of every /v1 response. Data holds the lock state even
+// when the request was refused, so clients can see who holds it and until
+// when.
+type Envelope struct {
+	OK    bool      `json:"ok"`
+	Data  any       `json:"data,omitempty"`
+	Error *APIError `json:"error,omitempty"`
+}
+
+type APIError struct {
+	Code       string `json:"code"`
+	Message    string `json:"message"`
+	ServerTime string `json:"server_time"`
+}
+
+// AcquireRequest is the body of POST /v1/locks/{job}; posting again as the
+// holder renews the lock
+type AcquireRequest struct {
+	Client      string            `json:"client"`
+	TTL         string            `json:"ttl,omitempty"`
+	MinInterval string            `json:"min_interval,omitempty"`
+	Metadata    map[string]string `json:"metadata,omitempty"`
+	// Session binds the lock to one of the client's sessions, see
+	// SessionRequest; its lease then follows the session's instead of ttl
+	Session string `json:"session,omitempty"`
+}
+
+// ReleaseRequest is the body of DELETE /v1/locks/{job}, optionally reporting
+// how the run went
+type ReleaseRequest struct {
+	Client   string `json:"client"`
+	Success  *bool  `json:"success,omitempty"`
+	ExitCode *int   `json:"exit_code,omitempty"`
+	Message  string `json:"message,omitempty"`
+}
+
+// RegisterV1 adds the /v1 resource API to mux. Job names are taken verbatim
+// from the rest of the path, so /v1/locks/photos/2024 is the job
+// "photos/2024"; escape a leading slash as %2F to address "/photos/2024".
+func (h *Handler) RegisterV1(mux *http.ServeMux) {
+	mux.HandleFunc("GET /v1/locks", h.v1List)
+	mux.HandleFunc("GET /v1/locks/{job...}", h.v1Status)
+	mux.HandleFunc("POST /v1/locks/{job...}", h.v1Acquire)
+	mux.HandleFunc("DELETE /v1/locks/{job...}", h.v1Release)
+	h.registerSessions(mux)
+	mux.HandleFunc("/v1/", v1Fallback)
+}
+
+func (h *Handler) v1List(w http.ResponseWriter, r *http.Request) {
+	now := time.Now()
+	locks := make([]LockResponse, 0)
+	for _, job := range h.manager.Jobs() {
+		locks = append(locks, statusResponse(h.manager.Status(job), now))
+	}
+	writeEnvelope(w, http.StatusOK, Envelope{OK: true, Data: locks})
+}
+
+func (h *Handler) v1Status(w http.ResponseWriter, r *http.Request) {
+	job, ok := v1Job(w, r)
+	if !ok {
+		return
+	}
+	writeEnvelope(w, http.StatusOK, Envelope{OK: true, Data: statusResponse(h.manager.Status(job), time.Now())})
+}
+
+func (h *Handler) v1Acquire(w http.ResponseWriter, r *http.Request) {
+	job, ok := v1Job(w, r)
+	if !ok {
+		return
+	}
+
+	var req AcquireRequest
+	if !decodeBody(w, r, &req) {
+		return
+	}
+	client, ok := requestClient(r, req.Client)
+	if !ok {
+		writeAPIError(w, http.StatusForbidden, string(lockstate.CodeClientNotPermitted), msg.ReservedClient)
+		return
+	}
+	req.Client = client
+	if req.Client == "" {
+		writeAPIError(w, http.StatusBadRequest, codeInvalidRequest, "client required")
+		return
+	}
+
+	var ttl time.Duration
+	if req.TTL != "" {
+		var err error
+		if ttl, err = time.ParseDuration(req.TTL); err != nil {
+			writeAPIError(w, http.StatusBadRequest, codeInvalidRequest, "invalid ttl format")
+			return
+		}
+	}
+
+	var opts []lockstate.AcquireOption
+	if req.MinInterval != "" {
+		interval, err := time.ParseDuration(req.MinInterval)
+		if err != nil || interval < 0 {
+			writeAPIError(w, http.StatusBadRequest, codeInvalidRequest, "invalid min_interval format")
+			return
+		}
+		opts = append(opts, lockstate.MinInterval(interval))
+	}
+	if req.Metadata != nil {
+		opts = append(opts, lockstate.WithMetadata(req.Metadata))
+	}
+	if req.Session != "" {
+		opts = append(opts, lockstate.InSession(req.Session))
+	}
+
+	if !h.peerPermitted(r, job) {
+		writeAPIError(w, http.StatusForbidden, string(lockstate.CodeClientNotPermitted), msg.ClientNotPermitted)
+		return
+	}
+	if h.throttled(w, r, req.Client, job) {
+		writeAPIError(w, http.StatusTooManyRequests, codeRateLimited, "rate limit exceeded")
+		return
+	}
+	result := h.manager.Acquire(job, req.Client, ttl, opts...)
+	status, response := acquireReply(result, time.Now())
+	h.logAcquire(r, req.Client, ttl, result)
+
+	if result.Success {
+		writeEnvelope(w, status, Envelope{OK: true, Data: response})
+		return
+	}
+
+	setRetryAfter(w, result.AvailableAt)
+	writeEnvelope(w, status, Envelope{
+		Data:  response,
+		Error: &APIError{Code: string(result.Code), Message: response.Message, ServerTime: response.ServerTime},
+	})
+}
+
+func (h *Handler) v1Release(w http.ResponseWriter, r *http.Request) {
+	job, ok := v1Job(w, r)
+	if !ok {
+		return
+	}
+
+	var req ReleaseRequest
+	if !decodeBody(w, r, &req) {
+		return
+	}
+	client, ok := requestClient(r, req.Client)
+	if !ok {
+		writeAPIError(w, http.StatusForbidden, string(lockstate.CodeClientNotPermitted), msg.ReservedClient)
+		return
+	}
+	req.Client = client
+	if req.Client == "" {
+		writeAPIError(w, http.StatusBadRequest, codeInvalidRequest, "client required")
+		return
+	}
+
+	opts, errMsg := outcomeOptions(req.Success, req.ExitCode, req.Message)
+	if errMsg != "" {
+		writeAPIError(w, http.StatusBadRequest, codeInvalidRequest, errMsg)
+		return
+	}
+
+	if h.throttled(w, r, req.Client, job) {
+		writeAPIError(w, http.StatusTooManyRequests, codeRateLimited, "rate limit exceeded")
+		return
+	}
+	result := h.manager.Release(job, req.Client, opts...)
+	response := releaseResponse(result, time.Now())
+	h.logRelease(r, req.Client, result)
+
+	if !result.Success {
+		writeEnvelope(w, http.StatusForbidden, Envelope{
+			Data:  response,
+			Error: &APIError{Code: string(result.Code), Message: result.Message, ServerTime: response.ServerTime},
+		})
+		return
+	}
+
+	writeEnvelope(w, http.StatusOK, Envelope{OK: true, Data: response})
+}
+
+// v1Fallback answers /v1 requests no route matched, so clients get an
+// envelope rather than the mux's plain-text errors
+func v1Fallback(w http.ResponseWriter, r *http.Request) {
+	if allow := sessionMethods(r.URL.Path); allow != "" {
+		w.Header().Set("Allow", allow)
+		writeAPIError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, "method not allowed")
+		return
+	}
+	if r.URL.Path == "/v1/locks" || strings.HasPrefix(r.URL.Path, "/v1/locks/") {
+		allow := "GET"
+		if r.URL.Path != "/v1/locks" {
+			allow = "GET, POST, DELETE"
+		}
+		w.Header().Set("Allow", allow)
+		writeAPIError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, "method not allowed")
+		return
+	}
+	writeAPIError(w, http.StatusNotFound, codeNotFound, "no such endpoint")
+}
+
+// v1Job returns the job named in the path, writing an error if it is empty
+func v1Job(w http.ResponseWriter, r *http.Request) (string, bool) {
+	job := r.PathValue("job")
+	if job == "" {
+		writeAPIError(w, http.StatusBadRequest, codeInvalidRequest, "job required")
+		return "", false
+	}
+	return job, true
+}
+
+// decodeBody parses a JSON request body into v, writing an error if it is
+// missing or malformed
+func decodeBody(w http.ResponseWriter, r *http.Request, v any) bool {
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize)).Decode(v)
+	switch {
+	case errors.Is(err, io.EOF):
+		writeAPIError(w, http.StatusBadRequest, codeInvalidRequest, "request body required")
+		return false
+	case isBodyTooLarge(err):
+		writeAPIError(w, http.StatusRequestEntityTooLarge, codeRequestTooLarge, "request body too large")
+		return false
+	case err != nil:
+		writeAPIError(w, http.StatusBadRequest, codeInvalidRequest, "invalid request body: "+err.Error())
+		return false
+	}
+	return true
+}
+
+func writeAPIError(w http.ResponseWriter, status int, code, message string) {
+	writeEnvelope(w, status, Envelope{Error: &APIError{
+		Code:       code,
+		Message:    message,
+		ServerTime: time.Now().Format(time.RFC3339Nano),
+	}})
+}
+
+func writeEnvelope(w http.ResponseWriter, status int, env Envelope) {
+	w.Header().Set("Content-Type", "application/json")
+	writeJSON(w, status, env)
+}
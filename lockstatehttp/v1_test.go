@@ -0,0 +1,153 @@
+package lockstatehttp
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shadyabhi/foolock/lockstate"
+	"github.com/stretchr/testify/require"
+)
+
+type v1Envelope struct {
+	OK    bool         `json:"ok"`
+	Data  LockResponse `json:"data"`
+	Error *APIError    `json:"error"`
+}
+
+func newV1Server(m *lockstate.Manager) *http.ServeMux {
+	mux := http.NewServeMux()
+	New(m).RegisterV1(mux)
+	return mux
+}
+
+func doV1(t *testing.T, mux *http.ServeMux, method, path, body string) (*httptest.ResponseRecorder, v1Envelope) {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	var env v1Envelope
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &env), w.Body.String())
+	return w, env
+}
+
+func TestV1Lifecycle(t *testing.T) {
+	mux := newV1Server(lockstate.New())
+
+	w, env := doV1(t, mux, http.MethodPost, "/v1/locks/backup", `{"client":"c1","ttl":"1m","metadata":{"host":"laptop1"}}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.True(t, env.OK)
+	require.Nil(t, env.Error)
+	require.Equal(t, "backup", env.Data.Job)
+	require.Equal(t, string(lockstate.CodeAcquired), env.Data.Code)
+	require.Equal(t, map[string]string{"host": "laptop1"}, env.Data.Metadata)
+
+	w, env = doV1(t, mux, http.MethodPost, "/v1/locks/backup", `{"client":"c1","ttl":"1m"}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, string(lockstate.CodeRenewed), env.Data.Code)
+
+	w, env = doV1(t, mux, http.MethodPost, "/v1/locks/backup", `{"client":"c2"}`)
+	require.Equal(t, http.StatusConflict, w.Code)
+	require.False(t, env.OK)
+	require.Equal(t, string(lockstate.CodeHeldByAnother), env.Error.Code)
+	require.Equal(t, "c1", env.Data.Holder)
+	require.NotEmpty(t, w.Header().Get("Retry-After"))
+
+	w, env = doV1(t, mux, http.MethodGet, "/v1/locks/backup", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "c1", env.Data.Holder)
+	require.Equal(t, "laptop1", env.Data.Metadata["host"])
+
+	w, env = doV1(t, mux, http.MethodDelete, "/v1/locks/backup", `{"client":"c2"}`)
+	require.Equal(t, http.StatusForbidden, w.Code)
+	require.Equal(t, string(lockstate.CodeNotHolder), env.Error.Code)
+
+	w, env = doV1(t, mux, http.MethodDelete, "/v1/locks/backup", `{"client":"c1","success":true}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, string(lockstate.CodeReleased), env.Data.Code)
+
+	_, env = doV1(t, mux, http.MethodGet, "/v1/locks/backup", "")
+	require.Empty(t, env.Data.Holder)
+	require.Equal(t, "c1", env.Data.LastSuccessBy)
+}
+
+func TestV1PathJobs(t *testing.T) {
+	m := lockstate.New()
+	mux := newV1Server(m)
+
+	w, env := doV1(t, mux, http.MethodPost, "/v1/locks/%2Fphotos%2F2024", `{"client":"c1"}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "/photos/2024", env.Data.Job)
+
+	w, env = doV1(t, mux, http.MethodPost, "/v1/locks/%2Fphotos", `{"client":"c2"}`)
+	require.Equal(t, http.StatusConflict, w.Code)
+	require.Equal(t, "/photos/2024", env.Data.BlockedBy)
+
+	w, env = doV1(t, mux, http.MethodPost, "/v1/locks/photos/2024", `{"client":"c2"}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "photos/2024", env.Data.Job)
+}
+
+func TestV1List(t *testing.T) {
+	m := lockstate.New()
+	m.Acquire("a", "c1", time.Minute)
+	m.Acquire("b", "c2", time.Minute)
+	mux := newV1Server(m)
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/locks", nil)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var env struct {
+		OK   bool           `json:"ok"`
+		Data []LockResponse `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &env))
+	require.True(t, env.OK)
+	require.Len(t, env.Data, 2)
+	require.Equal(t, "a", env.Data[0].Job)
+	require.Equal(t, "c2", env.Data[1].Holder)
+}
+
+func TestV1Errors(t *testing.T) {
+	m := lockstate.New(lockstate.WithPolicies(map[string]lockstate.Policy{
+		"restricted": {Clients: []string{"c1"}},
+	}))
+	mux := newV1Server(m)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		status int
+		code   string
+	}{
+		{"missing body", http.MethodPost, "/v1/locks/backup", "", http.StatusBadRequest, codeInvalidRequest},
+		{"malformed body", http.MethodPost, "/v1/locks/backup", "{", http.StatusBadRequest, codeInvalidRequest},
+		{"missing client", http.MethodPost, "/v1/locks/backup", `{"ttl":"1m"}`, http.StatusBadRequest, codeInvalidRequest},
+		{"invalid ttl", http.MethodPost, "/v1/locks/backup", `{"client":"c1","ttl":"soon"}`, http.StatusBadRequest, codeInvalidRequest},
+		{"ttl out of range", http.MethodPost, "/v1/locks/backup", `{"client":"c1","ttl":"-1s"}`, http.StatusBadRequest, string(lockstate.CodeTTLOutOfRange)},
+		{"client not permitted", http.MethodPost, "/v1/locks/restricted", `{"client":"c2"}`, http.StatusForbidden, string(lockstate.CodeClientNotPermitted)},
+		{"message without outcome", http.MethodDelete, "/v1/locks/backup", `{"client":"c1","message":"done"}`, http.StatusBadRequest, codeInvalidRequest},
+		{"empty job", http.MethodPost, "/v1/locks/", `{"client":"c1"}`, http.StatusBadRequest, codeInvalidRequest},
+		{"method not allowed", http.MethodPut, "/v1/locks/backup", `{}`, http.StatusMethodNotAllowed, codeMethodNotAllowed},
+		{"unknown endpoint", http.MethodGet, "/v1/nope", "", http.StatusNotFound, codeNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, env := doV1(t, mux, tt.method, tt.path, tt.body)
+			require.Equal(t, tt.status, w.Code)
+			require.False(t, env.OK)
+			require.NotNil(t, env.Error)
+			require.Equal(t, tt.code, env.Error.Code)
+			require.Equal(t, "application/json", w.Header().Get("Content-Type"))
+		})
+	}
+}
@@ -0,0 +1,409 @@
+package lockstateresp
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shadyabhi/foolock/lockstate"
+	"github.com/shadyabhi/foolock/lockstate/locklog"
+	"github.com/shadyabhi/foolock/lockstate/msg"
+	"github.com/shadyabhi/foolock/peercred"
+)
+
+// request is one command being answered
+type request struct {
+	server *Server
+	logger *slog.Logger
+	addr   net.Addr
+	w      writer
+	args   []string
+}
+
+// command answers a request; arity is the exact number of arguments,
+// including the command name, or the minimum if negative
+type command struct {
+	arity int
+	run   func(*request)
+}
+
+var commands = map[string]command{
+	"PING":    {-1, ping},
+	"ECHO":    {2, echo},
+	"QUIT":    {1, func(r *request) { r.w.simple("OK") }},
+	"SELECT":  {2, func(r *request) { r.w.simple("OK") }},
+	"CLIENT":  {-2, client},
+	"HELLO":   {-1, func(r *request) { r.w.error("NOPROTO this server only speaks RESP2") }},
+	"COMMAND": {-1, func(r *request) { r.w.array(0) }},
+
+	"SET":     {-3, set},
+	"GET":     {2, get},
+	"EXISTS":  {-2, exists},
+	"PTTL":    {2, func(r *request) { ttl(r, time.Millisecond) }},
+	"TTL":     {2, func(r *request) { ttl(r, time.Second) }},
+	"DEL":     {-2, del},
+	"UNLINK":  {-2, del},
+	"DELIFEQ": {3, delIfEq},
+	"EVAL":    {-3, eval},
+	"EVALSHA": {-3, evalSHA},
+	"SCRIPT":  {-2, scriptCommand},
+
+	"FOOLOCK.STATUS": {2, status},
+	"FOOLOCK.GRACE":  {2, grace},
+}
+
+// dispatch answers one command, reporting whether the client asked to quit
+func (s *Server) dispatch(logger *slog.Logger, addr net.Addr, w writer, args []string) bool {
+	name := strings.ToUpper(args[0])
+	cmd, ok := commands[name]
+	if !ok {
+		w.error(fmt.Sprintf("ERR unknown command '%s'", args[0]))
+		return false
+	}
+	if (cmd.arity > 0 && len(args) != cmd.arity) || (cmd.arity < 0 && len(args) < -cmd.arity) {
+		w.error(fmt.Sprintf("ERR wrong number of arguments for '%s' command", strings.ToLower(args[0])))
+		return false
+	}
+	cmd.run(&request{server: s, logger: logger, addr: addr, w: w, args: args})
+	return name == "QUIT"
+}
+
+func ping(r *request) {
+	switch len(r.args) {
+	case 1:
+		r.w.simple("PONG")
+	case 2:
+		r.w.bulk(r.args[1])
+	default:
+		r.w.error("ERR wrong number of arguments for 'ping' command")
+	}
+}
+
+func echo(r *request) {
+	r.w.bulk(r.args[1])
+}
+
+// client accepts the connection metadata client libraries send on connect
+func client(r *request) {
+	switch strings.ToUpper(r.args[1]) {
+	case "SETNAME", "SETINFO":
+		r.w.simple("OK")
+	default:
+		r.w.error("ERR unsupported CLIENT subcommand")
+	}
+}
+
+// set takes the lock with NX, or only extends it with XX, for the client
+// named by the value. A refusal replies nil, as Redis does when the key is
+// taken, so clients retry; refusals retrying can't fix are errors.
+func set(r *request) {
+	job, client := r.args[1], r.args[2]
+	var nx, xx bool
+	var ttl time.Duration
+	for i := 3; i < len(r.args); i++ {
+		switch opt := strings.ToUpper(r.args[i]); opt {
+		case "NX":
+			nx = true
+		case "XX":
+			xx = true
+		case "PX", "EX":
+			if i+1 == len(r.args) || ttl != 0 {
+				r.w.error("ERR syntax error")
+				return
+			}
+			i++
+			n, err := strconv.ParseInt(r.args[i], 10, 64)
+			if err != nil || n <= 0 {
+				r.w.error("ERR invalid expire time in 'set' command")
+				return
+			}
+			unit := time.Millisecond
+			if opt == "EX" {
+				unit = time.Second
+			}
+			ttl = time.Duration(n) * unit
+		default:
+			r.w.error("ERR syntax error")
+			return
+		}
+	}
+	if nx == xx {
+		r.w.error("ERR foolock only supports SET with either NX or XX")
+		return
+	}
+
+	var opts []lockstate.AcquireOption
+	if xx {
+		opts = append(opts, lockstate.RenewOnly())
+	}
+	result, ok := r.acquire(job, client, ttl, opts...)
+	if !ok {
+		return
+	}
+	if result.Success {
+		r.w.simple("OK")
+	} else {
+		r.w.null()
+	}
+}
+
+// get returns the holder of a lock, including during its grace period,
+// while nobody else may take it
+func get(r *request) {
+	st := r.server.manager.Status(r.args[1])
+	if !reserved(st) {
+		r.w.null()
+		return
+	}
+	r.w.bulk(st.Holder)
+}
+
+func exists(r *request) {
+	var n int64
+	for _, job := range r.args[1:] {
+		if reserved(r.server.manager.Status(job)) {
+			n++
+		}
+	}
+	r.w.integer(n)
+}
+
+// ttl answers PTTL and TTL with the time left on the lease, which is zero
+// during the grace period, or -2 if the lock isn't held
+func ttl(r *request, unit time.Duration) {
+	st := r.server.manager.Status(r.args[1])
+	if !reserved(st) {
+		r.w.integer(-2)
+		return
+	}
+	left := max(time.Until(st.ExpiresAt), 0)
+	r.w.integer(int64((left + unit/2) / unit))
+}
+
+// del refuses unconditional deletes: a release has to say who is releasing
+func del(r *request) {
+	r.w.error("ERR foolock can't release a lock without its value; use DELIFEQ or a compare-and-delete script")
+}
+
+// delIfEq releases the lock if the value names its holder, replying 1 if it
+// did and 0 otherwise
+func delIfEq(r *request) {
+	result, ok := r.release(r.args[1], r.args[2])
+	if !ok {
+		return
+	}
+	r.w.integer(boolInt(result.Success))
+}
+
+// errUnknownScript refuses a script that isn't one of knownScripts
+const errUnknownScript = "ERR foolock only runs the compare-and-delete and compare-and-extend scripts of known lock clients"
+
+func eval(r *request) {
+	_, sc := r.server.scripts.load(r.args[1])
+	if sc.kind == scriptUnknown {
+		r.w.error(errUnknownScript)
+		return
+	}
+	runScript(r, sc)
+}
+
+func evalSHA(r *request) {
+	sc, ok := r.server.scripts.get(r.args[1])
+	if !ok {
+		r.w.error("NOSCRIPT No matching script. Please use EVAL.")
+		return
+	}
+	runScript(r, sc)
+}
+
+// runScript runs a recognised script on the arguments following the script
+// or its SHA1: numkeys, KEYS[1] and the ARGV
+func runScript(r *request, sc script) {
+	if r.args[2] != "1" || len(r.args) < 4 {
+		r.w.error("ERR foolock scripts take exactly one key")
+		return
+	}
+	job, argv := r.args[3], r.args[4:]
+
+	switch sc.kind {
+	case scriptRelease:
+		if len(argv) < 1 {
+			r.w.error("ERR compare-and-delete needs the value as ARGV[1]")
+			return
+		}
+		result, ok := r.release(job, argv[0])
+		if !ok {
+			return
+		}
+		switch result.Code {
+		case lockstate.CodeReleased:
+			r.w.integer(1)
+		case lockstate.CodeNotHeld:
+			r.w.integer(sc.missing)
+		default:
+			r.w.integer(0)
+		}
+
+	case scriptExtend, scriptExtendOrAcquire:
+		if len(argv) < 2 {
+			r.w.error("ERR compare-and-extend needs the value and ttl as ARGV[1] and ARGV[2]")
+			return
+		}
+		n, err := strconv.ParseInt(argv[1], 10, 64)
+		if err != nil || n <= 0 {
+			r.w.error("ERR invalid expire time in script")
+			return
+		}
+		ttl := time.Duration(n) * time.Millisecond
+		var opts []lockstate.AcquireOption
+		if sc.kind == scriptExtend {
+			opts = append(opts, lockstate.RenewOnly())
+		}
+		result, ok := r.acquire(job, argv[0], ttl, opts...)
+		if !ok {
+			return
+		}
+		r.w.integer(boolInt(result.Success))
+	}
+}
+
+func scriptCommand(r *request) {
+	switch strings.ToUpper(r.args[1]) {
+	case "LOAD":
+		if len(r.args) != 3 {
+			r.w.error("ERR wrong number of arguments for 'script|load' command")
+			return
+		}
+		sha, sc := r.server.scripts.load(r.args[2])
+		if sc.kind == scriptUnknown {
+			r.w.error(errUnknownScript)
+			return
+		}
+		r.w.bulk(sha)
+	case "EXISTS":
+		r.w.array(len(r.args) - 2)
+		for _, sha := range r.args[2:] {
+			_, ok := r.server.scripts.get(sha)
+			r.w.integer(boolInt(ok))
+		}
+	case "FLUSH":
+		r.server.scripts.flush()
+		r.w.simple("OK")
+	default:
+		r.w.error("ERR unsupported SCRIPT subcommand")
+	}
+}
+
+// status replies with the lock's fields as alternating names and values,
+// like HGETALL, leaving out the ones that aren't set
+func status(r *request) {
+	st := r.server.manager.Status(r.args[1])
+	fields := []string{"job", st.Job}
+	add := func(name, value string) {
+		if value != "" {
+			fields = append(fields, name, value)
+		}
+	}
+	addTime := func(name string, t time.Time) {
+		if !t.IsZero() {
+			add(name, t.Format(time.RFC3339))
+		}
+	}
+	add("holder", st.Holder)
+	if st.Holder != "" {
+		addTime("acquired_at", st.AcquiredAt)
+		addTime("expires_at", st.ExpiresAt)
+		addTime("grace_until", st.GraceUntil)
+	}
+	add("expired", strconv.Itoa(int(boolInt(st.IsExpired))))
+	add("in_grace", strconv.Itoa(int(boolInt(st.InGrace))))
+	add("blocked_by", st.BlockedBy)
+	add("blocked_by_holder", st.BlockedByHolder)
+	addTime("max_hold_until", st.MaxHoldUntil)
+	addTime("cooldown_until", st.CooldownUntil)
+	addTime("last_success_at", st.LastSuccessAt)
+	add("last_success_by", st.LastSuccessBy)
+	addTime("last_failure_at", st.LastFailureAt)
+	add("last_failure_by", st.LastFailureBy)
+
+	r.w.array(len(fields))
+	for _, f := range fields {
+		r.w.bulk(f)
+	}
+}
+
+// grace replies with the milliseconds until the holder's grace period ends
+// and anyone may take the lock, or -2 if the lock isn't held
+func grace(r *request) {
+	st := r.server.manager.Status(r.args[1])
+	if !reserved(st) {
+		r.w.integer(-2)
+		return
+	}
+	r.w.integer(time.Until(st.GraceUntil).Milliseconds())
+}
+
+// reserved reports whether someone holds the lock or may still reclaim it,
+// which is when a Redis key standing for it would exist
+func reserved(st lockstate.StatusResult) bool {
+	return st.Holder != "" && (!st.IsExpired || st.InGrace)
+}
+
+// acquire takes or extends a lock, writing an error reply and returning
+// false if the request was refused, throttled or can never succeed
+func (r *request) acquire(job, client string, ttl time.Duration, opts ...lockstate.AcquireOption) (lockstate.AcquireResult, bool) {
+	if !r.allowed(client, job) {
+		return lockstate.AcquireResult{}, false
+	}
+	result := r.server.manager.Acquire(job, client, ttl, opts...)
+	locklog.Acquire(context.Background(), r.logger, client, ttl, result)
+	switch result.Code {
+	case lockstate.CodeClientNotPermitted, lockstate.CodeUnknownJob, lockstate.CodeTTLOutOfRange:
+		r.w.error(fmt.Sprintf("ERR %s: %s", result.Code, result.Message))
+		return result, false
+	}
+	return result, true
+}
+
+// release frees a lock, writing an error reply and returning false if the
+// request was refused or throttled
+func (r *request) release(job, client string) (lockstate.ReleaseResult, bool) {
+	if !r.allowed(client, job) {
+		return lockstate.ReleaseResult{}, false
+	}
+	result := r.server.manager.Release(job, client)
+	locklog.Release(context.Background(), r.logger, client, result)
+	return result, true
+}
+
+// allowed checks that client isn't one only Unix socket peers can be, and the
+// rate limiter, writing an error reply if either refuses
+func (r *request) allowed(client, job string) bool {
+	if peercred.Reserved(client) {
+		r.w.error("ERR " + msg.ReservedClient)
+		return false
+	}
+	if r.server.limiter == nil {
+		return true
+	}
+	var ip string
+	if addr, ok := r.addr.(*net.TCPAddr); ok {
+		ip = addr.IP.String()
+	}
+	ok, retry := r.server.limiter.Allow(client, ip, []string{job})
+	if !ok {
+		r.w.error(fmt.Sprintf("ERR rate limit exceeded, retry in %s", retry.Round(time.Millisecond)))
+	}
+	return ok
+}
+
+func boolInt(b bool) int64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,113 @@
+package lockstateresp
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Limits on what a client may send, so a broken or hostile one can't make
+// the server allocate without bound
+const (
+	maxArgs    = 1024
+	maxBulkLen = 512 << 10
+	maxLineLen = 64 << 10
+)
+
+// errProtocol is returned for input that isn't RESP; the connection can't be
+// resynchronised afterwards, so it is closed
+var errProtocol = errors.New("protocol error")
+
+// readCommand reads one command, either a RESP array of bulk strings as
+// client libraries send, or an inline command typed into telnet
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(line, "*") {
+		return strings.Fields(line), nil
+	}
+
+	n, err := strconv.Atoi(line[1:])
+	if err != nil || n > maxArgs {
+		return nil, fmt.Errorf("%w: invalid multibulk length", errProtocol)
+	}
+	args := make([]string, 0, max(n, 0))
+	for range n {
+		line, err := readLine(r)
+		if err != nil {
+			return nil, err
+		}
+		if !strings.HasPrefix(line, "$") {
+			return nil, fmt.Errorf("%w: expected '$', got %q", errProtocol, line)
+		}
+		size, err := strconv.Atoi(line[1:])
+		if err != nil || size < 0 || size > maxBulkLen {
+			return nil, fmt.Errorf("%w: invalid bulk length", errProtocol)
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		if string(buf[size:]) != "\r\n" {
+			return nil, fmt.Errorf("%w: bulk string not terminated", errProtocol)
+		}
+		args = append(args, string(buf[:size]))
+	}
+	return args, nil
+}
+
+// readLine reads up to CRLF, also accepting a bare LF from inline commands
+func readLine(r *bufio.Reader) (string, error) {
+	var line []byte
+	for {
+		chunk, isPrefix, err := r.ReadLine()
+		if err != nil {
+			return "", err
+		}
+		line = append(line, chunk...)
+		if len(line) > maxLineLen {
+			return "", fmt.Errorf("%w: line too long", errProtocol)
+		}
+		if !isPrefix {
+			return string(line), nil
+		}
+	}
+}
+
+// writer buffers replies; the connection flushes it once it has answered
+// every command the client pipelined
+type writer struct {
+	*bufio.Writer
+}
+
+func (w writer) simple(s string) {
+	w.WriteString("+" + s + "\r\n")
+}
+
+// error writes an error reply; msg starts with a code such as ERR
+func (w writer) error(msg string) {
+	w.WriteString("-" + msg + "\r\n")
+}
+
+func (w writer) integer(n int64) {
+	w.WriteString(":" + strconv.FormatInt(n, 10) + "\r\n")
+}
+
+func (w writer) bulk(s string) {
+	w.WriteString("$" + strconv.Itoa(len(s)) + "\r\n" + s + "\r\n")
+}
+
+// null writes the RESP2 nil bulk string, which is how SET NX reports that it
+// didn't set the key
+func (w writer) null() {
+	w.WriteString("$-1\r\n")
+}
+
+func (w writer) array(n int) {
+	w.WriteString("*" + strconv.Itoa(n) + "\r\n")
+}
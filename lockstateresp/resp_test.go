@@ -0,0 +1,54 @@
+package lockstateresp
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadCommand(t *testing.T) {
+	r := bufio.NewReader(strings.NewReader(
+		"*3\r\n$3\r\nSET\r\n$6\r\nbackup\r\n$7\r\nc1\r\nc2 \r\n" +
+			"PING  hello\r\n" +
+			"GET backup\n",
+	))
+
+	args, err := readCommand(r)
+	require.NoError(t, err)
+	require.Equal(t, []string{"SET", "backup", "c1\r\nc2 "}, args, "bulk strings are binary safe")
+
+	args, err = readCommand(r)
+	require.NoError(t, err)
+	require.Equal(t, []string{"PING", "hello"}, args)
+
+	args, err = readCommand(r)
+	require.NoError(t, err)
+	require.Equal(t, []string{"GET", "backup"}, args, "inline commands may end with a bare LF")
+
+	_, err = readCommand(r)
+	require.ErrorIs(t, err, io.EOF)
+}
+
+func TestReadCommandErrors(t *testing.T) {
+	for name, input := range map[string]string{
+		"bad length":     "*x\r\n",
+		"too many args":  "*100000\r\n",
+		"not bulk":       "*1\r\n:1\r\n",
+		"bulk too long":  "*1\r\n$999999999\r\n",
+		"unterminated":   "*1\r\n$3\r\nGETxx",
+		"line too long":  strings.Repeat("a", maxLineLen+1) + "\r\n",
+		"negative bulk":  "*1\r\n$-1\r\n",
+		"missing length": "*1\r\n$\r\n",
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := readCommand(bufio.NewReader(strings.NewReader(input)))
+			require.ErrorIs(t, err, errProtocol)
+		})
+	}
+
+	_, err := readCommand(bufio.NewReader(strings.NewReader("*2\r\n$3\r\nGET\r\n")))
+	require.ErrorIs(t, err, io.EOF, "a truncated command isn't a protocol error")
+}
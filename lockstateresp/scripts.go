@@ -0,0 +1,143 @@
+package lockstateresp
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"strings"
+	"sync"
+)
+
+// scriptKind is what a Lua script sent with EVAL does, as far as foolock is
+// concerned. There's no Lua interpreter: the scripts of known Redis lock
+// clients are recognised by their source and run natively.
+type scriptKind int
+
+const (
+	scriptUnknown scriptKind = iota
+	// Compare-and-delete: DEL KEYS[1] if GET KEYS[1] == ARGV[1]
+	scriptRelease
+	// Compare-and-expire: PEXPIRE KEYS[1] ARGV[2] if GET KEYS[1] == ARGV[1]
+	scriptExtend
+	// scriptExtend that falls back to SET KEYS[1] ARGV[1] NX when the key is
+	// gone
+	scriptExtendOrAcquire
+)
+
+// script is a recognised script
+type script struct {
+	kind scriptKind
+	// missing is returned by a release script when nobody holds the lock;
+	// some clients return -1 there to tell an expired lock from a lost one
+	missing int64
+}
+
+// knownScripts are the scripts foolock runs, keyed by their source as
+// normalized by normalizeScript. Matching whole scripts rather than the
+// commands they call means a script that does anything more is refused
+// rather than half run.
+var knownScripts = map[string]script{
+	// The Redis documentation's release script, also used by bsm/redislock
+	normalizeScript(`if redis.call("get",KEYS[1]) == ARGV[1] then
+		return redis.call("del",KEYS[1])
+	else
+		return 0
+	end`): {kind: scriptRelease},
+	normalizeScript(`if redis.call("get", KEYS[1]) == ARGV[1] then
+		return redis.call("del", KEYS[1])
+	else
+		return 0
+	end`): {kind: scriptRelease},
+	// bsm/redislock's refresh
+	normalizeScript(`if redis.call("get", KEYS[1]) == ARGV[1] then
+		return redis.call("pexpire", KEYS[1], ARGV[2])
+	else
+		return 0
+	end`): {kind: scriptExtend},
+
+	// go-redsync/redsync, whose release tells an expired lock from one taken
+	// by someone else
+	normalizeScript(`local val = redis.call("GET", KEYS[1])
+	if val == ARGV[1] then
+		return redis.call("DEL", KEYS[1])
+	elseif val == false then
+		return -1
+	else
+		return 0
+	end`): {kind: scriptRelease, missing: -1},
+	normalizeScript(`if redis.call("GET", KEYS[1]) == ARGV[1] then
+		return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+	else
+		return 0
+	end`): {kind: scriptExtend},
+	normalizeScript(`if redis.call("GET", KEYS[1]) == ARGV[1] then
+		return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+	elseif redis.call("SET", KEYS[1], ARGV[1], "PX", ARGV[2], "NX") then
+		return 1
+	else
+		return 0
+	end`): {kind: scriptExtendOrAcquire},
+
+	// redis-py's Lock release and reacquire
+	normalizeScript(`local token = redis.call('get', KEYS[1])
+	if not token or token ~= ARGV[1] then
+		return 0
+	end
+	redis.call('del', KEYS[1])
+	return 1`): {kind: scriptRelease},
+	normalizeScript(`local token = redis.call('get', KEYS[1])
+	if not token or token ~= ARGV[1] then
+		return 0
+	end
+	redis.call('pexpire', KEYS[1], ARGV[2])
+	return 1`): {kind: scriptExtend},
+}
+
+// normalizeScript collapses runs of whitespace, so indentation and line
+// endings don't matter
+func normalizeScript(src string) string {
+	return strings.Join(strings.Fields(src), " ")
+}
+
+// parseScript works out what a script does by looking it up among the
+// known ones
+func parseScript(src string) script {
+	return knownScripts[normalizeScript(src)]
+}
+
+// scripts remembers recognised scripts by SHA1, for EVALSHA
+type scripts struct {
+	mu    sync.Mutex
+	bySHA map[string]script
+}
+
+// load recognises src and remembers it, returning its SHA1; unknown scripts
+// aren't remembered
+func (s *scripts) load(src string) (string, script) {
+	sum := sha1.Sum([]byte(src))
+	sha := hex.EncodeToString(sum[:])
+	sc := parseScript(src)
+	if sc.kind == scriptUnknown {
+		return sha, sc
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.bySHA == nil {
+		s.bySHA = make(map[string]script)
+	}
+	s.bySHA[sha] = sc
+	return sha, sc
+}
+
+func (s *scripts) get(sha string) (script, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sc, ok := s.bySHA[strings.ToLower(sha)]
+	return sc, ok
+}
+
+func (s *scripts) flush() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.bySHA = nil
+}
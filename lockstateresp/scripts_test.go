@@ -0,0 +1,107 @@
+package lockstateresp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Scripts as sent by Redis lock clients
+const (
+	// The release script from the Redis documentation
+	releaseScript = `if redis.call("get",KEYS[1]) == ARGV[1] then
+    return redis.call("del",KEYS[1])
+else
+    return 0
+end`
+
+	// redsync's, telling an expired lock from one taken by someone else
+	redsyncRelease = `
+	local val = redis.call("GET", KEYS[1])
+	if val == ARGV[1] then
+		return redis.call("DEL", KEYS[1])
+	elseif val == false then
+		return -1
+	else
+		return 0
+	end
+`
+	redsyncTouch = `
+	if redis.call("GET", KEYS[1]) == ARGV[1] then
+		return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+	else
+		return 0
+	end
+`
+	redsyncTouchWithSetNX = `
+	if redis.call("GET", KEYS[1]) == ARGV[1] then
+		return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+	elseif redis.call("SET", KEYS[1], ARGV[1], "PX", ARGV[2], "NX") then
+		return 1
+	else
+		return 0
+	end
+`
+	redisPyRelease = `
+        local token = redis.call('get', KEYS[1])
+        if not token or token ~= ARGV[1] then
+            return 0
+        end
+        redis.call('del', KEYS[1])
+        return 1
+    `
+)
+
+func TestParseScript(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want script
+	}{
+		{"release", releaseScript, script{kind: scriptRelease}},
+		{"release with -1", redsyncRelease, script{kind: scriptRelease, missing: -1}},
+		{"extend", redsyncTouch, script{kind: scriptExtend}},
+		{"extend or acquire", redsyncTouchWithSetNX, script{kind: scriptExtendOrAcquire}},
+		{"reindented", "if redis.call(\"get\",KEYS[1]) == ARGV[1] then\r\n\treturn redis.call(\"del\",KEYS[1])\r\nelse return 0 end\r\n", script{kind: scriptRelease}},
+		{"redis-py release", redisPyRelease, script{kind: scriptRelease}},
+		{"unconditional delete", `return redis.call("del", KEYS[1])`, script{}},
+		{"release plus something else", `if redis.call("get",KEYS[1]) == ARGV[1] then redis.call("incr", "released") return redis.call("del",KEYS[1]) end`, script{}},
+		{"inverted check", `if redis.call("get",KEYS[1]) ~= ARGV[1] then
+    return redis.call("del",KEYS[1])
+else
+    return 0
+end`, script{}},
+		{"extend by a different argument", `if redis.call("GET", KEYS[1]) == ARGV[1] then
+		return redis.call("PEXPIRE", KEYS[1], ARGV[3])
+	else
+		return 0
+	end`, script{}},
+		{"-1 in a comment", `-- return -1
+if redis.call("get",KEYS[1]) == ARGV[1] then return redis.call("del",KEYS[1]) else return 0 end`, script{}},
+		{"no redis calls", `return 1`, script{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, parseScript(tt.src))
+		})
+	}
+}
+
+func TestScripts(t *testing.T) {
+	var s scripts
+	sha, sc := s.load(releaseScript)
+	require.Equal(t, scriptRelease, sc.kind)
+	require.Len(t, sha, 40)
+
+	got, ok := s.get(sha)
+	require.True(t, ok)
+	require.Equal(t, sc, got)
+
+	unknown, _ := s.load(`return 1`)
+	_, ok = s.get(unknown)
+	require.False(t, ok, "unknown scripts aren't remembered")
+
+	s.flush()
+	_, ok = s.get(sha)
+	require.False(t, ok)
+}
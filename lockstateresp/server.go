@@ -0,0 +1,203 @@
+// Package lockstateresp serves the lock manager over the Redis protocol, so
+// Redis lock clients can use foolock unchanged. It understands the commands
+// those clients send to take a lock (SET key value NX PX ttl), release it
+// (compare-and-delete) and extend it (compare-and-expire), treating the key
+// as the job and the value as the client. Anything else a Redis server would
+// do is refused.
+package lockstateresp
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/shadyabhi/foolock/lockstate"
+	"github.com/shadyabhi/foolock/ratelimit"
+)
+
+// ErrServerClosed is returned by Serve after Shutdown
+var ErrServerClosed = errors.New("lockstateresp: server closed")
+
+// Server answers Redis protocol connections
+type Server struct {
+	manager *lockstate.Manager
+	logger  *slog.Logger
+	limiter *ratelimit.Limiter
+	scripts scripts
+
+	mu       sync.Mutex
+	closing  bool
+	listener net.Listener
+	conns    map[*conn]struct{}
+	done     chan struct{}
+}
+
+// Option configures a Server
+type Option func(*Server)
+
+// WithLogger sets where lock operations are logged; the default is
+// slog.Default()
+func WithLogger(logger *slog.Logger) Option {
+	return func(s *Server) {
+		s.logger = logger
+	}
+}
+
+// WithRateLimiter throttles acquisitions, extensions and releases before they
+// reach the lock manager
+func WithRateLimiter(l *ratelimit.Limiter) Option {
+	return func(s *Server) {
+		s.limiter = l
+	}
+}
+
+func New(manager *lockstate.Manager, opts ...Option) *Server {
+	s := &Server{
+		manager: manager,
+		logger:  slog.Default(),
+		conns:   make(map[*conn]struct{}),
+		done:    make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Serve accepts connections on ln until Shutdown is called, then returns
+// ErrServerClosed
+func (s *Server) Serve(ln net.Listener) error {
+	s.mu.Lock()
+	if s.closing {
+		s.mu.Unlock()
+		return ErrServerClosed
+	}
+	s.listener = ln
+	s.mu.Unlock()
+
+	for {
+		nc, err := ln.Accept()
+		if err != nil {
+			s.mu.Lock()
+			closing := s.closing
+			s.mu.Unlock()
+			if closing {
+				return ErrServerClosed
+			}
+			return err
+		}
+		c := &conn{server: s, nc: nc}
+		if !s.track(c) {
+			nc.Close()
+			return ErrServerClosed
+		}
+		go c.serve()
+	}
+}
+
+// Shutdown stops accepting connections and closes each open one once it has
+// answered the commands it was sent, so clients reconnect elsewhere. If ctx
+// ends first, the remaining connections are closed straight away.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	if !s.closing {
+		s.closing = true
+		if s.listener != nil {
+			s.listener.Close()
+		}
+		// Wake connections waiting for a command; busy ones notice on their
+		// own once they finish
+		for c := range s.conns {
+			c.nc.SetReadDeadline(time.Now())
+		}
+		if len(s.conns) == 0 {
+			close(s.done)
+		}
+	}
+	s.mu.Unlock()
+
+	select {
+	case <-s.done:
+		return nil
+	case <-ctx.Done():
+		s.mu.Lock()
+		for c := range s.conns {
+			c.nc.Close()
+		}
+		s.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+func (s *Server) track(c *conn) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closing {
+		return false
+	}
+	s.conns[c] = struct{}{}
+	return true
+}
+
+func (s *Server) untrack(c *conn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.conns, c)
+	if s.closing && len(s.conns) == 0 {
+		close(s.done)
+	}
+}
+
+func (s *Server) shuttingDown() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closing
+}
+
+// conn is one client connection
+type conn struct {
+	server *Server
+	nc     net.Conn
+}
+
+func (c *conn) serve() {
+	defer c.server.untrack(c)
+	defer c.nc.Close()
+
+	logger := c.server.logger.With("remote_addr", c.nc.RemoteAddr().String())
+	r := bufio.NewReader(c.nc)
+	w := writer{bufio.NewWriter(c.nc)}
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			if errors.Is(err, errProtocol) {
+				w.error("ERR " + err.Error())
+				w.Flush()
+			} else if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) && !c.server.shuttingDown() {
+				logger.Debug("RESP connection failed", "err", err)
+			}
+			return
+		}
+		if len(args) == 0 {
+			continue
+		}
+
+		quit := c.server.dispatch(logger, c.nc.RemoteAddr(), w, args)
+		// Answer everything the client pipelined before writing, and before
+		// closing on shutdown
+		closing := quit || (r.Buffered() == 0 && c.server.shuttingDown())
+		if r.Buffered() == 0 || closing {
+			if err := w.Flush(); err != nil {
+				return
+			}
+		}
+		if closing {
+			return
+		}
+	}
+}
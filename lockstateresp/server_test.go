@@ -0,0 +1,296 @@
+package lockstateresp
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/require"
+
+	"github.com/shadyabhi/foolock/lockstate"
+	"github.com/shadyabhi/foolock/lockstate/msg"
+	"github.com/shadyabhi/foolock/ratelimit"
+)
+
+// serve runs srv on a local port and returns its address
+func serve(t *testing.T, srv *Server) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	served := make(chan error, 1)
+	go func() { served <- srv.Serve(ln) }()
+	t.Cleanup(func() {
+		srv.Shutdown(context.Background())
+		require.ErrorIs(t, <-served, ErrServerClosed)
+	})
+	return ln.Addr().String()
+}
+
+// newClient connects a go-redis client, which starts by trying RESP3 and
+// sending its library name, as it would against a real Redis
+func newClient(t *testing.T, addr string) *redis.Client {
+	t.Helper()
+	rdb := redis.NewClient(&redis.Options{Addr: addr, MaxRetries: -1})
+	t.Cleanup(func() { rdb.Close() })
+	return rdb
+}
+
+func TestLockLifecycle(t *testing.T) {
+	ctx := t.Context()
+	m := lockstate.New(lockstate.WithGracePeriod(0))
+	rdb := newClient(t, serve(t, New(m)))
+
+	require.NoError(t, rdb.Ping(ctx).Err())
+
+	ok, err := rdb.SetNX(ctx, "backup", "c1", time.Minute).Result()
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, "c1", m.Status("backup").Holder)
+
+	ok, err = rdb.SetNX(ctx, "backup", "c2", time.Minute).Result()
+	require.NoError(t, err)
+	require.False(t, ok, "held by another client")
+
+	holder, err := rdb.Get(ctx, "backup").Result()
+	require.NoError(t, err)
+	require.Equal(t, "c1", holder)
+	require.Equal(t, int64(1), rdb.Exists(ctx, "backup", "other").Val())
+
+	pttl, err := rdb.PTTL(ctx, "backup").Result()
+	require.NoError(t, err)
+	require.InDelta(t, time.Minute, pttl, float64(time.Second))
+
+	ok, err = rdb.SetXX(ctx, "backup", "c1", 2*time.Minute).Result()
+	require.NoError(t, err)
+	require.True(t, ok, "the holder extends")
+	require.InDelta(t, 2*time.Minute, rdb.PTTL(ctx, "backup").Val(), float64(time.Second))
+	ok, err = rdb.SetXX(ctx, "backup", "c2", time.Minute).Result()
+	require.NoError(t, err)
+	require.False(t, ok, "only the holder extends")
+
+	require.ErrorContains(t, rdb.Del(ctx, "backup").Err(), "without its value")
+	require.Equal(t, int64(0), rdb.Do(ctx, "DELIFEQ", "backup", "c2").Val())
+
+	// Run tries EVALSHA first and falls back to EVAL on NOSCRIPT
+	released, err := redis.NewScript(releaseScript).Run(ctx, rdb, []string{"backup"}, "c1").Int()
+	require.NoError(t, err)
+	require.Equal(t, 1, released)
+
+	_, err = rdb.Get(ctx, "backup").Result()
+	require.ErrorIs(t, err, redis.Nil)
+	require.Equal(t, time.Duration(-2), rdb.PTTL(ctx, "backup").Val())
+	require.Equal(t, int64(0), rdb.Do(ctx, "DELIFEQ", "backup", "c1").Val())
+}
+
+func TestDelIfEq(t *testing.T) {
+	ctx := t.Context()
+	m := lockstate.New()
+	rdb := newClient(t, serve(t, New(m)))
+
+	require.True(t, rdb.SetNX(ctx, "backup", "c1", time.Minute).Val())
+	require.Equal(t, int64(1), rdb.Do(ctx, "DELIFEQ", "backup", "c1").Val())
+	require.Empty(t, m.Status("backup").Holder)
+}
+
+func TestGracePeriod(t *testing.T) {
+	ctx := t.Context()
+	m := lockstate.New(lockstate.WithGracePeriod(time.Minute))
+	rdb := newClient(t, serve(t, New(m)))
+
+	require.True(t, rdb.SetNX(ctx, "backup", "c1", 20*time.Millisecond).Val())
+	time.Sleep(50 * time.Millisecond)
+
+	ok, err := rdb.SetNX(ctx, "backup", "c2", time.Minute).Result()
+	require.NoError(t, err)
+	require.False(t, ok, "the lease expired but the holder may still reclaim it")
+	require.Equal(t, "c1", rdb.Get(ctx, "backup").Val())
+	require.Zero(t, rdb.PTTL(ctx, "backup").Val())
+
+	grace, err := rdb.Do(ctx, "FOOLOCK.GRACE", "backup").Int64()
+	require.NoError(t, err)
+	require.InDelta(t, time.Minute.Milliseconds(), grace, 1000)
+
+	extended, err := redis.NewScript(redsyncTouch).Run(ctx, rdb, []string{"backup"}, "c1", 60000).Int()
+	require.NoError(t, err)
+	require.Equal(t, 1, extended, "the holder reclaims during grace")
+	require.InDelta(t, time.Minute, rdb.PTTL(ctx, "backup").Val(), float64(time.Second))
+
+	extended, err = redis.NewScript(redsyncTouch).Run(ctx, rdb, []string{"other"}, "c1", 60000).Int()
+	require.NoError(t, err)
+	require.Zero(t, extended, "extending doesn't acquire")
+	extended, err = redis.NewScript(redsyncTouchWithSetNX).Run(ctx, rdb, []string{"other"}, "c1", 60000).Int()
+	require.NoError(t, err)
+	require.Equal(t, 1, extended, "unless the script falls back to SET NX")
+
+	release := redis.NewScript(redsyncRelease)
+	released, err := release.Run(ctx, rdb, []string{"backup"}, "c2").Int()
+	require.NoError(t, err)
+	require.Zero(t, released, "held by someone else")
+	released, err = release.Run(ctx, rdb, []string{"backup"}, "c1").Int()
+	require.NoError(t, err)
+	require.Equal(t, 1, released)
+	released, err = release.Run(ctx, rdb, []string{"backup"}, "c1").Int()
+	require.NoError(t, err)
+	require.Equal(t, -1, released, "nobody holds it")
+
+	require.Equal(t, int64(-2), rdb.Do(ctx, "FOOLOCK.GRACE", "backup").Val())
+}
+
+func TestStatus(t *testing.T) {
+	ctx := t.Context()
+	m := lockstate.New()
+	rdb := newClient(t, serve(t, New(m)))
+
+	fields, err := rdb.Do(ctx, "FOOLOCK.STATUS", "backup").StringSlice()
+	require.NoError(t, err)
+	require.Equal(t, []string{"job", "backup", "expired", "1", "in_grace", "0"}, fields)
+
+	require.True(t, rdb.SetNX(ctx, "backup", "c1", time.Minute).Val())
+	fields, err = rdb.Do(ctx, "FOOLOCK.STATUS", "backup").StringSlice()
+	require.NoError(t, err)
+	status := map[string]string{}
+	for i := 0; i < len(fields); i += 2 {
+		status[fields[i]] = fields[i+1]
+	}
+	require.Equal(t, "c1", status["holder"])
+	require.Equal(t, "0", status["expired"])
+	expiresAt, err := time.Parse(time.RFC3339, status["expires_at"])
+	require.NoError(t, err)
+	require.WithinDuration(t, time.Now().Add(time.Minute), expiresAt, 2*time.Second)
+	require.Contains(t, status, "acquired_at")
+	require.Contains(t, status, "grace_until")
+}
+
+func TestRefusedForGood(t *testing.T) {
+	ctx := t.Context()
+	m := lockstate.New(
+		lockstate.WithPolicies(map[string]lockstate.Policy{"backup": {MaxTTL: time.Minute}}),
+		lockstate.WithStrictPolicies(true),
+	)
+	rdb := newClient(t, serve(t, New(m)))
+
+	require.ErrorContains(t, rdb.SetNX(ctx, "other", "c1", time.Minute).Err(), "unknown_job")
+	require.ErrorContains(t, rdb.SetNX(ctx, "backup", "c1", time.Hour).Err(), "ttl_out_of_range")
+	require.True(t, rdb.SetNX(ctx, "backup", "c1", time.Minute).Val())
+}
+
+func TestErrors(t *testing.T) {
+	ctx := t.Context()
+	rdb := newClient(t, serve(t, New(lockstate.New())))
+
+	tests := []struct {
+		args []any
+		want string
+	}{
+		{[]any{"FLUSHALL"}, "ERR unknown command 'FLUSHALL'"},
+		{[]any{"GET"}, "ERR wrong number of arguments for 'get' command"},
+		{[]any{"SET", "backup", "c1"}, "ERR foolock only supports SET with either NX or XX"},
+		{[]any{"SET", "backup", "c1", "NX", "XX"}, "ERR foolock only supports SET with either NX or XX"},
+		{[]any{"SET", "backup", "c1", "NX", "PX", "0"}, "ERR invalid expire time in 'set' command"},
+		{[]any{"SET", "backup", "c1", "NX", "PX"}, "ERR syntax error"},
+		{[]any{"SET", "backup", "c1", "NX", "KEEPTTL"}, "ERR syntax error"},
+		{[]any{"EVAL", "return 1", "0"}, errUnknownScript},
+		{[]any{"EVAL", releaseScript, "0"}, "ERR foolock scripts take exactly one key"},
+		{[]any{"EVALSHA", "0123456789012345678901234567890123456789", "1", "backup", "c1"}, "NOSCRIPT No matching script. Please use EVAL."},
+		{[]any{"SCRIPT", "LOAD", "return 1"}, errUnknownScript},
+		{[]any{"SET", "backup", "uid:1000/c1", "NX", "PX", "1000"}, "ERR " + msg.ReservedClient},
+		{[]any{"DELIFEQ", "backup", "uid:1000/c1"}, "ERR " + msg.ReservedClient},
+	}
+	for _, tt := range tests {
+		require.EqualError(t, rdb.Do(ctx, tt.args...).Err(), tt.want, tt.args)
+	}
+}
+
+func TestScriptLoad(t *testing.T) {
+	ctx := t.Context()
+	rdb := newClient(t, serve(t, New(lockstate.New())))
+
+	sha, err := rdb.ScriptLoad(ctx, releaseScript).Result()
+	require.NoError(t, err)
+	exists, err := rdb.ScriptExists(ctx, sha, "0123456789012345678901234567890123456789").Result()
+	require.NoError(t, err)
+	require.Equal(t, []bool{true, false}, exists)
+
+	require.True(t, rdb.SetNX(ctx, "backup", "c1", time.Minute).Val())
+	released, err := rdb.EvalSha(ctx, sha, []string{"backup"}, "c1").Int()
+	require.NoError(t, err)
+	require.Equal(t, 1, released)
+
+	require.NoError(t, rdb.ScriptFlush(ctx).Err())
+	require.Equal(t, []bool{false}, rdb.ScriptExists(ctx, sha).Val())
+}
+
+func TestRateLimit(t *testing.T) {
+	ctx := t.Context()
+	m := lockstate.New()
+	limiter := ratelimit.New(ratelimit.Config{Client: ratelimit.Limit{Rate: 0.1, Burst: 1}})
+	rdb := newClient(t, serve(t, New(m, WithRateLimiter(limiter))))
+
+	require.True(t, rdb.SetNX(ctx, "backup", "c1", time.Minute).Val())
+	require.ErrorContains(t, rdb.Do(ctx, "DELIFEQ", "backup", "c1").Err(), "rate limit exceeded")
+	require.Equal(t, "c1", m.Status("backup").Holder, "throttled requests never reach the manager")
+	require.Equal(t, "c1", rdb.Get(ctx, "backup").Val(), "reads aren't limited")
+}
+
+func TestInlineAndPipelined(t *testing.T) {
+	nc, err := net.Dial("tcp", serve(t, New(lockstate.New())))
+	require.NoError(t, err)
+	defer nc.Close()
+	r := bufio.NewReader(nc)
+
+	_, err = nc.Write([]byte("SET backup c1 NX PX 60000\r\nGET backup\r\nPING\r\nQUIT\r\n"))
+	require.NoError(t, err)
+	for _, want := range []string{"+OK", "$2", "c1", "+PONG", "+OK"} {
+		line, err := readLine(r)
+		require.NoError(t, err)
+		require.Equal(t, want, line)
+	}
+	_, err = r.ReadByte()
+	require.Error(t, err, "QUIT closes the connection")
+}
+
+func TestProtocolErrorClosesConnection(t *testing.T) {
+	nc, err := net.Dial("tcp", serve(t, New(lockstate.New())))
+	require.NoError(t, err)
+	defer nc.Close()
+	r := bufio.NewReader(nc)
+
+	_, err = nc.Write([]byte("*1\r\n:1\r\n"))
+	require.NoError(t, err)
+	line, err := readLine(r)
+	require.NoError(t, err)
+	require.Contains(t, line, "-ERR protocol error")
+	_, err = r.ReadByte()
+	require.Error(t, err)
+}
+
+func TestShutdown(t *testing.T) {
+	srv := New(lockstate.New())
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	served := make(chan error, 1)
+	go func() { served <- srv.Serve(ln) }()
+
+	idle, err := net.Dial("tcp", ln.Addr().String())
+	require.NoError(t, err)
+	defer idle.Close()
+	r := bufio.NewReader(idle)
+	_, err = idle.Write([]byte("PING\r\n"))
+	require.NoError(t, err)
+	line, err := readLine(r)
+	require.NoError(t, err)
+	require.Equal(t, "+PONG", line)
+
+	ctx, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, srv.Shutdown(ctx), "idle connections are closed straight away")
+	require.ErrorIs(t, <-served, ErrServerClosed)
+
+	_, err = r.ReadByte()
+	require.Error(t, err)
+	_, err = net.Dial("tcp", ln.Addr().String())
+	require.Error(t, err, "no longer listening")
+}
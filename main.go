@@ -1,23 +1,380 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
 	"log"
+	"log/slog"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 
+	"github.com/shadyabhi/foolock/alert"
+	"github.com/shadyabhi/foolock/audit"
+	"github.com/shadyabhi/foolock/config"
 	"github.com/shadyabhi/foolock/lockstate"
+	"github.com/shadyabhi/foolock/lockstategrpc"
 	"github.com/shadyabhi/foolock/lockstatehttp"
+	"github.com/shadyabhi/foolock/lockstateresp"
+	"github.com/shadyabhi/foolock/peercred"
+	"github.com/shadyabhi/foolock/policy"
+	"github.com/shadyabhi/foolock/ratelimit"
 )
 
-const ServerAddr = ":8080"
+// sweepInterval is how often lapsed leases are looked for, so their expiry is
+// logged and audited even if nobody asks about the job again
+const sweepInterval = time.Second
+
+// drainPollInterval is how often shutdown checks whether the last lock has
+// been released, so it can stop before the end of the drain period
+const drainPollInterval = 100 * time.Millisecond
+
+// Set by release builds with -ldflags "-X main.version=... -X main.commit=...
+// -X main.date=..."
+var (
+	version string
+	commit  string
+	date    string
+)
 
 func main() {
-	manager := lockstate.New()
-	handler := lockstatehttp.New(manager)
+	cfg, flags, err := config.Load(os.Args[1:], os.Getenv)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	if flags.PrintConfig {
+		out, err := cfg.YAML()
+		if err != nil {
+			log.Fatalf("Failed to render configuration: %v", err)
+		}
+		os.Stdout.Write(out)
+		return
+	}
+
+	logOutput := io.Writer(os.Stderr)
+	if cfg.LogFile != "" {
+		logFile, err := os.OpenFile(cfg.LogFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+		if err != nil {
+			log.Fatalf("Failed to open log file: %v", err)
+		}
+		// Left open if run fails, so its error is logged
+		defer logFile.Close()
+		logOutput = logFile
+	}
+	// Also routes the standard log package, used by net/http, through slog
+	slog.SetDefault(cfg.Logger(logOutput))
+
+	if err := run(cfg); err != nil {
+		slog.Error("Exiting", "err", err)
+		os.Exit(1)
+	}
+}
+
+// run serves until shut down or upgraded. It returns rather than exiting on
+// failure, so the manager and audit log are closed and no event is lost.
+func run(cfg config.Config) error {
+	opts := cfg.ManagerOptions()
+	var peerPolicy peercred.Policy
+	if cfg.Policy != "" {
+		policies, err := policy.Load(cfg.Policy)
+		if err != nil {
+			return fmt.Errorf("loading policy file: %w", err)
+		}
+		opts = append(opts, policies.Options()...)
+		peerPolicy = policies.PeerPolicy()
+	}
+	if cfg.AuditLog != "" {
+		auditLog, err := audit.Open(cfg.AuditLog)
+		if err != nil {
+			return fmt.Errorf("opening audit log: %w", err)
+		}
+		defer auditLog.Close()
+		opts = append(opts, lockstate.WithEventSink(auditLog.Write))
+	}
+
+	manager := lockstate.New(opts...)
+	// Runs before the audit log is closed, so it gets every event
+	defer manager.Close()
+	sweepCtx, stopSweep := context.WithCancel(context.Background())
+	defer stopSweep()
+	go manager.Sweep(sweepCtx, sweepInterval)
+	handlerOpts := []lockstatehttp.Option{
+		lockstatehttp.WithBuildInfo(lockstatehttp.ReadBuildInfo(version, commit, date)),
+		lockstatehttp.WithPeerPolicy(peerPolicy),
+	}
+	if cfg.StateFile != "" {
+		handlerOpts = append(handlerOpts, lockstatehttp.WithReadinessCheck("state_file", func() error {
+			return checkStateDir(cfg.StateFile)
+		}))
+	}
+	var grpcOpts []lockstategrpc.Option
+	var respOpts []lockstateresp.Option
+	if rl := cfg.RateLimit(); rl.Enabled() {
+		// Shared, so a client can't multiply its allowance by using several APIs
+		limiter := ratelimit.New(rl)
+		handlerOpts = append(handlerOpts, lockstatehttp.WithRateLimiter(limiter))
+		grpcOpts = append(grpcOpts, lockstategrpc.WithRateLimiter(limiter))
+		respOpts = append(respOpts, lockstateresp.WithRateLimiter(limiter))
+	}
+	handler := lockstatehttp.New(manager, handlerOpts...)
 
+	handler.RegisterV1(http.DefaultServeMux)
+
+	// Query-string API kept for existing clients
 	http.HandleFunc("/lock", handler.HandleLock)
+	http.HandleFunc("/lock/history", handler.HandleHistory)
+	http.HandleFunc("/locks", handler.HandleLocks)
+	http.HandleFunc("/runs", handler.HandleRuns)
+	http.HandleFunc("/openapi.json", lockstatehttp.HandleOpenAPI)
+	http.HandleFunc("/throttled", handler.HandleThrottled)
+
+	// Probes for orchestrators; none of them touch lock state
+	http.HandleFunc("/healthz", lockstatehttp.HandleHealthz)
+	http.HandleFunc("/readyz", handler.HandleReadyz)
+	http.HandleFunc("/version", handler.HandleVersion)
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+
+	if cfg.Alerts != "" {
+		alerts, err := alert.Load(cfg.Alerts)
+		if err != nil {
+			return fmt.Errorf("loading alert config: %w", err)
+		}
+		engine := alert.NewEngine(manager, alerts.Rules, alerts.BuildSinks()...)
+		go engine.Run(ctx, alerts.Interval)
+		http.HandleFunc("/alerts", engine.HandleAlerts)
+	}
+
+	ln, parent, err := listen(cfg.Addr)
+	if err != nil {
+		return fmt.Errorf("listening on %s: %w", cfg.Addr, err)
+	}
+	grpcLn, err := listenExtra("grpc", "tcp", cfg.GRPCAddr, parent)
+	if err != nil {
+		return fmt.Errorf("listening on %s: %w", cfg.GRPCAddr, err)
+	}
+	respLn, err := listenExtra("resp", "tcp", cfg.RESPAddr, parent)
+	if err != nil {
+		return fmt.Errorf("listening on %s: %w", cfg.RESPAddr, err)
+	}
+	unixLn, err := listenExtra("unix", "unix", cfg.UnixSocket, parent)
+	if err != nil {
+		return fmt.Errorf("listening on %s: %w", cfg.UnixSocket, err)
+	}
+
+	upgrades := make(chan os.Signal, 1)
+	if len(upgradeSignals) > 0 {
+		signal.Notify(upgrades, upgradeSignals...)
+	}
+
+	var accessLog io.Writer
+	switch cfg.AccessLog {
+	case "":
+	case "-":
+		accessLog = os.Stdout
+	default:
+		f, err := os.OpenFile(cfg.AccessLog, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+		if err != nil {
+			return fmt.Errorf("opening access log: %w", err)
+		}
+		defer f.Close()
+		accessLog = f
+	}
 
-	log.Printf("Starting lock service on %s", ServerAddr)
-	if err := http.ListenAndServe(ServerAddr, nil); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+	// Last of the setup that can fail: once the parent has been told this
+	// process is ready it stops serving and hands over its locks, and exits
+	// without saving them
+	switch {
+	case parent != nil:
+		n, err := parent.takeOver(manager)
+		if err != nil {
+			return fmt.Errorf("taking over from pid %d: %w", parent.pid, err)
+		}
+		slog.Info("Took over locks", "locks", n, "pid", parent.pid)
+	case cfg.StateFile != "":
+		n, err := loadState(cfg.StateFile, manager)
+		if err != nil {
+			return fmt.Errorf("loading state file %s: %w", cfg.StateFile, err)
+		}
+		slog.Info("Restored locks", "locks", n, "path", cfg.StateFile)
+	}
+
+	var busy busyConns
+	srv := servers{http: &http.Server{
+		Addr:      cfg.Addr,
+		Handler:   lockstatehttp.Chain(http.DefaultServeMux, middleware(cfg, accessLog)...),
+		ConnState: busy.track,
+		// Identifies clients on the Unix socket
+		ConnContext: peercred.ConnContext,
+	}}
+	httpLns := []net.Listener{ln}
+	serveErr := make(chan error, 4)
+	go func() {
+		slog.Info("Starting lock service", "addr", ln.Addr().String(), "pid", os.Getpid())
+		serveErr <- srv.http.Serve(ln)
+	}()
+	if unixLn != nil {
+		httpLns = append(httpLns, unixLn)
+		go func() {
+			slog.Info("Serving on Unix socket", "path", cfg.UnixSocket)
+			serveErr <- srv.http.Serve(unixLn)
+		}()
+	}
+	if grpcLn != nil {
+		srv.grpc = newGRPCService(manager, grpcOpts...)
+		go func() {
+			slog.Info("Starting gRPC service", "addr", grpcLn.Addr().String())
+			serveErr <- srv.grpc.server.Serve(grpcLn)
+		}()
+	}
+	if respLn != nil {
+		srv.resp = lockstateresp.New(manager, respOpts...)
+		go func() {
+			slog.Info("Starting RESP service", "addr", respLn.Addr().String())
+			serveErr <- srv.resp.Serve(respLn)
+		}()
+	}
+
+	for {
+		select {
+		case err := <-serveErr:
+			return fmt.Errorf("serving: %w", err)
+		case <-upgrades:
+			child, err := startUpgrade(ln, map[string]net.Listener{"grpc": grpcLn, "resp": respLn, "unix": unixLn})
+			if err != nil {
+				slog.Error("Upgrade failed, still serving", "err", err)
+				continue
+			}
+			n, err := child.handOver(srv, httpLns, &busy, manager, cfg.ShutdownTimeout)
+			if err != nil {
+				slog.Error("Upgrade failed after stopping", "err", err)
+				saveStateFile(manager, cfg)
+				return nil
+			}
+			slog.Info("Handed over locks", "locks", n, "pid", child.cmd.Process.Pid)
+			return nil
+		case <-ctx.Done():
+			// A second signal kills the process without waiting for the drain
+			stop()
+			shutdown(srv, manager, cfg)
+			return nil
+		}
+	}
+}
+
+// shutdown refuses new acquisitions for the drain period while holders renew
+// and release, stops the servers once in-flight requests finish, and saves
+// the remaining locks for the next instance
+func shutdown(srv servers, manager *lockstate.Manager, cfg config.Config) {
+	manager.SetDraining(true)
+	slog.Info("Draining", "drain_period", cfg.DrainPeriod)
+	drain(manager, cfg.DrainPeriod)
+
+	srv.stop(cfg.ShutdownTimeout)
+	saveStateFile(manager, cfg)
+}
+
+// drain waits up to period for every lock to be released, including those in
+// their grace period, which their holders may still reclaim
+func drain(manager *lockstate.Manager, period time.Duration) {
+	deadline := time.Now().Add(period)
+	for {
+		if stats := manager.Stats(); stats.Held == 0 && stats.InGrace == 0 {
+			return
+		}
+		wait := time.Until(deadline)
+		if wait <= 0 {
+			return
+		}
+		time.Sleep(min(drainPollInterval, wait))
+	}
+}
+
+// servers serve the lock manager; grpc and resp are nil when turned off
+type servers struct {
+	http *http.Server
+	grpc *grpcService
+	resp *lockstateresp.Server
+}
+
+// stop stops the servers side by side, so none eats into another's timeout
+func (s servers) stop(timeout time.Duration) {
+	var wg sync.WaitGroup
+	wg.Go(func() { stopServer(s.http, timeout) })
+	wg.Go(func() { s.grpc.stop(timeout) })
+	if s.resp != nil {
+		wg.Go(func() {
+			ctx, cancel := shutdownContext(timeout)
+			defer cancel()
+			if err := s.resp.Shutdown(ctx); err != nil {
+				slog.Warn("RESP shutdown did not complete", "err", err)
+			}
+		})
+	}
+	wg.Wait()
+}
+
+// stopServer stops accepting connections and waits up to timeout, or forever
+// if it's zero, for in-flight requests to finish
+func stopServer(server *http.Server, timeout time.Duration) {
+	ctx, cancel := shutdownContext(timeout)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		slog.Warn("Shutdown did not complete", "err", err)
+	}
+}
+
+// shutdownContext ends after timeout, or never if it's zero
+func shutdownContext(timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout > 0 {
+		return context.WithTimeout(context.Background(), timeout)
+	}
+	return context.WithCancel(context.Background())
+}
+
+// saveStateFile writes the state file, if one is configured
+func saveStateFile(manager *lockstate.Manager, cfg config.Config) {
+	if cfg.StateFile != "" {
+		n, err := saveState(cfg.StateFile, manager)
+		if err != nil {
+			slog.Error("Failed to save state file", "path", cfg.StateFile, "err", err)
+			return
+		}
+		slog.Info("Saved locks", "locks", n, "path", cfg.StateFile)
+	}
+}
+
+// middleware assembles the chain wrapped around every endpoint: request IDs
+// first so everything after can use them, then the access log so it sees the
+// final status, including a recovered panic's
+func middleware(cfg config.Config, accessLog io.Writer) []lockstatehttp.Middleware {
+	chain := []lockstatehttp.Middleware{lockstatehttp.WithRequestID}
+	if accessLog != nil {
+		chain = append(chain, lockstatehttp.AccessLog(accessLog, lockstatehttp.AccessLogFormat(cfg.AccessLogFormat)))
+	}
+	chain = append(chain, lockstatehttp.Recover(slog.Default()))
+	if len(cfg.CORSOrigins) > 0 {
+		chain = append(chain, lockstatehttp.CORS(lockstatehttp.CORSConfig{
+			AllowedOrigins: cfg.CORSOrigins,
+			MaxAge:         10 * time.Minute,
+		}))
+	}
+	if cfg.RequestTimeout > 0 {
+		chain = append(chain, lockstatehttp.Timeout(cfg.RequestTimeout))
+	}
+	if cfg.MaxBodySize > 0 {
+		chain = append(chain, lockstatehttp.MaxBodySize(int64(cfg.MaxBodySize)))
 	}
+	return chain
 }
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shadyabhi/foolock/lockstate"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDrainEndsOnceReleased(t *testing.T) {
+	m := lockstate.New()
+	start := time.Now()
+	drain(m, time.Minute)
+	require.Less(t, time.Since(start), time.Second, "nothing held, nothing to wait for")
+
+	m.Acquire("backup", "c1", time.Minute)
+	time.AfterFunc(50*time.Millisecond, func() { m.Release("backup", "c1") })
+	start = time.Now()
+	drain(m, time.Minute)
+	require.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+	require.Less(t, time.Since(start), time.Second)
+
+	m.Acquire("backup", "c1", time.Minute)
+	start = time.Now()
+	drain(m, 50*time.Millisecond)
+	require.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+	require.Equal(t, "c1", m.Status("backup").Holder)
+}
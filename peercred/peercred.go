@@ -0,0 +1,100 @@
+// Package peercred identifies the process on the other end of a Unix socket
+// from the credentials the kernel records for it, so local clients can be
+// trusted without naming themselves.
+package peercred
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/shadyabhi/foolock/lockstate"
+)
+
+// Cred is the peer process as it was when it connected
+type Cred struct {
+	UID uint32
+	GID uint32
+	PID int32
+	// Process is the executable name, empty if it couldn't be read; it's
+	// only logged
+	Process string
+}
+
+// uidPrefix begins every client name Client hands out
+const uidPrefix = "uid:"
+
+// Client is the lock client name for a peer calling itself named. A peer
+// that gives no name is its uid, such as uid:1000; a name is taken within
+// the peer's uid, such as uid:1000/backup, so one user's processes can't act
+// for another's while still being told apart by name. A name that is already
+// one of the peer's own, such as a holder read back from a response, is kept
+// as it is.
+func (c Cred) Client(named string) string {
+	uid := uidPrefix + strconv.FormatUint(uint64(c.UID), 10)
+	if named == "" || named == uid {
+		return uid
+	}
+	if strings.HasPrefix(named, uid+"/") {
+		return named
+	}
+	return uid + "/" + named
+}
+
+// Reserved reports whether client is a name only Client hands out, which
+// callers that didn't come over the Unix socket mustn't use
+func Reserved(client string) bool {
+	return strings.HasPrefix(client, uidPrefix)
+}
+
+type credKey struct{}
+
+// NewContext returns ctx carrying cred
+func NewContext(ctx context.Context, cred Cred) context.Context {
+	return context.WithValue(ctx, credKey{}, cred)
+}
+
+// FromContext returns the peer credentials in ctx, if the request came over
+// a Unix socket
+func FromContext(ctx context.Context) (Cred, bool) {
+	cred, ok := ctx.Value(credKey{}).(Cred)
+	return cred, ok
+}
+
+// ConnContext is an http.Server ConnContext hook adding the peer credentials
+// of Unix socket connections to their requests. A connection whose peer
+// can't be identified is closed rather than served anonymously.
+func ConnContext(ctx context.Context, c net.Conn) context.Context {
+	uc, ok := c.(*net.UnixConn)
+	if !ok {
+		return ctx
+	}
+	cred, err := Read(uc)
+	if err != nil {
+		slog.Warn("Closing Unix socket connection from unidentified peer", "err", err)
+		c.Close()
+		return ctx
+	}
+	return NewContext(ctx, cred)
+}
+
+// Policy maps uids to the jobs their processes may lock, and paths beneath
+// them; "*" allows every job. An empty policy allows everything.
+type Policy map[uint32][]string
+
+// Allows reports whether a process running as uid may lock job
+func (p Policy) Allows(uid uint32, job string) bool {
+	if len(p) == 0 {
+		return true
+	}
+	scopes, ok := p[uid]
+	if !ok {
+		return false
+	}
+	return slices.ContainsFunc(scopes, func(scope string) bool {
+		return scope == "*" || lockstate.Covers(scope, job)
+	})
+}
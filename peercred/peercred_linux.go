@@ -0,0 +1,45 @@
+package peercred
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"syscall"
+)
+
+// Read returns the credentials of the process that connected c, from
+// SO_PEERCRED
+func Read(c *net.UnixConn) (Cred, error) {
+	raw, err := c.SyscallConn()
+	if err != nil {
+		return Cred{}, err
+	}
+	var ucred *syscall.Ucred
+	var sockErr error
+	err = raw.Control(func(fd uintptr) {
+		ucred, sockErr = syscall.GetsockoptUcred(int(fd), syscall.SOL_SOCKET, syscall.SO_PEERCRED)
+	})
+	if err == nil {
+		err = sockErr
+	}
+	if err != nil {
+		return Cred{}, fmt.Errorf("reading SO_PEERCRED: %w", err)
+	}
+	return Cred{
+		UID:     ucred.Uid,
+		GID:     ucred.Gid,
+		PID:     ucred.Pid,
+		Process: processName(ucred.Pid),
+	}, nil
+}
+
+// processName reads the executable name from /proc; the process may already
+// have exited, or live in another pid namespace, so failing is expected
+func processName(pid int32) string {
+	comm, err := os.ReadFile(fmt.Sprintf("/proc/%d/comm", pid))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(comm))
+}
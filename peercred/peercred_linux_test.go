@@ -0,0 +1,89 @@
+package peercred
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// socketPair returns both ends of a connected Unix socket pair
+func socketPair(t *testing.T) (*net.UnixConn, *net.UnixConn) {
+	t.Helper()
+	fds, err := syscall.Socketpair(syscall.AF_UNIX, syscall.SOCK_STREAM|syscall.SOCK_CLOEXEC, 0)
+	require.NoError(t, err)
+	conns := make([]*net.UnixConn, 2)
+	for i, fd := range fds {
+		f := os.NewFile(uintptr(fd), "socketpair")
+		c, err := net.FileConn(f)
+		f.Close()
+		require.NoError(t, err)
+		t.Cleanup(func() { c.Close() })
+		conns[i] = c.(*net.UnixConn)
+	}
+	return conns[0], conns[1]
+}
+
+// self is what the kernel reports for this process
+func self(t *testing.T) Cred {
+	t.Helper()
+	comm, err := os.ReadFile("/proc/self/comm")
+	require.NoError(t, err)
+	return Cred{
+		UID:     uint32(os.Getuid()),
+		GID:     uint32(os.Getgid()),
+		PID:     int32(os.Getpid()),
+		Process: string(comm[:len(comm)-1]),
+	}
+}
+
+func TestRead(t *testing.T) {
+	a, b := socketPair(t)
+
+	cred, err := Read(a)
+	require.NoError(t, err)
+	require.Equal(t, self(t), cred)
+	require.NotEmpty(t, cred.Process)
+
+	cred, err = Read(b)
+	require.NoError(t, err)
+	require.Equal(t, self(t), cred)
+}
+
+func TestReadListener(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "foolock.sock")
+	ln, err := net.Listen("unix", path)
+	require.NoError(t, err)
+	defer ln.Close()
+
+	client, err := net.Dial("unix", path)
+	require.NoError(t, err)
+	defer client.Close()
+	server, err := ln.Accept()
+	require.NoError(t, err)
+	defer server.Close()
+
+	cred, err := Read(server.(*net.UnixConn))
+	require.NoError(t, err)
+	require.Equal(t, self(t), cred)
+}
+
+func TestConnContext(t *testing.T) {
+	a, _ := socketPair(t)
+	cred, ok := FromContext(ConnContext(context.Background(), a))
+	require.True(t, ok)
+	require.Equal(t, self(t), cred)
+
+	tcp, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer tcp.Close()
+	c, err := net.Dial("tcp", tcp.Addr().String())
+	require.NoError(t, err)
+	defer c.Close()
+	_, ok = FromContext(ConnContext(context.Background(), c))
+	require.False(t, ok, "only Unix socket peers are identified")
+}
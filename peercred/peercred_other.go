@@ -0,0 +1,14 @@
+//go:build !linux
+
+package peercred
+
+import (
+	"errors"
+	"net"
+)
+
+// Read is only implemented on Linux, where SO_PEERCRED reports the peer's
+// pid as well as its uid
+func Read(c *net.UnixConn) (Cred, error) {
+	return Cred{}, errors.ErrUnsupported
+}
@@ -0,0 +1,60 @@
+package peercred
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient(t *testing.T) {
+	cred := Cred{UID: 1000, PID: 42, Process: "rsync"}
+	require.Equal(t, "uid:1000/backup", cred.Client("backup"))
+	require.Equal(t, "uid:1000/backup", cred.Client("uid:1000/backup"), "already namespaced")
+	require.Equal(t, "uid:1000/uid:1001/backup", cred.Client("uid:1001/backup"), "not another uid's")
+	require.Equal(t, "uid:1000", cred.Client(""), "unnamed peers are their uid")
+	require.Equal(t, "uid:1000", cred.Client("uid:1000"))
+	require.NotEqual(t, cred.Client("cronA"), cred.Client("cronB"))
+}
+
+func TestReserved(t *testing.T) {
+	require.True(t, Reserved("uid:1000"))
+	require.True(t, Reserved("uid:1000/backup"))
+	require.False(t, Reserved("backup"))
+	require.False(t, Reserved("backup/uid:1000"))
+}
+
+func TestContext(t *testing.T) {
+	_, ok := FromContext(context.Background())
+	require.False(t, ok)
+
+	cred := Cred{UID: 1000, PID: 42, Process: "rsync"}
+	got, ok := FromContext(NewContext(context.Background(), cred))
+	require.True(t, ok)
+	require.Equal(t, cred, got)
+}
+
+func TestPolicy(t *testing.T) {
+	p := Policy{
+		0:    {"*"},
+		1000: {"backup", "/photos"},
+	}
+	tests := []struct {
+		uid      uint32
+		job      string
+		expected bool
+	}{
+		{0, "anything", true},
+		{1000, "backup", true},
+		{1000, "/photos/2024", true},
+		{1000, "/photos", true},
+		{1000, "sync", false},
+		{1000, "/", false},
+		{1001, "backup", false},
+	}
+	for _, tt := range tests {
+		require.Equal(t, tt.expected, p.Allows(tt.uid, tt.job), "uid %d job %q", tt.uid, tt.job)
+	}
+
+	require.True(t, Policy(nil).Allows(1001, "backup"), "no policy allows everyone")
+}
@@ -0,0 +1,153 @@
+// Package policy loads per-job lock policies from a YAML or JSON file.
+//
+// Example:
+//
+//	strict: false
+//	jobs:
+//	  backup:
+//	    default_ttl: 1m
+//	    min_ttl: 10s
+//	    max_ttl: 10m
+//	    grace_period: 30s
+//	    max_hold: 2h
+//	    cooldown: 1m
+//	    cooldown_mode: others
+//	    clients: [laptop1, macmini]
+//	uids:
+//	  0: ["*"]
+//	  1000: [backup, /photos]
+package policy
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/shadyabhi/foolock/lockstate"
+	"github.com/shadyabhi/foolock/peercred"
+	"gopkg.in/yaml.v3"
+)
+
+// Duration is a time.Duration written as a string such as "30s" or "2h"
+type Duration time.Duration
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	parsed, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+type Job struct {
+	DefaultTTL  Duration `yaml:"default_ttl"`
+	MinTTL      Duration `yaml:"min_ttl"`
+	MaxTTL      Duration `yaml:"max_ttl"`
+	GracePeriod Duration `yaml:"grace_period"`
+	MaxHold     Duration `yaml:"max_hold"`
+	Cooldown    Duration `yaml:"cooldown"`
+	// CooldownMode is "everyone" (default) or "others"
+	CooldownMode string   `yaml:"cooldown_mode"`
+	Clients      []string `yaml:"clients"`
+}
+
+type File struct {
+	// Strict rejects jobs that have no entry in Jobs, for themselves or an
+	// ancestor path
+	Strict bool           `yaml:"strict"`
+	Jobs   map[string]Job `yaml:"jobs"`
+	// UIDs maps uids to the jobs, and paths beneath them, their processes may
+	// lock over the Unix socket; "*" allows every job. Keys are strings so
+	// JSON files can use them too.
+	UIDs map[string][]string `yaml:"uids"`
+}
+
+// Load reads and validates a policy file. JSON is accepted as well, since
+// it is a subset of YAML.
+func Load(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+func Parse(data []byte) (*File, error) {
+	var f File
+	if err := yaml.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("parsing policy file: %w", err)
+	}
+	if err := f.validate(); err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+func (f *File) validate() error {
+	for name, job := range f.Jobs {
+		if _, err := cooldownMode(job.CooldownMode); err != nil {
+			return fmt.Errorf("job %q: %w", name, err)
+		}
+		if job.MinTTL > 0 && job.MaxTTL > 0 && job.MinTTL > job.MaxTTL {
+			return fmt.Errorf("job %q: min_ttl %s is greater than max_ttl %s", name, time.Duration(job.MinTTL), time.Duration(job.MaxTTL))
+		}
+		if job.DefaultTTL > 0 && job.MinTTL > 0 && job.DefaultTTL < job.MinTTL {
+			return fmt.Errorf("job %q: default_ttl %s is below min_ttl %s", name, time.Duration(job.DefaultTTL), time.Duration(job.MinTTL))
+		}
+		if job.DefaultTTL > 0 && job.MaxTTL > 0 && job.DefaultTTL > job.MaxTTL {
+			return fmt.Errorf("job %q: default_ttl %s is above max_ttl %s", name, time.Duration(job.DefaultTTL), time.Duration(job.MaxTTL))
+		}
+	}
+	for uid := range f.UIDs {
+		if _, err := strconv.ParseUint(uid, 10, 32); err != nil {
+			return fmt.Errorf("uids: %q is not a uid", uid)
+		}
+	}
+	return nil
+}
+
+// Options converts the file into lockstate manager options
+func (f *File) Options() []lockstate.Option {
+	policies := make(map[string]lockstate.Policy, len(f.Jobs))
+	for name, job := range f.Jobs {
+		mode, _ := cooldownMode(job.CooldownMode)
+		policies[name] = lockstate.Policy{
+			DefaultTTL:  time.Duration(job.DefaultTTL),
+			MinTTL:      time.Duration(job.MinTTL),
+			MaxTTL:      time.Duration(job.MaxTTL),
+			GracePeriod: time.Duration(job.GracePeriod),
+			MaxHold:     time.Duration(job.MaxHold),
+			Clients:     job.Clients,
+
+			Cooldown:     time.Duration(job.Cooldown),
+			CooldownMode: mode,
+		}
+	}
+	return []lockstate.Option{
+		lockstate.WithPolicies(policies),
+		lockstate.WithStrictPolicies(f.Strict),
+	}
+}
+
+// PeerPolicy returns the jobs each uid may lock over the Unix socket
+func (f *File) PeerPolicy() peercred.Policy {
+	p := make(peercred.Policy, len(f.UIDs))
+	for uid, jobs := range f.UIDs {
+		n, _ := strconv.ParseUint(uid, 10, 32)
+		p[uint32(n)] = jobs
+	}
+	return p
+}
+
+func cooldownMode(mode string) (lockstate.CooldownMode, error) {
+	switch mode {
+	case "", "everyone":
+		return lockstate.CooldownEveryone, nil
+	case "others":
+		return lockstate.CooldownOthers, nil
+	default:
+		return 0, fmt.Errorf("unknown cooldown_mode %q, want everyone or others", mode)
+	}
+}
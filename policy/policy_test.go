@@ -0,0 +1,131 @@
+package policy
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/shadyabhi/foolock/lockstate"
+	"github.com/shadyabhi/foolock/lockstate/msg"
+	"github.com/stretchr/testify/require"
+)
+
+const sample = `
+strict: true
+jobs:
+  backup:
+    default_ttl: 1m
+    min_ttl: 10s
+    max_ttl: 10m
+    grace_period: 30s
+    max_hold: 2h
+    clients: [laptop1, macmini]
+  sync:
+    cooldown: 1m
+    cooldown_mode: others
+uids:
+  0: ["*"]
+  1000: [backup]
+`
+
+func TestParse(t *testing.T) {
+	f, err := Parse([]byte(sample))
+	require.NoError(t, err)
+	require.True(t, f.Strict)
+	require.Len(t, f.Jobs, 2)
+
+	backup := f.Jobs["backup"]
+	require.Equal(t, Duration(time.Minute), backup.DefaultTTL)
+	require.Equal(t, Duration(10*time.Second), backup.MinTTL)
+	require.Equal(t, Duration(10*time.Minute), backup.MaxTTL)
+	require.Equal(t, Duration(30*time.Second), backup.GracePeriod)
+	require.Equal(t, Duration(2*time.Hour), backup.MaxHold)
+	require.Equal(t, []string{"laptop1", "macmini"}, backup.Clients)
+
+	sync := f.Jobs["sync"]
+	require.Equal(t, Duration(time.Minute), sync.Cooldown)
+	require.Equal(t, "others", sync.CooldownMode)
+
+	require.Equal(t, map[string][]string{"0": {"*"}, "1000": {"backup"}}, f.UIDs)
+}
+
+func TestParseJSON(t *testing.T) {
+	f, err := Parse([]byte(`{"jobs": {"backup": {"default_ttl": "45s"}}}`))
+	require.NoError(t, err)
+	require.False(t, f.Strict)
+	require.Equal(t, Duration(45*time.Second), f.Jobs["backup"].DefaultTTL)
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"bad duration", "jobs: {backup: {default_ttl: soon}}"},
+		{"min above max", "jobs: {backup: {min_ttl: 1m, max_ttl: 10s}}"},
+		{"default below min", "jobs: {backup: {default_ttl: 5s, min_ttl: 10s}}"},
+		{"default above max", "jobs: {backup: {default_ttl: 1h, max_ttl: 10m}}"},
+		{"not yaml", "jobs: [unclosed"},
+		{"bad cooldown mode", "jobs: {sync: {cooldown: 1m, cooldown_mode: nobody}}"},
+		{"uid not a number", "uids: {root: [backup]}"},
+		{"uid out of range", "uids: {-1: [backup]}"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(tt.data))
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(sample), 0o644))
+
+	f, err := Load(path)
+	require.NoError(t, err)
+	require.Len(t, f.Jobs, 2)
+
+	_, err = Load(filepath.Join(t.TempDir(), "missing.yaml"))
+	require.Error(t, err)
+}
+
+func TestOptions(t *testing.T) {
+	f, err := Parse([]byte(sample))
+	require.NoError(t, err)
+
+	m := lockstate.New(f.Options()...)
+
+	result := m.Acquire("backup", "laptop1", 0)
+	require.True(t, result.Success)
+	require.WithinDuration(t, time.Now().Add(time.Minute), result.ExpiresAt, time.Second)
+
+	result = m.Acquire("backup", "stranger", 0)
+	require.Equal(t, msg.ClientNotPermitted, result.Message)
+
+	result = m.Acquire("unlisted", "laptop1", time.Minute)
+	require.Equal(t, msg.UnknownJob, result.Message)
+
+	m.Acquire("sync", "laptop1", time.Minute)
+	m.Release("sync", "laptop1")
+	result = m.Acquire("sync", "macmini", time.Minute)
+	require.Equal(t, msg.CooldownActive, result.Message)
+	result = m.Acquire("sync", "laptop1", time.Minute)
+	require.True(t, result.Success)
+}
+
+func TestPeerPolicy(t *testing.T) {
+	f, err := Parse([]byte(sample))
+	require.NoError(t, err)
+	p := f.PeerPolicy()
+	require.True(t, p.Allows(0, "sync"))
+	require.True(t, p.Allows(1000, "backup"))
+	require.False(t, p.Allows(1000, "sync"))
+	require.False(t, p.Allows(1001, "backup"))
+
+	f, err = Parse([]byte(`{"uids": {"1000": ["backup"]}}`))
+	require.NoError(t, err)
+	require.True(t, f.PeerPolicy().Allows(1000, "backup"))
+}
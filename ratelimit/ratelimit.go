@@ -0,0 +1,208 @@
+// Package ratelimit throttles lock requests with token buckets kept per
+// client, per remote IP and per client on busy jobs, so a client stuck in a
+// retry loop can't monopolise the lock manager or flood the logs.
+package ratelimit
+
+import (
+	"maps"
+	"math"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// Limit is a token bucket refilling at Rate tokens per second and holding up
+// to Burst of them. A zero Rate means no limit; a zero Burst allows bursts of
+// one second's worth of requests.
+type Limit struct {
+	Rate  float64 `yaml:"rate" json:"rate"`
+	Burst int     `yaml:"burst" json:"burst"`
+}
+
+func (l Limit) burst() int {
+	if l.Burst > 0 {
+		return l.Burst
+	}
+	return max(1, int(math.Ceil(l.Rate)))
+}
+
+// refill is how long an unused bucket takes to fill up again, after which it
+// is no different from a new one
+func (l Limit) refill() time.Duration {
+	return time.Duration(float64(l.burst()) / l.Rate * float64(time.Second))
+}
+
+// Config says how fast each client and address may make lock requests
+type Config struct {
+	// Client applies to each client, unless Clients says otherwise
+	Client Limit
+	// IP applies to each remote address, whatever client it claims to be
+	IP Limit
+	// Clients replaces Client for the clients listed
+	Clients map[string]Limit
+	// Jobs limits each client on the jobs listed, on top of its own limit
+	Jobs map[string]Limit
+}
+
+// Enabled reports whether any limit is set
+func (c Config) Enabled() bool {
+	if c.Client.Rate > 0 || c.IP.Rate > 0 {
+		return true
+	}
+	for _, l := range c.Clients {
+		if l.Rate > 0 {
+			return true
+		}
+	}
+	for _, l := range c.Jobs {
+		if l.Rate > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+type bucketKind int
+
+const (
+	clientBucket bucketKind = iota
+	ipBucket
+	jobBucket
+)
+
+type bucketKey struct {
+	kind bucketKind
+	name string
+	job  string
+}
+
+type bucket struct {
+	limiter  *rate.Limiter
+	idleFrom time.Time
+	refill   time.Duration
+}
+
+// sweepInterval is how often idle buckets are dropped
+const sweepInterval = time.Minute
+
+// Limiter decides whether a lock request may go ahead
+type Limiter struct {
+	cfg Config
+
+	mu        sync.Mutex
+	buckets   map[bucketKey]*bucket
+	lastSweep time.Time
+	clients   map[string]uint64
+	ips       map[string]uint64
+}
+
+func New(cfg Config) *Limiter {
+	return &Limiter{
+		cfg:     cfg,
+		buckets: make(map[bucketKey]*bucket),
+		clients: make(map[string]uint64),
+		ips:     make(map[string]uint64),
+	}
+}
+
+// Allow takes a token from every bucket that applies to a request by client
+// from ip on jobs. If any of them is empty, nothing is taken and Allow
+// returns false along with how long until the request would be allowed.
+func (l *Limiter) Allow(client, ip string, jobs []string) (bool, time.Duration) {
+	return l.allowAt(client, ip, jobs, time.Now())
+}
+
+func (l *Limiter) allowAt(client, ip string, jobs []string, now time.Time) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.sweep(now)
+
+	type check struct {
+		key   bucketKey
+		limit Limit
+	}
+	var checks []check
+	if client != "" {
+		limit, ok := l.cfg.Clients[client]
+		if !ok {
+			limit = l.cfg.Client
+		}
+		checks = append(checks, check{bucketKey{kind: clientBucket, name: client}, limit})
+		for _, job := range jobs {
+			if limit, ok := l.cfg.Jobs[job]; ok {
+				checks = append(checks, check{bucketKey{kind: jobBucket, name: client, job: job}, limit})
+			}
+		}
+	}
+	if ip != "" {
+		checks = append(checks, check{bucketKey{kind: ipBucket, name: ip}, l.cfg.IP})
+	}
+
+	var taken []*rate.Reservation
+	for _, c := range checks {
+		if c.limit.Rate <= 0 {
+			continue
+		}
+		b := l.bucket(c.key, c.limit, now)
+		r := b.limiter.ReserveN(now, 1)
+		if delay := r.DelayFrom(now); delay > 0 {
+			r.CancelAt(now)
+			for _, t := range taken {
+				t.CancelAt(now)
+			}
+			l.countThrottle(c.key)
+			return false, delay
+		}
+		taken = append(taken, r)
+	}
+	return true, 0
+}
+
+func (l *Limiter) bucket(key bucketKey, limit Limit, now time.Time) *bucket {
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{
+			limiter: rate.NewLimiter(rate.Limit(limit.Rate), limit.burst()),
+			refill:  limit.refill(),
+		}
+		l.buckets[key] = b
+	}
+	b.idleFrom = now
+	return b
+}
+
+func (l *Limiter) countThrottle(key bucketKey) {
+	if key.kind == ipBucket {
+		l.ips[key.name]++
+	} else {
+		l.clients[key.name]++
+	}
+}
+
+// sweep drops buckets that have been idle long enough to be full again, so
+// one-off clients and addresses don't accumulate
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+	l.lastSweep = now
+	for key, b := range l.buckets {
+		if now.Sub(b.idleFrom) > max(b.refill, sweepInterval) {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// Throttled counts the requests refused so far, by client and by address
+type Throttled struct {
+	Clients map[string]uint64
+	IPs     map[string]uint64
+}
+
+// Throttled returns how many requests each client and address had refused
+func (l *Limiter) Throttled() Throttled {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return Throttled{Clients: maps.Clone(l.clients), IPs: maps.Clone(l.ips)}
+}
@@ -0,0 +1,101 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+var start = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func TestAllowClient(t *testing.T) {
+	l := New(Config{Client: Limit{Rate: 1, Burst: 2}})
+
+	ok, _ := l.allowAt("c1", "", nil, start)
+	require.True(t, ok)
+	ok, _ = l.allowAt("c1", "", nil, start)
+	require.True(t, ok)
+
+	ok, retry := l.allowAt("c1", "", nil, start)
+	require.False(t, ok, "burst used up")
+	require.Equal(t, time.Second, retry)
+
+	ok, _ = l.allowAt("c2", "", nil, start)
+	require.True(t, ok, "clients have separate buckets")
+
+	ok, _ = l.allowAt("c1", "", nil, start.Add(time.Second))
+	require.True(t, ok, "refilled")
+
+	require.Equal(t, Throttled{Clients: map[string]uint64{"c1": 1}, IPs: map[string]uint64{}}, l.Throttled())
+}
+
+func TestAllowOverrides(t *testing.T) {
+	l := New(Config{
+		Client:  Limit{Rate: 1, Burst: 1},
+		Clients: map[string]Limit{"ci": {Rate: 100, Burst: 20}, "trusted": {}},
+		Jobs:    map[string]Limit{"busy": {Rate: 0.1, Burst: 1}},
+	})
+
+	for range 10 {
+		ok, _ := l.allowAt("ci", "", nil, start)
+		require.True(t, ok, "client override raises the limit")
+	}
+	for range 100 {
+		ok, _ := l.allowAt("trusted", "", nil, start)
+		require.True(t, ok, "a zero rate is unlimited")
+	}
+
+	ok, _ := l.allowAt("ci", "", []string{"busy"}, start)
+	require.True(t, ok)
+	ok, retry := l.allowAt("ci", "", []string{"other", "busy"}, start)
+	require.False(t, ok, "job limit applies on top of the client's")
+	require.Equal(t, 10*time.Second, retry)
+
+	ok, _ = l.allowAt("ci", "", []string{"other"}, start)
+	require.True(t, ok, "refusal took no token from the client bucket")
+}
+
+func TestAllowIP(t *testing.T) {
+	l := New(Config{IP: Limit{Rate: 1, Burst: 1}})
+
+	ok, _ := l.allowAt("c1", "192.0.2.1", nil, start)
+	require.True(t, ok)
+	ok, _ = l.allowAt("c2", "192.0.2.1", nil, start)
+	require.False(t, ok, "changing client id doesn't escape the address limit")
+	ok, _ = l.allowAt("c2", "192.0.2.2", nil, start)
+	require.True(t, ok)
+
+	require.Equal(t, map[string]uint64{"192.0.2.1": 1}, l.Throttled().IPs)
+	require.Empty(t, l.Throttled().Clients)
+}
+
+func TestAllowRefusalTakesNothing(t *testing.T) {
+	l := New(Config{Client: Limit{Rate: 1, Burst: 1}, IP: Limit{Rate: 1, Burst: 2}})
+
+	ok, _ := l.allowAt("c1", "192.0.2.1", nil, start)
+	require.True(t, ok)
+	ok, _ = l.allowAt("c1", "192.0.2.1", nil, start)
+	require.False(t, ok)
+
+	// The IP bucket still has the token the refused request didn't use
+	ok, _ = l.allowAt("c2", "192.0.2.1", nil, start)
+	require.True(t, ok)
+}
+
+func TestSweep(t *testing.T) {
+	l := New(Config{Client: Limit{Rate: 1, Burst: 1}})
+	l.allowAt("c1", "", nil, start)
+	l.allowAt("c2", "", nil, start.Add(50*time.Second))
+	require.Len(t, l.buckets, 2)
+
+	l.allowAt("c2", "", nil, start.Add(2*time.Minute+time.Second))
+	require.Len(t, l.buckets, 1, "idle bucket dropped")
+}
+
+func TestEnabled(t *testing.T) {
+	require.False(t, Config{}.Enabled())
+	require.False(t, Config{Clients: map[string]Limit{"c1": {}}}.Enabled())
+	require.True(t, Config{IP: Limit{Rate: 5}}.Enabled())
+	require.True(t, Config{Jobs: map[string]Limit{"busy": {Rate: 1}}}.Enabled())
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/shadyabhi/foolock/lockstate"
+)
+
+// loadState restores the manager from a snapshot written by saveState. A
+// missing file is not an error: it's the first start.
+func loadState(path string, m *lockstate.Manager) (int, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	var snap lockstate.Snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return 0, fmt.Errorf("parsing state file: %w", err)
+	}
+	if err := m.Restore(snap); err != nil {
+		return 0, err
+	}
+	return len(snap.Locks), nil
+}
+
+// saveState writes the manager's snapshot to path, replacing it atomically so
+// a crash mid-write leaves the previous state intact. The file and the rename
+// are synced to disk before it returns, so a power loss right after shutdown
+// doesn't lose them either.
+func saveState(path string, m *lockstate.Manager) (int, error) {
+	snap := m.Snapshot()
+	data, err := json.MarshalIndent(snap, "", "  ")
+	if err != nil {
+		return 0, err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return 0, err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return 0, err
+	}
+	if err := tmp.Close(); err != nil {
+		return 0, err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return 0, err
+	}
+	if err := syncDir(filepath.Dir(path)); err != nil {
+		return 0, err
+	}
+	return len(snap.Locks), nil
+}
+
+// syncDir flushes a directory's entries, such as a rename into it, to disk
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+// checkStateDir reports whether the state file's directory is there to be
+// written to on shutdown
+func checkStateDir(path string) error {
+	dir := filepath.Dir(path)
+	info, err := os.Stat(dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", dir)
+	}
+	return nil
+}
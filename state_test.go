@@ -0,0 +1,53 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/shadyabhi/foolock/lockstate"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStateFileRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+
+	m := lockstate.New()
+	n, err := loadState(path, m)
+	require.NoError(t, err, "a missing state file is a first start")
+	require.Zero(t, n)
+
+	m.Acquire("backup", "c1", time.Minute)
+	m.Acquire("report", "c2", time.Minute)
+	n, err = saveState(path, m)
+	require.NoError(t, err)
+	require.Equal(t, 2, n)
+
+	next := lockstate.New()
+	n, err = loadState(path, next)
+	require.NoError(t, err)
+	require.Equal(t, 2, n)
+	require.Equal(t, "c1", next.Status("backup").Holder)
+
+	result := next.Acquire("backup", "c2", time.Minute)
+	require.False(t, result.Success, "restored lock must still be held")
+}
+
+func TestLoadStateInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	require.NoError(t, os.WriteFile(path, []byte("{"), 0o644))
+
+	_, err := loadState(path, lockstate.New())
+	require.Error(t, err)
+}
+
+func TestCheckStateDir(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, checkStateDir(filepath.Join(dir, "state.json")))
+	require.Error(t, checkStateDir(filepath.Join(dir, "missing", "state.json")))
+
+	file := filepath.Join(dir, "file")
+	require.NoError(t, os.WriteFile(file, nil, 0o644))
+	require.Error(t, checkStateDir(filepath.Join(file, "state.json")))
+}
@@ -0,0 +1,310 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shadyabhi/foolock/lockstate"
+)
+
+// upgradeEnv is set in the environment of a process started by an upgrade so
+// it takes the listener and state from its parent instead of starting fresh
+const upgradeEnv = "FOOLOCK_UPGRADE"
+
+// upgradeListenersEnv names the other listeners passed along, such as the
+// gRPC one, comma separated in the order of their descriptors
+const upgradeListenersEnv = "FOOLOCK_UPGRADE_LISTENERS"
+
+// Descriptors passed to the new process, in ExtraFiles order; the other
+// listeners follow from extraListenerFD
+const (
+	listenerFD = 3 + iota
+	stateFD
+	readyFD
+	extraListenerFD
+)
+
+// upgradeReadyTimeout bounds how long the old process waits for the new one
+// to finish starting before giving up and carrying on serving
+const upgradeReadyTimeout = 10 * time.Second
+
+// listen returns the listening socket: inherited from the parent when started
+// by an upgrade, otherwise a new one on addr
+func listen(addr string) (net.Listener, *upgradeParent, error) {
+	if os.Getenv(upgradeEnv) == "" {
+		ln, err := net.Listen("tcp", addr)
+		return ln, nil, err
+	}
+	os.Unsetenv(upgradeEnv)
+
+	ln, err := inheritListener(listenerFD)
+	if err != nil {
+		return nil, nil, err
+	}
+	parent := &upgradeParent{
+		pid:   os.Getppid(),
+		state: os.NewFile(stateFD, "state"),
+		ready: os.NewFile(readyFD, "ready"),
+	}
+	if names := os.Getenv(upgradeListenersEnv); names != "" {
+		os.Unsetenv(upgradeListenersEnv)
+		parent.listeners = make(map[string]net.Listener)
+		for i, name := range strings.Split(names, ",") {
+			extra, err := inheritListener(extraListenerFD + uintptr(i))
+			if err != nil {
+				ln.Close()
+				for _, l := range parent.listeners {
+					l.Close()
+				}
+				return nil, nil, err
+			}
+			parent.listeners[name] = extra
+		}
+	}
+	return ln, parent, nil
+}
+
+// listenExtra returns the listening socket called name, inherited from the
+// parent if it passed one, or nil if addr is empty because it's turned off
+func listenExtra(name, network, addr string, parent *upgradeParent) (net.Listener, error) {
+	var inherited net.Listener
+	if parent != nil {
+		inherited = parent.listeners[name]
+	}
+	switch {
+	case addr == "":
+		if inherited != nil {
+			inherited.Close()
+		}
+		return nil, nil
+	case inherited != nil:
+		return inherited, nil
+	case network == "unix":
+		return listenUnix(addr)
+	default:
+		return net.Listen(network, addr)
+	}
+}
+
+// listenUnix listens on a Unix socket at path, replacing one a previous
+// process left behind, and lets every local user connect: the peer policy
+// decides what they may lock
+func listenUnix(path string) (net.Listener, error) {
+	if fi, err := os.Lstat(path); err == nil && fi.Mode()&os.ModeSocket != 0 {
+		if c, err := net.Dial("unix", path); err == nil {
+			c.Close()
+			return nil, fmt.Errorf("%s is in use", path)
+		}
+		os.Remove(path)
+	}
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, 0o666); err != nil {
+		ln.Close()
+		return nil, err
+	}
+	return ln, nil
+}
+
+func inheritListener(fd uintptr) (net.Listener, error) {
+	f := os.NewFile(fd, "listener")
+	ln, err := net.FileListener(f)
+	f.Close()
+	if err != nil {
+		return nil, fmt.Errorf("inheriting listener: %w", err)
+	}
+	return ln, nil
+}
+
+// upgradeParent is the old process, seen from the new one
+type upgradeParent struct {
+	pid       int
+	state     *os.File
+	ready     *os.File
+	listeners map[string]net.Listener
+}
+
+// takeOver tells the parent this process started successfully, then waits for
+// it to stop serving and send its locks. Nothing may be served before this
+// returns, or two processes would hand out the same lock.
+func (p *upgradeParent) takeOver(m *lockstate.Manager) (int, error) {
+	defer p.state.Close()
+
+	_, err := p.ready.Write([]byte{1})
+	p.ready.Close()
+	if err != nil {
+		return 0, fmt.Errorf("signalling readiness: %w", err)
+	}
+
+	var snap lockstate.Snapshot
+	if err := json.NewDecoder(p.state).Decode(&snap); err != nil {
+		return 0, fmt.Errorf("receiving state: %w", err)
+	}
+	if err := m.Restore(snap); err != nil {
+		return 0, err
+	}
+	return len(snap.Locks), nil
+}
+
+// upgradeChild is the new process, seen from the old one
+type upgradeChild struct {
+	cmd   *exec.Cmd
+	state *os.File
+}
+
+// startUpgrade re-executes the current binary with the same arguments, hands
+// it a copy of the listening sockets, ln and the extra ones by name, and waits
+// until it's ready to take over. Both processes can accept on the sockets from
+// here on, but the new one doesn't until it has the state, so connections just
+// queue meanwhile.
+func startUpgrade(ln net.Listener, extra map[string]net.Listener) (*upgradeChild, error) {
+	listener, err := listenerFile(ln)
+	if err != nil {
+		return nil, err
+	}
+	defer listener.Close()
+	var names []string
+	var extraFiles []*os.File
+	for name, l := range extra {
+		if l == nil {
+			continue
+		}
+		f, err := listenerFile(l)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		names = append(names, name)
+		extraFiles = append(extraFiles, f)
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	stateR, stateW, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	defer stateR.Close()
+	readyR, readyW, err := os.Pipe()
+	if err != nil {
+		stateW.Close()
+		return nil, err
+	}
+	defer readyR.Close()
+
+	cmd := exec.Command(exe, os.Args[1:]...)
+	cmd.Env = append(os.Environ(), upgradeEnv+"=1")
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.ExtraFiles = []*os.File{listener, stateR, readyW}
+	if len(names) > 0 {
+		cmd.Env = append(cmd.Env, upgradeListenersEnv+"="+strings.Join(names, ","))
+		cmd.ExtraFiles = append(cmd.ExtraFiles, extraFiles...)
+	}
+	err = cmd.Start()
+	readyW.Close()
+	if err != nil {
+		stateW.Close()
+		return nil, err
+	}
+
+	// The child exiting early closes its end of the pipe, ending the read
+	readyR.SetReadDeadline(time.Now().Add(upgradeReadyTimeout))
+	if _, err := io.ReadFull(readyR, make([]byte, 1)); err != nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+		stateW.Close()
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			return nil, fmt.Errorf("new process not ready after %s", upgradeReadyTimeout)
+		}
+		return nil, errors.New("new process exited during startup")
+	}
+
+	go cmd.Wait()
+	return &upgradeChild{cmd: cmd, state: stateW}, nil
+}
+
+// listenerFile duplicates a listening socket to pass to the new process
+func listenerFile(ln net.Listener) (*os.File, error) {
+	switch l := ln.(type) {
+	case *net.TCPListener:
+		return l.File()
+	case *net.UnixListener:
+		// The new process serves on the same path, so closing this one must
+		// leave it in place
+		l.SetUnlinkOnClose(false)
+		return l.File()
+	default:
+		return nil, fmt.Errorf("cannot hand over a %T", ln)
+	}
+}
+
+// handOver stops accepting, waits for in-flight requests and RPCs so the
+// snapshot includes them, and sends the locks to the new process.
+//
+// The HTTP listeners are closed before shutting the server down because Shutdown
+// drops connections that were accepted but hadn't sent their request yet;
+// this way they are answered here and only idle keep-alive connections are
+// closed, which clients retry.
+func (c *upgradeChild) handOver(s servers, lns []net.Listener, busy *busyConns, m *lockstate.Manager, timeout time.Duration) (int, error) {
+	defer c.state.Close()
+
+	for _, ln := range lns {
+		ln.Close()
+	}
+	busy.wait(timeout)
+	s.stop(timeout)
+
+	snap := m.Snapshot()
+	if err := json.NewEncoder(c.state).Encode(snap); err != nil {
+		return 0, fmt.Errorf("sending state to pid %d: %w", c.cmd.Process.Pid, err)
+	}
+	return len(snap.Locks), nil
+}
+
+// busyConns tracks connections with a request on the way or in progress, as
+// an http.Server ConnState hook
+type busyConns struct {
+	mu    sync.Mutex
+	conns map[net.Conn]struct{}
+}
+
+func (b *busyConns) track(c net.Conn, state http.ConnState) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.conns == nil {
+		b.conns = make(map[net.Conn]struct{})
+	}
+	if state == http.StateNew || state == http.StateActive {
+		b.conns[c] = struct{}{}
+	} else {
+		delete(b.conns, c)
+	}
+}
+
+func (b *busyConns) count() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.conns)
+}
+
+// wait returns once no connection is busy, or after timeout if it's not zero
+func (b *busyConns) wait(timeout time.Duration) {
+	deadline := time.Now().Add(timeout)
+	for b.count() > 0 && (timeout == 0 || time.Now().Before(deadline)) {
+		time.Sleep(10 * time.Millisecond)
+	}
+}
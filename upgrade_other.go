@@ -0,0 +1,8 @@
+//go:build !unix
+
+package main
+
+import "os"
+
+// upgradeSignals is empty: handing a socket to a child process is unix-only
+var upgradeSignals []os.Signal
@@ -0,0 +1,204 @@
+//go:build unix
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// runServerEnv makes the test binary run main instead of the tests, so
+// upgrade tests can spawn real server processes that re-execute themselves
+const runServerEnv = "FOOLOCK_TEST_RUN_SERVER"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(runServerEnv) != "" {
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// syncBuffer collects the log output of the server processes
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func freeAddr(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+	return ln.Addr().String()
+}
+
+func TestUpgradeHandsOverListenerAndLocks(t *testing.T) {
+	if testing.Short() {
+		t.Skip("spawns server processes")
+	}
+	addr := freeAddr(t)
+	base := "http://" + addr
+
+	// Both processes write to the same pipe; an *os.File avoids exec's copy
+	// goroutine, which would wait for the new process to exit too
+	logR, logW, err := os.Pipe()
+	require.NoError(t, err)
+	var logs syncBuffer
+	go io.Copy(&logs, logR)
+	t.Cleanup(func() { logR.Close() })
+
+	old := exec.Command(os.Args[0], "-addr", addr)
+	old.Env = append(os.Environ(), runServerEnv+"=1")
+	old.Stderr = logW
+	require.NoError(t, old.Start())
+	logW.Close()
+	t.Cleanup(func() { old.Process.Kill() })
+
+	// Each request opens a new connection, so every one of them goes through
+	// the listening socket
+	client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}, Timeout: 5 * time.Second}
+	require.Eventually(t, func() bool {
+		resp, err := client.Get(base + "/lock")
+		if err == nil {
+			resp.Body.Close()
+		}
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond, logs.String())
+
+	resp, err := client.Post(base+"/lock?job=backup&client=c1&ttl=1m", "", nil)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// Keep requests going through the upgrade; none may fail
+	var failures atomic.Int64
+	var firstErr atomic.Value
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			resp, err := client.Get(base + "/lock?job=backup")
+			if err != nil {
+				failures.Add(1)
+				firstErr.CompareAndSwap(nil, err.Error())
+				continue
+			}
+			resp.Body.Close()
+		}
+	}()
+
+	require.NoError(t, old.Process.Signal(syscall.SIGUSR2))
+	require.NoError(t, old.Wait(), "old process should exit cleanly after handing over")
+	close(done)
+	wg.Wait()
+	require.Zero(t, failures.Load(), "requests failed during upgrade: %v\n%s", firstErr.Load(), logs.String())
+
+	// The log pipe may not have been drained yet
+	handedOver := regexp.MustCompile(`msg="Handed over locks" locks=\d+ pid=(\d+)`)
+	var match []string
+	require.Eventually(t, func() bool {
+		match = handedOver.FindStringSubmatch(logs.String())
+		return match != nil
+	}, 5*time.Second, 10*time.Millisecond, logs.String())
+	pid, err := strconv.Atoi(match[1])
+	require.NoError(t, err)
+	t.Cleanup(func() { syscall.Kill(pid, syscall.SIGKILL) })
+	require.NotEqual(t, old.Process.Pid, pid)
+
+	// The new process serves the lock the old one granted
+	resp, err = client.Get(base + "/lock?job=backup")
+	require.NoError(t, err)
+	var status struct {
+		Holder string `json:"holder"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&status))
+	resp.Body.Close()
+	require.Equal(t, "c1", status.Holder)
+
+	resp, err = client.Post(base+"/lock?job=backup&client=c2", "", nil)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusConflict, resp.StatusCode)
+
+	require.NoError(t, syscall.Kill(pid, syscall.SIGTERM))
+	require.Eventually(t, func() bool {
+		return syscall.Kill(pid, 0) != nil
+	}, 15*time.Second, 50*time.Millisecond, fmt.Sprintf("pid %d did not exit\n%s", pid, logs.String()))
+}
+
+// A new process that fails to start must do so before the old one hands over
+// and exits, or the locks would be lost
+func TestUpgradeFailingChildLeavesParentServing(t *testing.T) {
+	if testing.Short() {
+		t.Skip("spawns server processes")
+	}
+	addr := freeAddr(t)
+	base := "http://" + addr
+	logDir := t.TempDir()
+
+	var logs syncBuffer
+	old := exec.Command(os.Args[0], "-addr", addr, "-access-log", logDir+"/logs/access.log")
+	old.Env = append(os.Environ(), runServerEnv+"=1")
+	old.Stderr = &logs
+	require.NoError(t, os.Mkdir(logDir+"/logs", 0o755))
+	require.NoError(t, old.Start())
+	t.Cleanup(func() { old.Process.Kill() })
+
+	client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}, Timeout: 5 * time.Second}
+	require.Eventually(t, func() bool {
+		resp, err := client.Post(base+"/lock?job=backup&client=c1&ttl=1m", "", nil)
+		if err != nil {
+			return false
+		}
+		resp.Body.Close()
+		return resp.StatusCode == http.StatusOK
+	}, 5*time.Second, 10*time.Millisecond, logs.String())
+
+	// The new process can't open the access log
+	require.NoError(t, os.RemoveAll(logDir+"/logs"))
+	require.NoError(t, old.Process.Signal(syscall.SIGUSR2))
+	require.Eventually(t, func() bool {
+		return strings.Contains(logs.String(), "Upgrade failed, still serving")
+	}, 15*time.Second, 10*time.Millisecond, logs.String())
+
+	resp, err := client.Post(base+"/lock?job=backup&client=c2", "", nil)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusConflict, resp.StatusCode, logs.String())
+}